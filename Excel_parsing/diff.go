@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/FrancoisDuvet/friendly-chainsaw/Excel_parsing/gradebook"
+)
+
+// diff runs the "diff" subcommand: it compares two versions of a gradebook
+// by Emplid and reports added and removed students, mark changes and the
+// rank and grade changes they cause.
+func diff(args []string) {
+	fs := flag.NewFlagSet("diff", flag.ExitOnError)
+	format := fs.String("format", "text", "Output format: text or json")
+	output := fs.String("output", "-", "Output file, or - for stdout")
+	columnsFile := fs.String("columns", "", "JSON or YAML file with column header synonyms and extra components")
+	sheetName := fs.String("sheet", "", "Read the named sheet instead of the first one")
+	allSheets := fs.Bool("all-sheets", false, "Read every sheet of each workbook")
+	branchFile := fs.String("branches", "", "JSON or YAML file with the campus ID pattern and branch/campus names")
+	dualDegree := fs.String("dual-degree", "", "Group dual-degree students under their "+strings.Join(gradebook.DualDegreeModes, ", ")+" degree")
+	gradingScheme := fs.String("grading", "", "Assign letter grades using "+strings.Join(gradebook.GradingSchemes, ", "))
+	gradingFile := fs.String("grading-config", "", "JSON or YAML file with grade cutoffs, bands or quotas")
+	weightsFile := fs.String("weights", "", "JSON or YAML file with component weights and maximum marks")
+	rankBy := fs.String("rank-by", gradebook.FieldTotal, "Component or arithmetic expression to rank by")
+	rankMethod := fs.String("rank-method", gradebook.RankCompetition, "Rank numbering: "+strings.Join(gradebook.RankMethods, ", "))
+	var tieBreakers stringList
+	fs.Var(&tieBreakers, "tie-break", "Tie-breakers in order, e.g. Compre,MidSem,Emplid")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: diff [flags] OLD NEW")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+	if fs.NArg() != 2 {
+		fs.Usage()
+		os.Exit(2)
+	}
+	if *format != "text" && *format != "json" {
+		fmt.Println("Unknown diff format:", *format)
+		return
+	}
+
+	columnCfg, err := gradebook.LoadColumnConfig(*columnsFile)
+	if err != nil {
+		fmt.Println("Error reading column config:", err)
+		return
+	}
+	branches, err := gradebook.LoadBranchRegistry(*branchFile, *dualDegree)
+	if err != nil {
+		fmt.Println("Error reading branch registry:", err)
+		return
+	}
+	loadOpts := gradebook.LoadOptions{
+		Columns:  columnCfg,
+		Sheets:   gradebook.SheetSelection{Name: *sheetName, All: *allSheets},
+		Branches: branches,
+	}
+	// Each version gets its own loader so that a student present in both
+	// is not reported as a duplicate Emplid.
+	var versions [2]gradebook.Version
+	for i, path := range fs.Args() {
+		loader, err := gradebook.NewLoader(loadOpts)
+		if err != nil {
+			fmt.Println("Error preparing loader:", err)
+			return
+		}
+		versions[i].Name = filepath.Base(path)
+		if versions[i].Students, err = loader.LoadFile(path); err != nil {
+			fmt.Println("Error opening file:", err)
+			return
+		}
+		versions[i].Validation = loader.Validation()
+		gradebook.PrintValidationReport(os.Stderr, versions[i].Validation)
+	}
+
+	opts := gradebook.Options{
+		Rank:     gradebook.RankOptions{By: *rankBy, Method: *rankMethod, TieBreakers: tieBreakers},
+		MaxTotal: columnCfg.MaxMarks[gradebook.FieldTotal],
+	}
+	if *gradingScheme != "" || *gradingFile != "" {
+		gradingCfg, err := gradebook.LoadGradingConfig(*gradingFile, *gradingScheme)
+		if err != nil {
+			fmt.Println("Error reading grading config:", err)
+			return
+		}
+		opts.Grading = &gradingCfg
+	}
+	if *weightsFile != "" {
+		weights, err := gradebook.LoadWeightsConfig(*weightsFile)
+		if err != nil {
+			fmt.Println("Error reading weights:", err)
+			return
+		}
+		opts.Weights = &weights
+	}
+
+	d, err := gradebook.Compare(versions[0], versions[1], opts)
+	if err != nil {
+		fmt.Println("Error comparing gradebooks:", err)
+		return
+	}
+
+	out := os.Stdout
+	if *output != "-" {
+		if out, err = os.Create(*output); err != nil {
+			fmt.Println("Error writing diff:", err)
+			return
+		}
+		defer out.Close()
+	}
+	if *format == "text" {
+		gradebook.PrintDiff(out, d)
+		return
+	}
+	enc := json.NewEncoder(out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(d); err != nil {
+		fmt.Println("Error writing diff:", err)
+	}
+}
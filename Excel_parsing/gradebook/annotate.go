@@ -0,0 +1,433 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Columns appended to an annotated workbook.
+var annotationHeader = []string{"Rank", "Branch Rank", "Percentile", "Grade", "Recomputed Total", "Validation Flags"}
+
+// AnnotateOptions control how a source workbook is annotated. Report
+// ranks, grades and recomputes totals as for Generate; its Rank.Top
+// students are highlighted. Columns finds the header rows again and
+// Validation supplies the flags for rows with issues.
+type AnnotateOptions struct {
+	Report     Options
+	Columns    ColumnConfig
+	Validation ValidationReport
+}
+
+// annotation is what gets appended to one source row.
+type annotation struct {
+	student *RankedStudent
+	branch  []string
+	flags   []string
+}
+
+// Annotate appends ranks, grades, recomputed totals and validation flags
+// to every gradebook sheet of the XLSX workbook src, which students were
+// loaded from, and saves it as dest; dest may be src itself. The original
+// columns and row order are kept as they are; a copy of each annotated
+// sheet with the students sorted by Total is added next to it. The header
+// and ID columns are frozen, and conditional formatting highlights the top
+// students and those who fail (grade E or NC).
+func Annotate(src, dest string, students []Student, opts AnnotateOptions) error {
+	if ext := strings.ToLower(filepath.Ext(src)); ext != ".xlsx" && ext != ".xlsm" {
+		return fmt.Errorf("%s: only XLSX workbooks can be annotated", src)
+	}
+	reportOpts := opts.Report
+	top := reportOpts.Rank.Top
+	reportOpts.Rank.Top = 0
+	reportOpts.Charts, reportOpts.Anomalies, reportOpts.Correlations = false, nil, false
+	report, err := Generate(students, reportOpts)
+	if err != nil {
+		return err
+	}
+
+	name := filepath.Clean(src)
+	rowKey := func(sheet string, row int) string { return sheet + "\x00" + strconv.Itoa(row) }
+	notes := make(map[string]*annotation)
+	note := func(sheet string, row int) *annotation {
+		k := rowKey(sheet, row)
+		if notes[k] == nil {
+			notes[k] = &annotation{}
+		}
+		return notes[k]
+	}
+	sheets := make(map[string]bool)
+	for i, r := range report.OverallTopStudents {
+		if r.Source == name {
+			note(r.Sheet, r.Row).student = &report.OverallTopStudents[i]
+			sheets[r.Sheet] = true
+		}
+	}
+	for _, b := range sortedRankingKeys(report.BranchRankings) {
+		for _, r := range report.BranchRankings[b] {
+			if r.Source == name {
+				a := note(r.Sheet, r.Row)
+				if r.Unranked {
+					a.branch = append(a.branch, b+": unranked")
+				} else {
+					a.branch = append(a.branch, fmt.Sprintf("%s: %d", b, r.Rank))
+				}
+			}
+		}
+	}
+	for _, is := range opts.Validation.Issues {
+		if is.File == name {
+			msg := is.Message
+			if is.Column != "" {
+				msg = is.Column + ": " + msg
+			}
+			a := note(is.Sheet, is.Row)
+			a.flags = append(a.flags, msg)
+			sheets[is.Sheet] = true
+		}
+	}
+	for _, q := range opts.Validation.Quarantined {
+		if q.File == name {
+			a := note(q.Sheet, q.Row)
+			a.flags = append([]string{"left out of the report"}, a.flags...)
+		}
+	}
+
+	f, err := excelize.OpenFile(src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	graded := report.Grading != nil
+	for _, sheet := range f.GetSheetList() {
+		if !sheets[sheet] {
+			continue
+		}
+		notesFor := func(row int) *annotation { return notes[rowKey(sheet, row)] }
+		if err := annotateSheet(f, sheet, notesFor, opts.Columns, top, graded, sheets); err != nil {
+			return fmt.Errorf("%s: %w", sheet, err)
+		}
+	}
+
+	if dest != src {
+		return f.SaveAs(dest)
+	}
+	// Write next to the original and swap it in, so that a failed save
+	// leaves the source untouched.
+	tmp, err := os.CreateTemp(filepath.Dir(src), ".annotate-*"+filepath.Ext(src))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if info, err := os.Stat(src); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			tmp.Close()
+			return err
+		}
+	}
+	if err := f.Write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), src)
+}
+
+// annotateSheet appends the annotation columns to one gradebook sheet and
+// writes its sorted view. Sheets in gradebooks are never replaced by a view.
+func annotateSheet(f *excelize.File, sheet string, notesFor func(row int) *annotation, cfg ColumnConfig, top int, graded bool, gradebooks map[string]bool) error {
+	rows, err := f.GetRows(sheet, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+	cols, err := findColumns(rows, cfg)
+	if err != nil {
+		return err
+	}
+	headerRow := cols.HeaderRow + 1
+	lastCol, lastRow := 0, headerRow
+	for i, row := range rows {
+		lastCol = max(lastCol, len(row))
+		if i+1 > headerRow && (notesFor(i+1) != nil || !isBlankRow(row)) {
+			lastRow = i + 1
+		}
+	}
+	if lastRow == headerRow {
+		return nil
+	}
+	// A workbook annotated before gets its columns replaced, not repeated.
+	if i := annotatedFrom(rows[cols.HeaderRow]); i >= 0 {
+		lastCol = i
+		for r := headerRow + 1; r <= lastRow; r++ {
+			for c := range annotationHeader {
+				if err := f.SetCellValue(sheet, cellName(lastCol+1+c, r), nil); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	headerStyle, _ := f.GetCellStyle(sheet, cellName(lastCol, headerRow))
+	for i, h := range annotationHeader {
+		cell := cellName(lastCol+1+i, headerRow)
+		if err := f.SetCellStr(sheet, cell, h); err != nil {
+			return err
+		}
+		if err := f.SetCellStyle(sheet, cell, cell, headerStyle); err != nil {
+			return err
+		}
+	}
+	for row := headerRow + 1; row <= lastRow; row++ {
+		a := notesFor(row)
+		if a == nil {
+			continue
+		}
+		for j, v := range a.values() {
+			if v == nil {
+				continue
+			}
+			if err := f.SetCellValue(sheet, cellName(lastCol+1+j, row), v); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Freeze the header and the ID columns.
+	xSplit := 0
+	for _, field := range []string{FieldClassNo, FieldEmplid, FieldCampusID} {
+		if i, ok := cols.Fields[field]; ok {
+			xSplit = max(xSplit, i+1)
+		}
+	}
+	if err := highlight(f, sheet, headerRow+1, lastRow, lastCol, top, graded); err != nil {
+		return err
+	}
+	if err := freeze(f, sheet, headerRow, xSplit); err != nil {
+		return err
+	}
+
+	// Sort the data rows by Total, best first; rows that are not students
+	// (quarantined or blank) keep their order at the bottom, and blank rows
+	// without flags are left out.
+	var order []int
+	for r := headerRow + 1; r <= lastRow; r++ {
+		if notesFor(r) != nil || (r-1 < len(rows) && !isBlankRow(rows[r-1])) {
+			order = append(order, r)
+		}
+	}
+	total := func(r int) float64 {
+		if a := notesFor(r); a != nil && a.student != nil {
+			return a.student.Total
+		}
+		return math.Inf(-1)
+	}
+	sort.SliceStable(order, func(i, j int) bool { return total(order[i]) > total(order[j]) })
+
+	view, err := newSortedView(f, sheet, gradebooks)
+	if err != nil {
+		return err
+	}
+	width := lastCol + len(annotationHeader)
+	if err := copyRows(f, sheet, view, append([]int{headerRow}, order...), width); err != nil {
+		return err
+	}
+	if err := highlight(f, view, 2, len(order)+1, lastCol, top, graded); err != nil {
+		return err
+	}
+	return freeze(f, view, 1, xSplit)
+}
+
+// values are the annotation columns for a row, nil where there is nothing
+// to write.
+func (a *annotation) values() []interface{} {
+	values := make([]interface{}, len(annotationHeader))
+	if s := a.student; s != nil {
+		values[0], values[2] = s.Rank, s.Percentile
+		if s.Unranked {
+			values[0], values[2] = "unranked", nil
+		}
+		if s.Grade != "" {
+			values[3] = s.Grade
+		}
+		if s.RecomputedTotal != nil {
+			values[4] = *s.RecomputedTotal
+		}
+	}
+	if len(a.branch) > 0 {
+		values[1] = strings.Join(a.branch, "; ")
+	}
+	if len(a.flags) > 0 {
+		values[5] = strings.Join(a.flags, "; ")
+	}
+	return values
+}
+
+// highlight sets the conditional formatting of the data rows first to
+// last, whose annotation columns start after lastCol.
+func highlight(f *excelize.File, sheet string, first, last, lastCol, top int, graded bool) error {
+	width := lastCol + len(annotationHeader)
+	rangeRef := cellName(1, first) + ":" + cellName(width, last)
+	rankCell := "$" + columnName(lastCol+1) + strconv.Itoa(first)
+	gradeCell := "$" + columnName(lastCol+4) + strconv.Itoa(first)
+	var rules []excelize.ConditionalFormatOptions
+	if top > 0 {
+		style, err := f.NewConditionalStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"C6EFCE"}}})
+		if err != nil {
+			return err
+		}
+		rules = append(rules, excelize.ConditionalFormatOptions{Type: "formula", Format: &style,
+			Criteria: fmt.Sprintf(`AND(%s<>"",%s<=%d)`, rankCell, rankCell, top)})
+	}
+	if graded {
+		style, err := f.NewConditionalStyle(&excelize.Style{Fill: excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFC7CE"}}})
+		if err != nil {
+			return err
+		}
+		rules = append(rules, excelize.ConditionalFormatOptions{Type: "formula", Format: &style,
+			Criteria: fmt.Sprintf(`OR(%s="%s",%s="E")`, gradeCell, gradeNC, gradeCell)})
+	}
+	if err := f.UnsetConditionalFormat(sheet, rangeRef); err != nil {
+		return err
+	}
+	if len(rules) == 0 {
+		return nil
+	}
+	return f.SetConditionalFormat(sheet, rangeRef, rules)
+}
+
+// freeze freezes the first headerRow rows and xSplit columns.
+func freeze(f *excelize.File, sheet string, headerRow, xSplit int) error {
+	pane := "bottomRight"
+	if xSplit == 0 {
+		pane = "bottomLeft"
+	}
+	return f.SetPanes(sheet, &excelize.Panes{
+		Freeze: true, XSplit: xSplit, YSplit: headerRow,
+		TopLeftCell: cellName(xSplit+1, headerRow+1), ActivePane: pane,
+	})
+}
+
+// newSortedView adds an empty sheet for the sorted copy of sheet, named
+// "<sheet> by Total". The view left by an earlier run is replaced, unless
+// that name is one of the gradebooks, in which case the view gets a new
+// name.
+func newSortedView(f *excelize.File, sheet string, gradebooks map[string]bool) (string, error) {
+	name := xlsxSheetName(sheet+" by Total", make(map[string]bool))
+	used := make(map[string]bool)
+	for _, s := range f.GetSheetList() {
+		if strings.EqualFold(s, name) && !gradebooks[s] {
+			if err := f.DeleteSheet(s); err != nil {
+				return "", err
+			}
+			continue
+		}
+		used[strings.ToLower(s)] = true
+	}
+	name = xlsxSheetName(name, used)
+	if _, err := f.NewSheet(name); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+// copyRows copies the first width cells of the given rows of src to
+// consecutive rows of dest from the first, with their styles and column
+// widths. Formulas are copied as their last computed values: they refer to
+// other cells by position, which the copy does not keep.
+func copyRows(f *excelize.File, src, dest string, rows []int, width int) error {
+	values, err := f.GetRows(src, excelize.Options{RawCellValue: true})
+	if err != nil {
+		return err
+	}
+	for c := 1; c <= width; c++ {
+		w, err := f.GetColWidth(src, columnName(c))
+		if err != nil {
+			return err
+		}
+		if err := f.SetColWidth(dest, columnName(c), columnName(c), w); err != nil {
+			return err
+		}
+	}
+	for i, old := range rows {
+		for c := 1; c <= width; c++ {
+			from, to := cellName(c, old), cellName(c, i+1)
+			value := ""
+			if old-1 < len(values) && c-1 < len(values[old-1]) {
+				value = values[old-1][c-1]
+			}
+			typ, err := f.GetCellType(src, from)
+			if err != nil {
+				return err
+			}
+			style, err := f.GetCellStyle(src, from)
+			if err != nil {
+				return err
+			}
+			switch {
+			case value == "":
+			case typ == excelize.CellTypeBool:
+				err = f.SetCellBool(dest, to, value == "1" || strings.EqualFold(value, "true"))
+			case typ == excelize.CellTypeSharedString || typ == excelize.CellTypeInlineString:
+				err = f.SetCellStr(dest, to, value)
+			default:
+				if v, perr := strconv.ParseFloat(value, 64); perr == nil {
+					err = f.SetCellFloat(dest, to, v, -1, 64)
+				} else {
+					err = f.SetCellStr(dest, to, value)
+				}
+			}
+			if err == nil {
+				err = f.SetCellStyle(dest, to, to, style)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// annotatedFrom returns the index of the first annotation column in
+// header, or -1 when the sheet has not been annotated.
+func annotatedFrom(header []string) int {
+	for i := range header {
+		if i+len(annotationHeader) > len(header) {
+			break
+		}
+		match := true
+		for j, h := range annotationHeader {
+			match = match && header[i+j] == h
+		}
+		if match {
+			return i
+		}
+	}
+	return -1
+}
+
+func cellName(col, row int) string {
+	name, _ := excelize.CoordinatesToCellName(col, row)
+	return name
+}
+
+func columnName(col int) string {
+	name, _ := excelize.ColumnNumberToName(col)
+	return name
+}
+
+func sortedRankingKeys(m map[string][]RankedStudent) []string {
+	var keys []string
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,175 @@
+package gradebook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// annotationStudents have their best Total last, so a sorted view differs
+// from the source order.
+func annotationStudents(first int) []Student {
+	students := syntheticStudents(first + 3)[first:]
+	for i := range students {
+		students[i].Total = float64(40 + 10*(first+i))
+	}
+	return students
+}
+
+func loadForAnnotation(t *testing.T, paths ...string) ([]Student, ValidationReport) {
+	t.Helper()
+	l, err := NewLoader(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	students, err := l.LoadFiles(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return students, l.Validation()
+}
+
+func annotate(t *testing.T, path string, students []Student, v ValidationReport) *excelize.File {
+	t.Helper()
+	opts := AnnotateOptions{Report: Options{Rank: DefaultRankOptions()}, Validation: v}
+	if err := Annotate(path, path, students, opts); err != nil {
+		t.Fatal(err)
+	}
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { f.Close() })
+	return f
+}
+
+// sheetColumn returns the values below the header in the column headed name.
+func sheetColumn(t *testing.T, f *excelize.File, sheet, name string) []string {
+	t.Helper()
+	rows, err := f.GetRows(sheet)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for c, h := range rows[0] {
+		if h != name {
+			continue
+		}
+		var values []string
+		for _, row := range rows[1:] {
+			v := ""
+			if c < len(row) {
+				v = row[c]
+			}
+			values = append(values, v)
+		}
+		return values
+	}
+	t.Fatalf("%s has no %s column", sheet, name)
+	return nil
+}
+
+func emplids(students []Student) []string {
+	var ids []string
+	for _, s := range students {
+		ids = append(ids, s.Emplid)
+	}
+	return ids
+}
+
+func TestAnnotateKeepsSourceOrderAndFormulas(t *testing.T) {
+	students := annotationStudents(0)
+	path := filepath.Join(t.TempDir(), "marks.xlsx")
+	writeGradebook(t, path, students)
+	// A formula reaching into the next row must still point there.
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.SetCellStr("Sheet1", "K1", "Next Total")
+	f.SetCellFormula("Sheet1", "K2", "J3")
+	f.SetCellFormula("Sheet1", "M1", "SUM(J2:J4)")
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	loaded, v := loadForAnnotation(t, path)
+	f = annotate(t, path, loaded, v)
+
+	if got := sheetColumn(t, f, "Sheet1", "Emplid"); !reflect.DeepEqual(got, emplids(students)) {
+		t.Errorf("source rows are %v, want them left in order %v", got, emplids(students))
+	}
+	if got := sheetColumn(t, f, "Sheet1", "Rank"); !reflect.DeepEqual(got, []string{"3", "2", "1"}) {
+		t.Errorf("ranks %v, want 3 2 1", got)
+	}
+	for cell, want := range map[string]string{"K2": "J3", "M1": "SUM(J2:J4)"} {
+		if got, _ := f.GetCellFormula("Sheet1", cell); got != want {
+			t.Errorf("%s formula %q, want %q", cell, got, want)
+		}
+	}
+
+	view := "Sheet1 by Total"
+	want := []string{students[2].Emplid, students[1].Emplid, students[0].Emplid}
+	if got := sheetColumn(t, f, view, "Emplid"); !reflect.DeepEqual(got, want) {
+		t.Errorf("sorted view %v, want %v", got, want)
+	}
+	if got := sheetColumn(t, f, view, "Rank"); !reflect.DeepEqual(got, []string{"1", "2", "3"}) {
+		t.Errorf("sorted view ranks %v, want 1 2 3", got)
+	}
+	if formula, _ := f.GetCellFormula(view, "K2"); formula != "" {
+		t.Errorf("sorted view copied formula %q", formula)
+	}
+}
+
+func TestAnnotateTwice(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marks.xlsx")
+	writeGradebook(t, path, annotationStudents(0))
+	loaded, v := loadForAnnotation(t, path)
+	annotate(t, path, loaded, v)
+	f := annotate(t, path, loaded, v)
+
+	if got, want := f.GetSheetList(), []string{"Sheet1", "Sheet1 by Total"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("sheets %v, want %v", got, want)
+	}
+	header, err := f.GetRows("Sheet1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, want := len(header[0]), len(gradebookHeader)+len(annotationHeader); got != want {
+		t.Errorf("%d columns after annotating twice, want %d", got, want)
+	}
+}
+
+// TestAnnotateSameFileNames checks that workbooks with the same name in
+// different directories only get their own students.
+func TestAnnotateSameFileNames(t *testing.T) {
+	dir := t.TempDir()
+	first, second := annotationStudents(0), annotationStudents(3)
+	paths := []string{filepath.Join(dir, "sem1", "marks.xlsx"), filepath.Join(dir, "sem2", "marks.xlsx")}
+	for i, group := range [][]Student{first, second} {
+		if err := os.Mkdir(filepath.Dir(paths[i]), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		writeGradebook(t, paths[i], group)
+	}
+	loaded, v := loadForAnnotation(t, paths...)
+	if loaded[0].Source != filepath.Clean(paths[0]) {
+		t.Errorf("Source %q, want the full path %q", loaded[0].Source, paths[0])
+	}
+
+	// Together the students rank 1 to 6; sem2 holds the top three.
+	for i, tt := range []struct {
+		ranks []string
+	}{
+		{[]string{"6", "5", "4"}},
+		{[]string{"3", "2", "1"}},
+	} {
+		f := annotate(t, paths[i], loaded, v)
+		if got := sheetColumn(t, f, "Sheet1", "Rank"); !reflect.DeepEqual(got, tt.ranks) {
+			t.Errorf("%s ranks %v, want %v", paths[i], got, tt.ranks)
+		}
+	}
+}
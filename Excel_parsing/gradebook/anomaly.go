@@ -0,0 +1,374 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Kinds of anomaly.
+const (
+	AnomalyCompreJump   = "compre_jump"
+	AnomalyExtremeSplit = "extreme_split"
+	AnomalySectionMean  = "section_mean"
+	AnomalyIdentical    = "identical_scores"
+)
+
+// AnomalyOptions tune the anomaly checks. Threshold is the Score an
+// anomaly needs to be reported, NearZero the fraction of a component's
+// maximum counted as near zero. MaxMarks gives full marks per component;
+// components without one use the class's highest mark instead.
+type AnomalyOptions struct {
+	Threshold float64
+	NearZero  float64
+	MaxMarks  map[string]float64
+}
+
+const (
+	defaultAnomalyThreshold = 2
+	defaultNearZero         = 0.05
+	// maxAnomalyScore caps scores where a probability underflows.
+	maxAnomalyScore = 8.0
+)
+
+// Anomaly is one unusual student, group of students or section. Score is
+// in standard deviations for every kind, so that different kinds rank
+// against each other; Explanation says what was found in words.
+type Anomaly struct {
+	Kind        string   `json:"kind"`
+	Score       float64  `json:"score"`
+	Section     string   `json:"section,omitempty"`
+	Component   string   `json:"component,omitempty"`
+	Students    []string `json:"students,omitempty"`
+	Explanation string   `json:"explanation"`
+}
+
+// AnomalyReport lists anomalies from the most to the least unusual.
+type AnomalyReport struct {
+	Threshold float64   `json:"threshold"`
+	Anomalies []Anomaly `json:"anomalies"`
+}
+
+// DetectAnomalies looks for score patterns worth re-checking:
+//
+//   - Compre marks far from what the class trend predicts from PreCompre.
+//   - Full marks in one component alongside near-zero marks in another.
+//   - Sections whose mean in a component is far from the rest of the course.
+//   - Students with identical marks in every component.
+func DetectAnomalies(students []Student, opts AnomalyOptions) AnomalyReport {
+	if opts.Threshold <= 0 {
+		opts.Threshold = defaultAnomalyThreshold
+	}
+	if opts.NearZero <= 0 {
+		opts.NearZero = defaultNearZero
+	}
+	report := AnomalyReport{Threshold: opts.Threshold, Anomalies: []Anomaly{}}
+
+	var found []Anomaly
+	found = append(found, compreJumps(students)...)
+	found = append(found, extremeSplits(students, opts)...)
+	found = append(found, sectionDeviations(students)...)
+	found = append(found, identicalScores(students)...)
+	for _, a := range found {
+		if a.Score >= opts.Threshold {
+			report.Anomalies = append(report.Anomalies, a)
+		}
+	}
+	sort.SliceStable(report.Anomalies, func(i, j int) bool {
+		a, b := report.Anomalies[i], report.Anomalies[j]
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		if a.Kind != b.Kind {
+			return a.Kind < b.Kind
+		}
+		return strings.Join(a.Students, ",") < strings.Join(b.Students, ",")
+	})
+	return report
+}
+
+// scoredComponents are the components marked on their own, leaving out
+// the aggregates, that anyone in the class scored in.
+func scoredComponents(students []Student) []string {
+	var out []string
+	for _, c := range statComponents(students) {
+		if c == FieldPreCompre || c == FieldTotal || c == FieldRecomputedTotal || c == FieldNormalizedTotal {
+			continue
+		}
+		for _, v := range componentValues(students, c) {
+			if v != 0 {
+				out = append(out, c)
+				break
+			}
+		}
+	}
+	return out
+}
+
+// compreJumps fits Compre against PreCompre by least squares and flags
+// students whose Compre is far off the line, measured in standard
+// deviations of the residuals.
+func compreJumps(students []Student) []Anomaly {
+	fit := scatter(students, FieldPreCompre, FieldCompre)
+	if len(fit.Points) < 3 || fit.Slope == 0 {
+		return nil
+	}
+	residuals := make([]float64, len(fit.Points))
+	for i, p := range fit.Points {
+		residuals[i] = p.Y - (fit.Slope*p.X + fit.Intercept)
+	}
+	_, sd := meanSD(residuals)
+	if sd == 0 {
+		return nil
+	}
+
+	sections := make(map[string]string, len(students))
+	for _, s := range students {
+		sections[s.Emplid] = s.ClassNo
+	}
+	var out []Anomaly
+	for i, p := range fit.Points {
+		z := residuals[i] / sd
+		direction := "above"
+		if z < 0 {
+			direction = "below"
+		}
+		out = append(out, Anomaly{
+			Kind:      AnomalyCompreJump,
+			Score:     math.Abs(z),
+			Section:   sections[p.Emplid],
+			Component: FieldCompre,
+			Students:  []string{p.Emplid},
+			Explanation: fmt.Sprintf("Compre %.2f is %.2f %s the %.2f expected from PreCompre %.2f (class trend Compre = %.3f × PreCompre %+.2f), %.1f SD off the trend",
+				p.Y, math.Abs(residuals[i]), direction, p.Y-residuals[i], p.X, fit.Slope, fit.Intercept, math.Abs(z)),
+		})
+	}
+	return out
+}
+
+// extremeSplits flags students with full marks in one component and near
+// zero in another. The score is the smaller of the two deviations from
+// the class mean, so both marks have to be unusual.
+func extremeSplits(students []Student, opts AnomalyOptions) []Anomaly {
+	type scale struct{ max, mean, sd float64 }
+	scales := make(map[string]scale)
+	components := scoredComponents(students)
+	for _, c := range components {
+		values := componentValues(students, c)
+		mean, sd := meanSD(values)
+		if sd == 0 {
+			continue
+		}
+		max := opts.MaxMarks[c]
+		if max <= 0 {
+			max = values[0]
+			for _, v := range values {
+				max = math.Max(max, v)
+			}
+		}
+		scales[c] = scale{max: max, mean: mean, sd: sd}
+	}
+
+	var out []Anomaly
+	for _, s := range students {
+		high, low := "", ""
+		var hv, lv, zHigh, zLow float64
+		for _, c := range components {
+			sc, ok := scales[c]
+			if !ok {
+				continue
+			}
+			// A mark left out by policy is neither full nor near zero.
+			v, ok := s.Component(c)
+			if !ok {
+				continue
+			}
+			z := (v - sc.mean) / sc.sd
+			switch {
+			case v >= sc.max && (high == "" || z > zHigh):
+				high, hv, zHigh = c, v, z
+			case v <= opts.NearZero*sc.max && (low == "" || z < zLow):
+				low, lv, zLow = c, v, z
+			}
+		}
+		if high == "" || low == "" {
+			continue
+		}
+		out = append(out, Anomaly{
+			Kind:     AnomalyExtremeSplit,
+			Score:    math.Min(zHigh, -zLow),
+			Section:  s.ClassNo,
+			Students: []string{s.Emplid},
+			Explanation: fmt.Sprintf("full marks in %s (%.2f of %g, %+.1f SD) but %.2f of %g in %s (%+.1f SD)",
+				high, hv, scales[high].max, zHigh, lv, scales[low].max, low, zLow),
+		})
+	}
+	return out
+}
+
+// sectionDeviations compares each section's mean in every component and
+// in Total with the rest of the course. The score is the difference in
+// standard errors; the explanation also gives it in standard deviations.
+func sectionDeviations(students []Student) []Anomaly {
+	bySection := make(map[string][]Student)
+	for _, s := range students {
+		bySection[s.ClassNo] = append(bySection[s.ClassNo], s)
+	}
+	if len(bySection) < 2 {
+		return nil
+	}
+	var sections []string
+	for name := range bySection {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+
+	var out []Anomaly
+	for _, c := range append(scoredComponents(students), FieldTotal) {
+		all := componentValues(students, c)
+		_, sd := meanSD(all)
+		if sd == 0 {
+			continue
+		}
+		var sum float64
+		for _, v := range all {
+			sum += v
+		}
+		for _, name := range sections {
+			values := componentValues(bySection[name], c)
+			n, rest := len(values), len(all)-len(values)
+			if n < 2 || rest < 2 {
+				continue
+			}
+			var sectionSum float64
+			for _, v := range values {
+				sectionSum += v
+			}
+			mean := sectionSum / float64(n)
+			restMean := (sum - sectionSum) / float64(rest)
+			z := (mean - restMean) / (sd * math.Sqrt(1/float64(n)+1/float64(rest)))
+			verdict := "higher"
+			if z < 0 {
+				verdict = "lower"
+			}
+			out = append(out, Anomaly{
+				Kind:      AnomalySectionMean,
+				Score:     math.Abs(z),
+				Section:   name,
+				Component: c,
+				Explanation: fmt.Sprintf("section %s averages %.2f in %s against %.2f in the rest of the course (%d and %d students), %.2f SD %s",
+					name, mean, c, restMean, n, rest, math.Abs(mean-restMean)/sd, verdict),
+			})
+		}
+	}
+	return out
+}
+
+// identicalScores groups students whose marks agree in every component.
+// The chance of such a match is estimated from how common each mark is in
+// the rest of the class and expressed as the equivalent normal deviate. Students with
+// no marks at all are left out; they are usually absentees. A mark left
+// out by policy matches only another such mark and counts toward no
+// frequency.
+func identicalScores(students []Student) []Anomaly {
+	components := scoredComponents(students)
+	if len(components) == 0 || len(students) < 2 {
+		return nil
+	}
+	freq := make(map[string]map[float64]int, len(components))
+	marked := make(map[string]int, len(components))
+	for _, c := range components {
+		freq[c] = make(map[float64]int)
+	}
+	groups := make(map[string][]Student)
+	var keys []string
+	for _, s := range students {
+		var key strings.Builder
+		zero := true
+		for _, c := range components {
+			v, ok := s.Component(c)
+			if !ok {
+				key.WriteString("-;")
+				continue
+			}
+			freq[c][v]++
+			marked[c]++
+			zero = zero && v == 0
+			fmt.Fprintf(&key, "%g;", v)
+		}
+		if zero {
+			continue
+		}
+		k := key.String()
+		if _, ok := groups[k]; !ok {
+			keys = append(keys, k)
+		}
+		groups[k] = append(groups[k], s)
+	}
+
+	n := float64(len(students))
+	var out []Anomaly
+	for _, k := range keys {
+		group := groups[k]
+		if len(group) < 2 {
+			continue
+		}
+		// A student matches the group on a component with the chance that
+		// mark has outside the group, counting a mark seen nowhere else once.
+		k := float64(len(group))
+		logP := 0.0
+		var marks []string
+		for _, c := range components {
+			v, ok := group[0].Component(c)
+			if !ok {
+				marks = append(marks, c+" -")
+				continue
+			}
+			logP += math.Log((float64(freq[c][v]) - k + 1) / float64(marked[c]))
+			marks = append(marks, fmt.Sprintf("%s %.2f", c, v))
+		}
+		// Expected number of k-way matches among n students, then the
+		// chance of at least one.
+		lnN, _ := math.Lgamma(n + 1)
+		lnK, _ := math.Lgamma(k + 1)
+		lnNK, _ := math.Lgamma(n - k + 1)
+		expected := math.Exp(lnN - lnK - lnNK + (k-1)*logP)
+		chance := -math.Expm1(-expected)
+		score := float64(maxAnomalyScore)
+		if chance > 0 {
+			score = math.Min(maxAnomalyScore, math.Sqrt2*math.Erfinv(1-2*chance))
+		}
+		emplids := make([]string, len(group))
+		for i, s := range group {
+			emplids[i] = s.Emplid
+		}
+		section := group[0].ClassNo
+		for _, s := range group {
+			if s.ClassNo != section {
+				section = ""
+			}
+		}
+		out = append(out, Anomaly{
+			Kind:     AnomalyIdentical,
+			Score:    score,
+			Section:  section,
+			Students: emplids,
+			Explanation: fmt.Sprintf("%d students share every mark (%s); %.2g such matches expected by chance in a class of %d",
+				len(group), strings.Join(marks, ", "), expected, len(students)),
+		})
+	}
+	return out
+}
+
+func anomalyTable(r *AnomalyReport) Table {
+	t := Table{
+		Name:   "anomalies",
+		Title:  fmt.Sprintf("Anomalies (%d scoring %g or more)", len(r.Anomalies), r.Threshold),
+		Header: []string{"#", "Score", "Kind", "Section", "Component", "Students", "Explanation"},
+	}
+	for i, a := range r.Anomalies {
+		t.Rows = append(t.Rows, []interface{}{i + 1, a.Score, a.Kind, a.Section, a.Component, strings.Join(a.Students, ", "), a.Explanation})
+	}
+	return t
+}
@@ -0,0 +1,183 @@
+package gradebook
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// anomalyClass is a class of 60 in three sections that share the same
+// spread of marks, and whose Compre follows PreCompre closely, so that
+// nothing in it is unusual.
+func anomalyClass() []Student {
+	students := make([]Student, 60)
+	for i := range students {
+		sec, j := i%3, i/3
+		s := Student{
+			ClassNo:    fmt.Sprint(1 + sec),
+			Emplid:     fmt.Sprint(100000 + i),
+			Quiz:       1 + float64(j)*1.4,
+			MidSem:     3 + float64((j*7+sec*5)%20)*3.5,
+			LabTest:    2 + float64((j*3+sec*11)%20)*1.3,
+			WeeklyLabs: 5 + float64((j*13+sec*3)%20)*1.2,
+		}
+		s.PreCompre = s.Quiz + s.MidSem + s.LabTest + s.WeeklyLabs
+		s.Compre = s.PreCompre/2 + float64((j*9+sec)%7) - 3
+		s.Total = s.PreCompre + s.Compre
+		students[i] = s
+	}
+	return students
+}
+
+func TestDetectAnomalies(t *testing.T) {
+	tests := []struct {
+		name     string
+		change   func(students []Student)
+		opts     AnomalyOptions
+		kind     string
+		students []string
+		section  string
+		absent   bool
+	}{
+		{
+			name:   "nothing unusual",
+			change: func([]Student) {},
+			absent: true,
+		},
+		{
+			name:     "compre jump",
+			change:   func(s []Student) { s[5].Compre += 60 },
+			kind:     AnomalyCompreJump,
+			students: []string{"100005"},
+			section:  "3",
+		},
+		{
+			name: "extreme split",
+			change: func(s []Student) {
+				s[7].Quiz, s[7].MidSem = 30, 0.5
+				s[7].PreCompre = s[7].Quiz + s[7].MidSem + s[7].LabTest + s[7].WeeklyLabs
+			},
+			opts:     AnomalyOptions{Threshold: 1.5, MaxMarks: map[string]float64{FieldQuiz: 30, FieldMidSem: 75}},
+			kind:     AnomalyExtremeSplit,
+			students: []string{"100007"},
+			section:  "2",
+		},
+		{
+			name: "section mean",
+			change: func(s []Student) {
+				for i := range s {
+					if s[i].ClassNo == "1" {
+						s[i].MidSem += 40
+					}
+				}
+			},
+			kind:    AnomalySectionMean,
+			section: "1",
+		},
+		{
+			name: "identical marks",
+			change: func(s []Student) {
+				id, class := s[11].Emplid, s[11].ClassNo
+				s[11] = s[10]
+				s[11].Emplid, s[11].ClassNo = id, class
+			},
+			kind:     AnomalyIdentical,
+			students: []string{"100010", "100011"},
+		},
+	}
+	for _, tt := range tests {
+		students := anomalyClass()
+		tt.change(students)
+		report := DetectAnomalies(students, tt.opts)
+		if tt.absent {
+			if len(report.Anomalies) != 0 {
+				t.Errorf("%s: found %+v", tt.name, report.Anomalies)
+			}
+			continue
+		}
+		found := false
+		for _, a := range report.Anomalies {
+			if a.Kind == tt.kind && (tt.students == nil || reflect.DeepEqual(a.Students, tt.students)) && a.Section == tt.section {
+				found = true
+				if a.Explanation == "" || a.Score < report.Threshold {
+					t.Errorf("%s: %+v", tt.name, a)
+				}
+			}
+		}
+		if !found {
+			t.Errorf("%s: no %s anomaly for %v in section %q among %+v", tt.name, tt.kind, tt.students, tt.section, report.Anomalies)
+		}
+	}
+}
+
+// TestIdenticalScoresSkipsAbsentees checks that students with no marks at
+// all are not reported as copying each other.
+func TestIdenticalScoresSkipsAbsentees(t *testing.T) {
+	students := anomalyClass()
+	for _, i := range []int{3, 4} {
+		id := students[i].Emplid
+		students[i] = Student{Emplid: id, ClassNo: "1"}
+	}
+	for _, a := range identicalScores(students) {
+		t.Errorf("absentees reported: %+v", a)
+	}
+}
+
+func TestDetectAnomaliesOrder(t *testing.T) {
+	students := anomalyClass()
+	students[5].Compre += 60
+	students[20].Compre -= 30
+	report := DetectAnomalies(students, AnomalyOptions{})
+	for i := 1; i < len(report.Anomalies); i++ {
+		if report.Anomalies[i].Score > report.Anomalies[i-1].Score {
+			t.Errorf("anomaly %d scores %g, above %g before it", i, report.Anomalies[i].Score, report.Anomalies[i-1].Score)
+		}
+	}
+	table := anomalyTable(&report)
+	if len(table.Rows) != len(report.Anomalies) || !strings.Contains(table.Title, "scoring 2 or more") {
+		t.Errorf("table %q with %d rows", table.Title, len(table.Rows))
+	}
+}
+
+// TestAnomaliesSkipExcludedMarks checks that a mark left out by policy is
+// not read as a zero: an absentee with full marks elsewhere is no extreme
+// split, and does not match a student who really scored zero.
+func TestAnomaliesSkipExcludedMarks(t *testing.T) {
+	absent := map[string]SpecialMark{FieldCompre: {Marker: "AB", State: MarkAbsent, Policy: PolicyExclude}}
+	tests := []struct {
+		name   string
+		change func(s []Student)
+		opts   AnomalyOptions
+		kind   string
+	}{
+		{
+			name: "full marks beside an absence",
+			change: func(s []Student) {
+				s[7].Quiz, s[7].Compre, s[7].Special = 30, 0, absent
+			},
+			opts: AnomalyOptions{Threshold: 0.5, MaxMarks: map[string]float64{FieldQuiz: 30, FieldCompre: 100}},
+			kind: AnomalyExtremeSplit,
+		},
+		{
+			name: "absence beside a real zero",
+			change: func(s []Student) {
+				id, class := s[11].Emplid, s[11].ClassNo
+				s[11] = s[10]
+				s[11].Emplid, s[11].ClassNo = id, class
+				s[10].Compre, s[10].Special = 0, absent
+				s[11].Compre = 0
+			},
+			kind: AnomalyIdentical,
+		},
+	}
+	for _, tt := range tests {
+		students := anomalyClass()
+		tt.change(students)
+		for _, a := range DetectAnomalies(students, tt.opts).Anomalies {
+			if a.Kind == tt.kind && (contains(a.Students, "100007") || contains(a.Students, "100010")) {
+				t.Errorf("%s: %+v", tt.name, a)
+			}
+		}
+	}
+}
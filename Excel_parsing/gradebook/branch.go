@@ -0,0 +1,221 @@
+package gradebook
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Ways of grouping dual-degree students, whose campus ID carries two
+// degree codes (e.g. B3A7: M.Sc. Economics with B.E. Computer Science).
+const (
+	DualFirst  = "first"
+	DualSecond = "second"
+	DualBoth   = "both"
+)
+
+var DualDegreeModes = []string{DualFirst, DualSecond, DualBoth}
+
+// The default pattern splits 2023A7PS0001P into year 2023, first degree A7,
+// PS/TS or second degree code PS, serial 0001 and campus P.
+const defaultCampusIDPattern = `^(?P<year>\d{4})(?P<first>[A-Z][A-Z0-9])(?P<second>[A-Z][A-Z0-9])(?P<serial>\d{4})(?P<campus>[A-Z])$`
+
+var defaultBranchNames = map[string]string{
+	"A1": "Chemical Engineering",
+	"A2": "Civil Engineering",
+	"A3": "Electrical and Electronics",
+	"A4": "Mechanical Engineering",
+	"A5": "Pharmacy",
+	"A7": "Computer Science",
+	"A8": "Electronics and Instrumentation",
+	"AA": "Electronics and Communication",
+	"AB": "Manufacturing Engineering",
+	"AD": "Mathematics and Computing",
+	"B1": "M.Sc. Biological Sciences",
+	"B2": "M.Sc. Chemistry",
+	"B3": "M.Sc. Economics",
+	"B4": "M.Sc. Mathematics",
+	"B5": "M.Sc. Physics",
+}
+
+var defaultCampusNames = map[string]string{
+	"P": "Pilani",
+	"G": "Goa",
+	"H": "Hyderabad",
+	"U": "Dubai",
+}
+
+// Second-position codes that mark a single-degree student's track rather
+// than a second degree.
+var singleDegreeTracks = map[string]string{
+	"PS": "Practice School",
+	"TS": "Thesis",
+}
+
+// BranchConfig customises campus ID parsing and naming. Pattern must be a
+// regular expression with named groups year, first and second; serial and
+// campus are optional. Names and Campuses add to or override the built-in
+// tables.
+type BranchConfig struct {
+	Pattern    string            `json:"pattern" yaml:"pattern"`
+	Names      map[string]string `json:"names" yaml:"names"`
+	Campuses   map[string]string `json:"campuses" yaml:"campuses"`
+	DualDegree string            `json:"dual_degree" yaml:"dual_degree"`
+}
+
+// ProgramInfo is what a campus ID says about a student's programme.
+type ProgramInfo struct {
+	Year         string `json:"year"`
+	FirstDegree  string `json:"first_degree"`
+	SecondDegree string `json:"second_degree,omitempty"`
+	Track        string `json:"track,omitempty"`
+	Campus       string `json:"campus,omitempty"`
+}
+
+func (p ProgramInfo) Dual() bool { return p.SecondDegree != "" }
+
+type BranchRegistry struct {
+	pattern  *regexp.Regexp
+	names    map[string]string
+	campuses map[string]string
+	dual     string
+}
+
+func LoadBranchRegistry(path, dualMode string) (*BranchRegistry, error) {
+	var cfg BranchConfig
+	if path != "" {
+		if err := LoadConfigFile(path, &cfg); err != nil {
+			return nil, err
+		}
+	}
+	if dualMode != "" {
+		cfg.DualDegree = dualMode
+	}
+	return NewBranchRegistry(cfg)
+}
+
+func NewBranchRegistry(cfg BranchConfig) (*BranchRegistry, error) {
+	src := cfg.Pattern
+	if src == "" {
+		src = defaultCampusIDPattern
+	}
+	pattern, err := regexp.Compile(src)
+	if err != nil {
+		return nil, fmt.Errorf("campus ID pattern: %w", err)
+	}
+	for _, group := range []string{"year", "first", "second"} {
+		if pattern.SubexpIndex(group) < 0 {
+			return nil, fmt.Errorf("campus ID pattern has no (?P<%s>...) group", group)
+		}
+	}
+
+	dual := cfg.DualDegree
+	if dual == "" {
+		dual = DualFirst
+	}
+	if !contains(DualDegreeModes, dual) {
+		return nil, fmt.Errorf("unknown dual degree grouping %q (want one of %s)", dual, strings.Join(DualDegreeModes, ", "))
+	}
+
+	r := &BranchRegistry{
+		pattern:  pattern,
+		names:    make(map[string]string),
+		campuses: make(map[string]string),
+		dual:     dual,
+	}
+	for k, v := range defaultBranchNames {
+		r.names[k] = v
+	}
+	for k, v := range cfg.Names {
+		r.names[strings.ToUpper(k)] = v
+	}
+	for k, v := range defaultCampusNames {
+		r.campuses[k] = v
+	}
+	for k, v := range cfg.Campuses {
+		r.campuses[strings.ToUpper(k)] = v
+	}
+	return r, nil
+}
+
+// parse splits a campus ID; ok is false when it does not match the pattern.
+func (r *BranchRegistry) parse(campusID string) (ProgramInfo, bool) {
+	m := r.pattern.FindStringSubmatch(campusID)
+	if m == nil {
+		return ProgramInfo{}, false
+	}
+	group := func(name string) string {
+		if i := r.pattern.SubexpIndex(name); i >= 0 {
+			return m[i]
+		}
+		return ""
+	}
+	info := ProgramInfo{Year: group("year"), FirstDegree: group("first"), Campus: group("campus")}
+	second := group("second")
+	if track, single := singleDegreeTracks[second]; single {
+		info.Track = track
+	} else {
+		info.SecondDegree = second
+	}
+	return info, true
+}
+
+// label is the name reports use for a degree code.
+func (r *BranchRegistry) label(code string) string {
+	if name, ok := r.names[code]; ok {
+		return name
+	}
+	return code
+}
+
+// groups returns the branch groups a programme is counted under.
+func (r *BranchRegistry) groups(p ProgramInfo) []string {
+	if !p.Dual() {
+		return []string{r.label(p.FirstDegree)}
+	}
+	switch r.dual {
+	case DualSecond:
+		return []string{r.label(p.SecondDegree)}
+	case DualBoth:
+		return []string{r.label(p.FirstDegree), r.label(p.SecondDegree)}
+	default:
+		return []string{r.label(p.FirstDegree)}
+	}
+}
+
+// assign fills in Program and Branches on every student and returns the
+// degree codes the registry has no name for.
+func (r *BranchRegistry) assign(students []Student) []string {
+	unknown := make(map[string]bool)
+	for i := range students {
+		s := &students[i]
+		info, ok := r.parse(s.CampusID)
+		if !ok {
+			s.Branches = []string{branchOf(s.CampusID)}
+			continue
+		}
+		s.Program = &info
+		s.Branches = r.groups(info)
+		for _, code := range []string{info.FirstDegree, info.SecondDegree} {
+			if _, known := r.names[code]; code != "" && !known {
+				unknown[code] = true
+			}
+		}
+	}
+	codes := make([]string, 0, len(unknown))
+	for code := range unknown {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// studentBranches returns the branch groups of s, falling back to the raw
+// code slice for students that never went through a registry.
+func studentBranches(s Student) []string {
+	if len(s.Branches) > 0 {
+		return s.Branches
+	}
+	return []string{branchOf(s.CampusID)}
+}
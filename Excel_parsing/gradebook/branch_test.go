@@ -0,0 +1,105 @@
+package gradebook
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseCampusID(t *testing.T) {
+	r, err := NewBranchRegistry(BranchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		id   string
+		want ProgramInfo
+		ok   bool
+	}{
+		{"2023A7PS0001P", ProgramInfo{Year: "2023", FirstDegree: "A7", Track: "Practice School", Campus: "P"}, true},
+		{"2021A3TS0456G", ProgramInfo{Year: "2021", FirstDegree: "A3", Track: "Thesis", Campus: "G"}, true},
+		{"2022B4A70012H", ProgramInfo{Year: "2022", FirstDegree: "B4", SecondDegree: "A7", Campus: "H"}, true},
+		{"2023a7ps0001p", ProgramInfo{}, false},
+		{"23A7PS0001P", ProgramInfo{}, false},
+		{"", ProgramInfo{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := r.parse(tt.id)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("parse(%q) = %+v, %v, want %+v, %v", tt.id, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestBranchGroups(t *testing.T) {
+	students := func() []Student {
+		return []Student{
+			{CampusID: "2023A7PS0001P"},
+			{CampusID: "2022B4A70012H"},
+			{CampusID: "2022B9A3TS13P"},
+			{CampusID: "2022Z1PS0003P"},
+		}
+	}
+	tests := []struct {
+		cfg     BranchConfig
+		groups  [][]string
+		unknown []string
+	}{
+		{
+			BranchConfig{},
+			[][]string{{"Computer Science"}, {"M.Sc. Mathematics"}, {"B9A3"}, {"Z1"}},
+			[]string{"Z1"},
+		},
+		{
+			BranchConfig{DualDegree: DualSecond},
+			[][]string{{"Computer Science"}, {"Computer Science"}, {"B9A3"}, {"Z1"}},
+			[]string{"Z1"},
+		},
+		{
+			BranchConfig{DualDegree: DualBoth, Names: map[string]string{"z1": "Zoology"}},
+			[][]string{{"Computer Science"}, {"M.Sc. Mathematics", "Computer Science"}, {"B9A3"}, {"Zoology"}},
+			[]string{},
+		},
+	}
+	for _, tt := range tests {
+		r, err := NewBranchRegistry(tt.cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		s := students()
+		unknown := r.assign(s)
+		var groups [][]string
+		for _, st := range s {
+			groups = append(groups, st.Branches)
+		}
+		if !reflect.DeepEqual(groups, tt.groups) {
+			t.Errorf("%+v: groups %q, want %q", tt.cfg, groups, tt.groups)
+		}
+		if !reflect.DeepEqual(unknown, tt.unknown) {
+			t.Errorf("%+v: unknown codes %v, want %v", tt.cfg, unknown, tt.unknown)
+		}
+		if s[2].Program != nil || s[0].Program == nil {
+			t.Errorf("%+v: programs %v and %v", tt.cfg, s[0].Program, s[2].Program)
+		}
+	}
+}
+
+func TestNewBranchRegistryErrors(t *testing.T) {
+	for _, cfg := range []BranchConfig{
+		{Pattern: `(?P<year>\d{4}`},
+		{Pattern: `^(?P<year>\d{4})(?P<first>..)$`},
+		{DualDegree: "either"},
+	} {
+		if _, err := NewBranchRegistry(cfg); err == nil {
+			t.Errorf("%+v accepted", cfg)
+		}
+	}
+
+	// A custom pattern need not name the serial or campus.
+	r, err := NewBranchRegistry(BranchConfig{Pattern: `^(?P<year>\d{2})-(?P<first>[A-Z0-9]{2})(?P<second>[A-Z]{2})$`})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, ok := r.parse("23-A7PS"); !ok || got != (ProgramInfo{Year: "23", FirstDegree: "A7", Track: "Practice School"}) {
+		t.Errorf("custom pattern parsed %+v, %v", got, ok)
+	}
+}
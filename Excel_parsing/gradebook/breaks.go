@@ -0,0 +1,321 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Natural-breaks methods for suggesting grade cutoffs. Jenks finds the
+// split with the least squared deviation within grades; gap puts the
+// cutoffs in the widest gaps between consecutive scores; kmeans runs
+// Lloyd's algorithm from an equal-count split, which usually lands on
+// the Jenks split but can stop at a nearby one.
+const (
+	BreaksJenks  = "jenks"
+	BreaksGap    = "gap"
+	BreaksKMeans = "kmeans"
+)
+
+var BreakMethods = []string{BreaksJenks, BreaksGap, BreaksKMeans}
+
+const kmeansIterations = 100
+
+// BreakOptions configure cutoff suggestions. Grades is the number of
+// grades to split the class into, awarded from A down the letter grades
+// (all of them when zero). MinQuota and MaxQuota bound the percentage of
+// the class in any one grade and are ignored when zero. By is the score
+// split on, Total by default.
+type BreakOptions struct {
+	Method   string
+	Grades   int
+	MinQuota float64
+	MaxQuota float64
+	By       string
+}
+
+// CutoffSuggestion is a proposed set of grade cutoffs. Fit is the
+// goodness of variance fit, the share of the variance in scores that lies
+// between grades rather than within them.
+type CutoffSuggestion struct {
+	Method   string            `json:"method"`
+	By       string            `json:"by"`
+	MinQuota float64           `json:"min_quota,omitempty"`
+	MaxQuota float64           `json:"max_quota,omitempty"`
+	Fit      float64           `json:"fit"`
+	Cutoffs  []SuggestedCutoff `json:"cutoffs"`
+}
+
+// SuggestedCutoff is one proposed grade. Cutoff is the lowest score in
+// the grade and Gap the distance down to the highest score of the next
+// grade; AtCutoff and Below are the students on either side of the gap.
+// The lowest grade has no gap below it.
+type SuggestedCutoff struct {
+	Grade      string   `json:"grade"`
+	Cutoff     float64  `json:"cutoff"`
+	Count      int      `json:"count"`
+	Share      float64  `json:"share"`
+	Gap        float64  `json:"gap,omitempty"`
+	AtCutoff   []string `json:"at_cutoff"`
+	Below      []string `json:"below,omitempty"`
+	BelowScore *float64 `json:"below_score,omitempty"`
+}
+
+// SuggestCutoffs splits students into grades at natural breaks in their
+// scores. Students with equal scores always get the same grade.
+func SuggestCutoffs(students []Student, opts BreakOptions) (CutoffSuggestion, error) {
+	if opts.Method == "" {
+		opts.Method = BreaksJenks
+	}
+	if !contains(BreakMethods, opts.Method) {
+		return CutoffSuggestion{}, fmt.Errorf("unknown natural-breaks method %q (want %s)", opts.Method, strings.Join(BreakMethods, ", "))
+	}
+	if opts.By == "" {
+		opts.By = FieldTotal
+	}
+	k := opts.Grades
+	if k == 0 {
+		k = len(letterGrades)
+	}
+	if k < 2 || k > len(letterGrades) {
+		return CutoffSuggestion{}, fmt.Errorf("cannot suggest cutoffs for %d grades (want 2 to %d)", k, len(letterGrades))
+	}
+	if opts.MinQuota < 0 || opts.MaxQuota < 0 || (opts.MaxQuota > 0 && opts.MaxQuota < opts.MinQuota) {
+		return CutoffSuggestion{}, fmt.Errorf("bad grade quotas: minimum %g%%, maximum %g%%", opts.MinQuota, opts.MaxQuota)
+	}
+
+	type scored struct {
+		emplid string
+		score  float64
+	}
+	// Students without the score take no part in the split.
+	ranked := make([]scored, 0, len(students))
+	for _, s := range students {
+		if v, ok := s.Component(opts.By); ok {
+			ranked = append(ranked, scored{emplid: s.Emplid, score: v})
+		}
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		if ranked[i].score != ranked[j].score {
+			return ranked[i].score > ranked[j].score
+		}
+		return ranked[i].emplid < ranked[j].emplid
+	})
+	x := make([]float64, len(ranked))
+	distinct := 0
+	for i, r := range ranked {
+		x[i] = r.score
+		if i == 0 || x[i] != x[i-1] {
+			distinct++
+		}
+	}
+	if distinct < k {
+		return CutoffSuggestion{}, fmt.Errorf("only %d distinct %s scores to split into %d grades", distinct, opts.By, k)
+	}
+
+	n := len(x)
+	minSize := int(math.Ceil(opts.MinQuota / 100 * float64(n)))
+	if minSize < 1 {
+		minSize = 1
+	}
+	maxSize := n
+	if opts.MaxQuota > 0 {
+		maxSize = int(math.Floor(opts.MaxQuota / 100 * float64(n)))
+	}
+	p := breakProblem{x: x, k: k, minSize: minSize, maxSize: maxSize}
+	p.prefix()
+
+	var ends []int
+	var ok bool
+	switch opts.Method {
+	case BreaksJenks:
+		ends, ok = p.partition(func(_, i, e int) float64 { return p.sse(i, e) })
+	case BreaksGap:
+		ends, ok = p.partition(func(_, _, e int) float64 {
+			if e == n {
+				return 0
+			}
+			return -(x[e-1] - x[e])
+		})
+	case BreaksKMeans:
+		ends, ok = p.kmeans()
+	}
+	if !ok {
+		return CutoffSuggestion{}, fmt.Errorf("no split of %d students into %d grades keeps every grade between %d and %d students without splitting tied scores",
+			n, k, minSize, maxSize)
+	}
+
+	suggestion := CutoffSuggestion{
+		Method: opts.Method, By: opts.By, MinQuota: opts.MinQuota, MaxQuota: opts.MaxQuota, Cutoffs: []SuggestedCutoff{},
+	}
+	var within float64
+	start := 0
+	for j, e := range ends {
+		within += p.sse(start, e)
+		c := SuggestedCutoff{
+			Grade:  letterGrades[j],
+			Cutoff: x[e-1],
+			Count:  e - start,
+			Share:  float64(e-start) / float64(n) * 100,
+		}
+		for i := e - 1; i >= start && x[i] == x[e-1]; i-- {
+			c.AtCutoff = append(c.AtCutoff, ranked[i].emplid)
+		}
+		sort.Strings(c.AtCutoff)
+		if e < n {
+			c.Gap = x[e-1] - x[e]
+			c.BelowScore = &x[e]
+			for i := e; i < n && x[i] == x[e]; i++ {
+				c.Below = append(c.Below, ranked[i].emplid)
+			}
+		}
+		suggestion.Cutoffs = append(suggestion.Cutoffs, c)
+		start = e
+	}
+	if total := p.sse(0, n); total > 0 {
+		suggestion.Fit = 1 - within/total
+	}
+	return suggestion, nil
+}
+
+// breakProblem is a descending list of scores to split into k runs of
+// minSize to maxSize scores each, with prefix sums for O(1) run costs.
+type breakProblem struct {
+	x                []float64
+	k                int
+	minSize, maxSize int
+	sum, sumSq       []float64
+}
+
+func (p *breakProblem) prefix() {
+	p.sum = make([]float64, len(p.x)+1)
+	p.sumSq = make([]float64, len(p.x)+1)
+	for i, v := range p.x {
+		p.sum[i+1] = p.sum[i] + v
+		p.sumSq[i+1] = p.sumSq[i] + v*v
+	}
+}
+
+// sse is the sum of squared deviations from the mean of x[i:e].
+func (p *breakProblem) sse(i, e int) float64 {
+	m := float64(e - i)
+	s := p.sum[e] - p.sum[i]
+	return math.Max(0, p.sumSq[e]-p.sumSq[i]-s*s/m)
+}
+
+// partition finds the split of x into k runs, by dynamic programming,
+// that minimises the sum of cost(j, i, e) over runs x[i:e], the j-th run
+// from the top. Runs never end between equal scores. It returns the end
+// of each run, or false when the sizes cannot be met.
+func (p *breakProblem) partition(cost func(j, i, e int) float64) ([]int, bool) {
+	n := len(p.x)
+	inf := math.Inf(1)
+	best := make([][]float64, p.k+1)
+	from := make([][]int, p.k+1)
+	for j := range best {
+		best[j] = make([]float64, n+1)
+		from[j] = make([]int, n+1)
+		for e := range best[j] {
+			best[j][e] = inf
+		}
+	}
+	best[0][0] = 0
+	for j := 1; j <= p.k; j++ {
+		for e := j; e <= n; e++ {
+			if e < n && p.x[e-1] == p.x[e] {
+				continue
+			}
+			lo := e - p.maxSize
+			if lo < 0 {
+				lo = 0
+			}
+			for i := lo; i <= e-p.minSize; i++ {
+				if math.IsInf(best[j-1][i], 1) {
+					continue
+				}
+				if c := best[j-1][i] + cost(j-1, i, e); c < best[j][e] {
+					best[j][e], from[j][e] = c, i
+				}
+			}
+		}
+	}
+	if math.IsInf(best[p.k][n], 1) {
+		return nil, false
+	}
+	ends := make([]int, p.k)
+	for j, e := p.k, n; j > 0; j-- {
+		ends[j-1] = e
+		e = from[j][e]
+	}
+	return ends, true
+}
+
+// kmeans runs Lloyd's algorithm. In one dimension the best assignment to
+// fixed centres is a split into runs, so the assignment step is a
+// partition too, which also keeps the quotas and ties.
+func (p *breakProblem) kmeans() ([]int, bool) {
+	n := len(p.x)
+	centres := make([]float64, p.k)
+	for j := range centres {
+		i, e := j*n/p.k, (j+1)*n/p.k
+		centres[j] = (p.sum[e] - p.sum[i]) / float64(e-i)
+	}
+	var ends []int
+	for iter := 0; iter < kmeansIterations; iter++ {
+		next, ok := p.partition(func(j, i, e int) float64 {
+			c := centres[j]
+			return p.sumSq[e] - p.sumSq[i] - 2*c*(p.sum[e]-p.sum[i]) + float64(e-i)*c*c
+		})
+		if !ok {
+			return nil, false
+		}
+		if equalInts(next, ends) {
+			break
+		}
+		ends = next
+		start := 0
+		for j, e := range ends {
+			centres[j] = (p.sum[e] - p.sum[start]) / float64(e-start)
+			start = e
+		}
+	}
+	return ends, true
+}
+
+func equalInts(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func cutoffSuggestionTable(r *CutoffSuggestion) Table {
+	title := fmt.Sprintf("Suggested Cutoffs (%s on %s, fit %.3f", r.Method, r.By, r.Fit)
+	if r.MinQuota > 0 {
+		title += fmt.Sprintf(", at least %g%% per grade", r.MinQuota)
+	}
+	if r.MaxQuota > 0 {
+		title += fmt.Sprintf(", at most %g%% per grade", r.MaxQuota)
+	}
+	t := Table{
+		Name:   "cutoff_suggestions",
+		Title:  title + ")",
+		Header: []string{"Grade", "Minimum " + r.By, "Students", "Share %", "Gap Below", "Lowest In Grade", "Highest Below"},
+	}
+	for _, c := range r.Cutoffs {
+		gap, below := interface{}(""), ""
+		if len(c.Below) > 0 {
+			gap = c.Gap
+			below = fmt.Sprintf("%s (%.2f)", strings.Join(c.Below, ", "), *c.BelowScore)
+		}
+		at := fmt.Sprintf("%s (%.2f)", strings.Join(c.AtCutoff, ", "), c.Cutoff)
+		t.Rows = append(t.Rows, []interface{}{c.Grade, c.Cutoff, c.Count, c.Share, gap, at, below})
+	}
+	return t
+}
@@ -0,0 +1,104 @@
+package gradebook
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// breakStudents gives each score in turn to students 1, 2, ...
+func breakStudents(scores ...float64) []Student {
+	students := make([]Student, len(scores))
+	for i, v := range scores {
+		students[i] = Student{Emplid: fmt.Sprint(i + 1), Total: v}
+	}
+	return students
+}
+
+func TestSuggestCutoffs(t *testing.T) {
+	// Three clusters, with an incomplete student left out of the split.
+	clusters := breakStudents(95, 93, 91, 70, 68, 66, 64, 40, 38, 0)
+	clusters[9].Special = map[string]SpecialMark{FieldTotal: {Marker: "I", State: MarkIncomplete, Policy: PolicyExclude}}
+
+	tests := []struct {
+		name     string
+		students []Student
+		opts     BreakOptions
+		cutoffs  []float64
+		counts   []int
+	}{
+		{"jenks", clusters, BreakOptions{Grades: 3}, []float64{91, 64, 38}, []int{3, 4, 2}},
+		{"gap", clusters, BreakOptions{Method: BreaksGap, Grades: 3}, []float64{91, 64, 38}, []int{3, 4, 2}},
+		{"kmeans", clusters, BreakOptions{Method: BreaksKMeans, Grades: 3}, []float64{91, 64, 38}, []int{3, 4, 2}},
+		// At least 30% of nine is three students a grade.
+		{"minimum quota", clusters, BreakOptions{Grades: 3, MinQuota: 30}, []float64{91, 66, 38}, []int{3, 3, 3}},
+		{"by MidSem", []Student{{Emplid: "1", MidSem: 30}, {Emplid: "2", MidSem: 29}, {Emplid: "3", MidSem: 5}}, BreakOptions{Grades: 2, By: FieldMidSem}, []float64{29, 5}, []int{2, 1}},
+		// The gap between 80 and 79 is the narrowest, but ties stay together.
+		{"ties", breakStudents(90, 80, 80, 80, 79, 50), BreakOptions{Method: BreaksGap, Grades: 3}, []float64{90, 79, 50}, []int{1, 4, 1}},
+	}
+	for _, tt := range tests {
+		s, err := SuggestCutoffs(tt.students, tt.opts)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		var cutoffs []float64
+		var counts []int
+		for _, c := range s.Cutoffs {
+			cutoffs = append(cutoffs, c.Cutoff)
+			counts = append(counts, c.Count)
+		}
+		if !reflect.DeepEqual(cutoffs, tt.cutoffs) || !reflect.DeepEqual(counts, tt.counts) {
+			t.Errorf("%s: cutoffs %v with %v students, want %v with %v", tt.name, cutoffs, counts, tt.cutoffs, tt.counts)
+		}
+		if s.Fit <= 0 || s.Fit > 1 {
+			t.Errorf("%s: fit %g", tt.name, s.Fit)
+		}
+	}
+}
+
+func TestSuggestCutoffsDetail(t *testing.T) {
+	s, err := SuggestCutoffs(breakStudents(95, 91, 93, 91, 70, 40), BreakOptions{Grades: 3})
+	if err != nil {
+		t.Fatal(err)
+	}
+	seventy, forty := 70.0, 40.0
+	share := func(count int) float64 { return float64(count) / 6 * 100 }
+	want := []SuggestedCutoff{
+		{Grade: "A", Cutoff: 91, Count: 4, Share: share(4), Gap: 21, AtCutoff: []string{"2", "4"}, Below: []string{"5"}, BelowScore: &seventy},
+		{Grade: "A-", Cutoff: 70, Count: 1, Share: share(1), Gap: 30, AtCutoff: []string{"5"}, Below: []string{"6"}, BelowScore: &forty},
+		{Grade: "B", Cutoff: 40, Count: 1, Share: share(1), AtCutoff: []string{"6"}},
+	}
+	if !reflect.DeepEqual(s.Cutoffs, want) {
+		t.Errorf("cutoffs %+v, want %+v", s.Cutoffs, want)
+	}
+	table := cutoffSuggestionTable(&s)
+	if len(table.Rows) != 3 || table.Rows[0][6] != "5 (70.00)" || table.Rows[2][4] != "" {
+		t.Errorf("table rows %v", table.Rows)
+	}
+}
+
+func TestSuggestCutoffsErrors(t *testing.T) {
+	students := breakStudents(90, 80, 80, 80, 79, 50)
+	tests := []struct {
+		opts BreakOptions
+		err  string
+	}{
+		{BreakOptions{Method: "quantile"}, "unknown natural-breaks method"},
+		{BreakOptions{Grades: 1}, "cannot suggest cutoffs for 1 grades"},
+		{BreakOptions{Grades: 9}, "cannot suggest cutoffs for 9 grades"},
+		{BreakOptions{Grades: 2, MinQuota: -5}, "bad grade quotas"},
+		{BreakOptions{Grades: 2, MinQuota: 40, MaxQuota: 30}, "bad grade quotas"},
+		{BreakOptions{Grades: 5}, "only 4 distinct Total scores"},
+		{BreakOptions{Grades: 3, MaxQuota: 20}, "no split of 6 students"},
+		// Three a grade would split the 80s.
+		{BreakOptions{Grades: 2, MaxQuota: 50}, "without splitting tied scores"},
+	}
+	for _, tt := range tests {
+		_, err := SuggestCutoffs(students, tt.opts)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%+v: error %v, want %q", tt.opts, err, tt.err)
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gradebook
+
+import (
+	"math"
+	"sort"
+)
+
+// ChartData holds what the charts are drawn from: a histogram per
+// component, box plots of Total per branch and MidSem against Compre.
+type ChartData struct {
+	Histograms []Histogram `json:"histograms"`
+	BoxPlotOf  string      `json:"box_plot_of"`
+	BoxPlots   []BoxPlot   `json:"box_plots"`
+	Scatter    *Scatter    `json:"scatter,omitempty"`
+}
+
+type HistogramBin struct {
+	Lo    float64 `json:"lo"`
+	Hi    float64 `json:"hi"`
+	Count int     `json:"count"`
+}
+
+type Histogram struct {
+	Component string         `json:"component"`
+	Bins      []HistogramBin `json:"bins"`
+}
+
+// BoxPlot is a Tukey box plot: whiskers reach the furthest values within
+// 1.5 IQR of the box and anything beyond is an outlier.
+type BoxPlot struct {
+	Group        string    `json:"group"`
+	Count        int       `json:"count"`
+	LowerWhisker float64   `json:"lower_whisker"`
+	Q1           float64   `json:"q1"`
+	Median       float64   `json:"median"`
+	Q3           float64   `json:"q3"`
+	UpperWhisker float64   `json:"upper_whisker"`
+	Outliers     []float64 `json:"outliers"`
+}
+
+type ScatterPoint struct {
+	Emplid string  `json:"emplid"`
+	X      float64 `json:"x"`
+	Y      float64 `json:"y"`
+}
+
+// Scatter pairs two components per student with the least-squares line
+// and Pearson correlation of the points.
+type Scatter struct {
+	X         string         `json:"x"`
+	Y         string         `json:"y"`
+	Points    []ScatterPoint `json:"points"`
+	Slope     float64        `json:"slope"`
+	Intercept float64        `json:"intercept"`
+	R         float64        `json:"r"`
+}
+
+// BuildCharts computes the chart data for students.
+func BuildCharts(students []Student) ChartData {
+	data := ChartData{BoxPlotOf: FieldTotal, Histograms: []Histogram{}, BoxPlots: []BoxPlot{}}
+	if len(students) == 0 {
+		return data
+	}
+	for _, c := range statComponents(students) {
+		data.Histograms = append(data.Histograms, histogram(c, componentValues(students, c)))
+	}
+
+	groups := make(map[string][]float64)
+	for _, s := range students {
+		total, ok := s.Component(FieldTotal)
+		if !ok {
+			continue
+		}
+		for _, b := range studentBranches(s) {
+			groups[b] = append(groups[b], total)
+		}
+	}
+	var names []string
+	for name := range groups {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		data.BoxPlots = append(data.BoxPlots, boxPlot(name, groups[name]))
+	}
+
+	data.Scatter = scatter(students, FieldMidSem, FieldCompre)
+	return data
+}
+
+func componentValues(students []Student, component string) []float64 {
+	var values []float64
+	for _, s := range students {
+		if v, ok := s.Component(component); ok {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+// histogram bins values into roughly sqrt(n) bins of a round width.
+func histogram(component string, values []float64) Histogram {
+	h := Histogram{Component: component, Bins: []HistogramBin{}}
+	if len(values) == 0 {
+		return h
+	}
+	lo, hi := values[0], values[0]
+	for _, v := range values {
+		lo, hi = math.Min(lo, v), math.Max(hi, v)
+	}
+	k := math.Max(5, math.Min(20, math.Ceil(math.Sqrt(float64(len(values))))))
+	width := niceStep((hi - lo) / k)
+	start := math.Floor(lo/width) * width
+	n := int(math.Floor((hi-start)/width)) + 1
+	for i := 0; i < n; i++ {
+		h.Bins = append(h.Bins, HistogramBin{Lo: start + float64(i)*width, Hi: start + float64(i+1)*width})
+	}
+	for _, v := range values {
+		i := int(math.Floor((v - start) / width))
+		if i >= n {
+			i = n - 1
+		}
+		h.Bins[i].Count++
+	}
+	return h
+}
+
+// niceStep rounds raw up to 1, 2, 2.5 or 5 times a power of ten.
+func niceStep(raw float64) float64 {
+	if raw <= 0 {
+		return 1
+	}
+	mag := math.Pow(10, math.Floor(math.Log10(raw)))
+	for _, m := range []float64{1, 2, 2.5, 5, 10} {
+		if raw <= m*mag {
+			return m * mag
+		}
+	}
+	return 10 * mag
+}
+
+func boxPlot(group string, values []float64) BoxPlot {
+	sorted := append([]float64(nil), values...)
+	sort.Float64s(sorted)
+	b := BoxPlot{
+		Group:    group,
+		Count:    len(sorted),
+		Q1:       quantile(sorted, 0.25),
+		Median:   quantile(sorted, 0.5),
+		Q3:       quantile(sorted, 0.75),
+		Outliers: []float64{},
+	}
+	fence := 1.5 * (b.Q3 - b.Q1)
+	b.LowerWhisker, b.UpperWhisker = b.Q1, b.Q3
+	for _, v := range sorted {
+		switch {
+		case v < b.Q1-fence || v > b.Q3+fence:
+			b.Outliers = append(b.Outliers, v)
+		case v < b.LowerWhisker:
+			b.LowerWhisker = v
+		case v > b.UpperWhisker:
+			b.UpperWhisker = v
+		}
+	}
+	return b
+}
+
+func scatter(students []Student, x, y string) *Scatter {
+	sc := &Scatter{X: x, Y: y, Points: []ScatterPoint{}}
+	var xs, ys []float64
+	for _, s := range students {
+		xv, okX := s.Component(x)
+		yv, okY := s.Component(y)
+		if okX && okY {
+			sc.Points = append(sc.Points, ScatterPoint{Emplid: s.Emplid, X: xv, Y: yv})
+			xs, ys = append(xs, xv), append(ys, yv)
+		}
+	}
+	mx, sx := meanSD(xs)
+	my, sy := meanSD(ys)
+	var cov float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+	}
+	if len(xs) > 0 {
+		cov /= float64(len(xs))
+	}
+	if sx > 0 {
+		sc.Slope = cov / (sx * sx)
+	}
+	sc.Intercept = my - sc.Slope*mx
+	if sx > 0 && sy > 0 {
+		sc.R = cov / (sx * sy)
+	}
+	return sc
+}
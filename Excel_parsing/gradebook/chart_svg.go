@@ -0,0 +1,244 @@
+package gradebook
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// SVGChart is one rendered chart. Name is a file-safe base name.
+type SVGChart struct {
+	Name  string
+	Title string
+	SVG   string
+}
+
+// RenderSVG draws every chart in data as a standalone SVG document.
+func RenderSVG(data ChartData) []SVGChart {
+	var charts []SVGChart
+	for _, h := range data.Histograms {
+		title := "Distribution of " + h.Component
+		charts = append(charts, SVGChart{"histogram_" + fileSlug(h.Component), title, svgHistogram(title, h)})
+	}
+	if len(data.BoxPlots) > 0 {
+		title := data.BoxPlotOf + " by Branch"
+		charts = append(charts, SVGChart{"boxplot_branches", title, svgBoxPlots(title, data.BoxPlots)})
+	}
+	if sc := data.Scatter; sc != nil && len(sc.Points) > 0 {
+		title := fmt.Sprintf("%s vs %s (r = %.2f)", sc.Y, sc.X, sc.R)
+		charts = append(charts, SVGChart{"scatter_" + fileSlug(sc.X) + "_" + fileSlug(sc.Y), title, svgScatter(title, sc)})
+	}
+	return charts
+}
+
+// WriteSVGCharts writes one .svg file per chart into dir and returns the
+// paths written.
+func WriteSVGCharts(dir string, data ChartData) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, c := range RenderSVG(data) {
+		path := filepath.Join(dir, c.Name+".svg")
+		if err := os.WriteFile(path, []byte(c.SVG), 0o644); err != nil {
+			return paths, err
+		}
+		paths = append(paths, path)
+	}
+	return paths, nil
+}
+
+const (
+	svgWidth   = 640
+	svgHeight  = 400
+	svgLeft    = 60
+	svgRight   = 20
+	svgTop     = 40
+	svgBottom  = 50
+	svgBarFill = "#4472c4"
+)
+
+// plot maps data coordinates onto the drawing area of an SVG canvas.
+type plot struct {
+	b                       strings.Builder
+	width, height, bottom   float64
+	xlo, xhi, ylo, yhi      float64
+	innerWidth, innerHeight float64
+}
+
+func newPlot(title string, width, bottom float64, xlo, xhi, ylo, yhi float64) *plot {
+	p := &plot{width: width, height: svgHeight, bottom: bottom, xlo: xlo, xhi: xhi, ylo: ylo, yhi: yhi}
+	p.innerWidth = width - svgLeft - svgRight
+	p.innerHeight = svgHeight - svgTop - bottom
+	fmt.Fprintf(&p.b, `<svg xmlns="http://www.w3.org/2000/svg" width="%g" height="%g" viewBox="0 0 %g %g" font-family="sans-serif" font-size="11">`+"\n",
+		width, p.height, width, p.height)
+	fmt.Fprintf(&p.b, `<rect width="100%%" height="100%%" fill="white"/>`+"\n")
+	fmt.Fprintf(&p.b, `<text x="%g" y="22" text-anchor="middle" font-size="14" font-weight="bold">%s</text>`+"\n",
+		width/2, html.EscapeString(title))
+	return p
+}
+
+func (p *plot) x(v float64) float64 {
+	if p.xhi == p.xlo {
+		return svgLeft + p.innerWidth/2
+	}
+	return svgLeft + (v-p.xlo)/(p.xhi-p.xlo)*p.innerWidth
+}
+
+func (p *plot) y(v float64) float64 {
+	if p.yhi == p.ylo {
+		return svgTop + p.innerHeight/2
+	}
+	return svgTop + p.innerHeight - (v-p.ylo)/(p.yhi-p.ylo)*p.innerHeight
+}
+
+// yAxis draws the left axis with gridlines at every tick.
+func (p *plot) yAxis(label string) {
+	for _, t := range ticks(p.ylo, p.yhi) {
+		y := p.y(t)
+		fmt.Fprintf(&p.b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#e0e0e0"/>`+"\n", svgLeft, y, p.width-svgRight, y)
+		fmt.Fprintf(&p.b, `<text x="%d" y="%.1f" text-anchor="end" dominant-baseline="middle">%s</text>`+"\n", svgLeft-6, y, tickLabel(t))
+	}
+	fmt.Fprintf(&p.b, `<line x1="%d" y1="%d" x2="%d" y2="%.1f" stroke="#333"/>`+"\n", svgLeft, svgTop, svgLeft, svgTop+p.innerHeight)
+	fmt.Fprintf(&p.b, `<text transform="translate(16 %.1f) rotate(-90)" text-anchor="middle">%s</text>`+"\n",
+		svgTop+p.innerHeight/2, html.EscapeString(label))
+}
+
+// xAxis draws the bottom axis with numeric ticks.
+func (p *plot) xAxis(label string) {
+	base := svgTop + p.innerHeight
+	for _, t := range ticks(p.xlo, p.xhi) {
+		x := p.x(t)
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", x, base, x, base+4)
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n", x, base+16, tickLabel(t))
+	}
+	p.baseline()
+	fmt.Fprintf(&p.b, `<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n",
+		svgLeft+p.innerWidth/2, p.height-12, html.EscapeString(label))
+}
+
+func (p *plot) baseline() {
+	base := svgTop + p.innerHeight
+	fmt.Fprintf(&p.b, `<line x1="%d" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", svgLeft, base, p.width-svgRight, base)
+}
+
+func (p *plot) String() string {
+	return p.b.String() + "</svg>\n"
+}
+
+// ticks returns round values spanning lo..hi, about five of them.
+func ticks(lo, hi float64) []float64 {
+	if hi <= lo {
+		return []float64{lo}
+	}
+	step := niceStep((hi - lo) / 5)
+	var out []float64
+	for t := math.Ceil(lo/step) * step; t <= hi+step*1e-9; t += step {
+		out = append(out, t)
+	}
+	return out
+}
+
+func tickLabel(v float64) string {
+	return strconv.FormatFloat(math.Round(v*100)/100, 'f', -1, 64)
+}
+
+// niceRange widens lo..hi outwards to round tick values.
+func niceRange(lo, hi float64) (float64, float64) {
+	if hi <= lo {
+		return lo - 1, hi + 1
+	}
+	step := niceStep((hi - lo) / 5)
+	return math.Floor(lo/step) * step, math.Ceil(hi/step) * step
+}
+
+func svgHistogram(title string, h Histogram) string {
+	xlo, xhi := 0.0, 1.0
+	maxCount := 0
+	if len(h.Bins) > 0 {
+		xlo, xhi = h.Bins[0].Lo, h.Bins[len(h.Bins)-1].Hi
+	}
+	for _, bin := range h.Bins {
+		if bin.Count > maxCount {
+			maxCount = bin.Count
+		}
+	}
+	_, yhi := niceRange(0, float64(maxCount))
+	p := newPlot(title, svgWidth, svgBottom, xlo, xhi, 0, yhi)
+	p.yAxis("Students")
+	for _, bin := range h.Bins {
+		x0, x1 := p.x(bin.Lo), p.x(bin.Hi)
+		y := p.y(float64(bin.Count))
+		fmt.Fprintf(&p.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="white"><title>%s–%s: %d</title></rect>`+"\n",
+			x0, y, x1-x0, p.y(0)-y, svgBarFill, tickLabel(bin.Lo), tickLabel(bin.Hi), bin.Count)
+	}
+	p.xAxis(h.Component)
+	return p.String()
+}
+
+func svgBoxPlots(title string, boxes []BoxPlot) string {
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for _, b := range boxes {
+		lo, hi = math.Min(lo, b.LowerWhisker), math.Max(hi, b.UpperWhisker)
+		for _, o := range b.Outliers {
+			lo, hi = math.Min(lo, o), math.Max(hi, o)
+		}
+	}
+	ylo, yhi := niceRange(lo, hi)
+	width := math.Max(svgWidth, float64(80*len(boxes)+svgLeft+svgRight))
+	p := newPlot(title, width, 110, 0, float64(len(boxes)), ylo, yhi)
+	p.yAxis(FieldTotal)
+	p.baseline()
+	slot := p.innerWidth / float64(len(boxes))
+	for i, b := range boxes {
+		cx := svgLeft + slot*(float64(i)+0.5)
+		half := math.Min(slot*0.3, 30)
+		fmt.Fprintf(&p.b, `<g><title>%s (n=%d): median %s, IQR %s–%s</title>`+"\n",
+			html.EscapeString(b.Group), b.Count, tickLabel(b.Median), tickLabel(b.Q1), tickLabel(b.Q3))
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", cx, p.y(b.LowerWhisker), cx, p.y(b.Q1))
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", cx, p.y(b.Q3), cx, p.y(b.UpperWhisker))
+		for _, w := range []float64{b.LowerWhisker, b.UpperWhisker} {
+			fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#333"/>`+"\n", cx-half/2, p.y(w), cx+half/2, p.y(w))
+		}
+		fmt.Fprintf(&p.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="#c5d5f0" stroke="%s"/>`+"\n",
+			cx-half, p.y(b.Q3), 2*half, p.y(b.Q1)-p.y(b.Q3), svgBarFill)
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="%s" stroke-width="2"/>`+"\n",
+			cx-half, p.y(b.Median), cx+half, p.y(b.Median), svgBarFill)
+		for _, o := range b.Outliers {
+			fmt.Fprintf(&p.b, `<circle cx="%.1f" cy="%.1f" r="3" fill="none" stroke="#c00"/>`+"\n", cx, p.y(o))
+		}
+		fmt.Fprintln(&p.b, "</g>")
+		base := svgTop + p.innerHeight
+		fmt.Fprintf(&p.b, `<text transform="translate(%.1f %.1f) rotate(-35)" text-anchor="end">%s</text>`+"\n",
+			cx, base+14, html.EscapeString(b.Group))
+	}
+	return p.String()
+}
+
+func svgScatter(title string, sc *Scatter) string {
+	xlo, xhi := math.Inf(1), math.Inf(-1)
+	ylo, yhi := math.Inf(1), math.Inf(-1)
+	for _, pt := range sc.Points {
+		xlo, xhi = math.Min(xlo, pt.X), math.Max(xhi, pt.X)
+		ylo, yhi = math.Min(ylo, pt.Y), math.Max(yhi, pt.Y)
+	}
+	xlo, xhi = niceRange(xlo, xhi)
+	ylo, yhi = niceRange(ylo, yhi)
+	p := newPlot(title, svgWidth, svgBottom, xlo, xhi, ylo, yhi)
+	p.yAxis(sc.Y)
+	for _, pt := range sc.Points {
+		fmt.Fprintf(&p.b, `<circle cx="%.1f" cy="%.1f" r="3" fill="%s" fill-opacity="0.7"><title>%s: %s, %s</title></circle>`+"\n",
+			p.x(pt.X), p.y(pt.Y), svgBarFill, html.EscapeString(pt.Emplid), tickLabel(pt.X), tickLabel(pt.Y))
+	}
+	fmt.Fprintf(&p.b, `<clipPath id="area"><rect x="%d" y="%d" width="%.1f" height="%.1f"/></clipPath>`+"\n",
+		svgLeft, svgTop, p.innerWidth, p.innerHeight)
+	fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%.1f" x2="%.1f" y2="%.1f" stroke="#c00" stroke-dasharray="4 3" clip-path="url(#area)"><title>least squares: %s = %.3f × %s %+.3f</title></line>`+"\n",
+		p.x(xlo), p.y(sc.Intercept+sc.Slope*xlo), p.x(xhi), p.y(sc.Intercept+sc.Slope*xhi),
+		html.EscapeString(sc.Y), sc.Slope, html.EscapeString(sc.X), sc.Intercept)
+	p.xAxis(sc.X)
+	return p.String()
+}
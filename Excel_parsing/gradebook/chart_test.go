@@ -0,0 +1,182 @@
+package gradebook
+
+import (
+	"encoding/xml"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestNiceStep(t *testing.T) {
+	for raw, want := range map[float64]float64{
+		0: 1, -3: 1, 0.7: 1, 1: 1, 1.2: 2, 2.2: 2.5, 3: 5, 7: 10, 13: 20, 0.042: 0.05,
+	} {
+		if got := niceStep(raw); math.Abs(got-want) > 1e-12 {
+			t.Errorf("niceStep(%g) = %g, want %g", raw, got, want)
+		}
+	}
+}
+
+func TestTicks(t *testing.T) {
+	tests := []struct {
+		lo, hi float64
+		want   []float64
+	}{
+		{0, 100, []float64{0, 20, 40, 60, 80, 100}},
+		{3, 17, []float64{5, 10, 15}},
+		{5, 5, []float64{5}},
+	}
+	for _, tt := range tests {
+		if got := ticks(tt.lo, tt.hi); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ticks(%g, %g) = %v, want %v", tt.lo, tt.hi, got, tt.want)
+		}
+	}
+}
+
+func TestHistogram(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		lo, hi float64
+		counts []int
+	}{
+		// 25 values span 0..96: five bins of 20.
+		{"round width", seq(0, 4, 25), 0, 100, []int{5, 5, 5, 5, 5}},
+		// The maximum sits on a bin edge and still gets a bin of its own.
+		{"max on an edge", []float64{10, 20, 30, 40, 50}, 10, 60, []int{1, 1, 1, 1, 1}},
+		{"all equal", []float64{7, 7, 7}, 7, 8, []int{3}},
+		{"empty", nil, 0, 0, nil},
+	}
+	for _, tt := range tests {
+		h := histogram(FieldTotal, tt.values)
+		var counts []int
+		for _, b := range h.Bins {
+			counts = append(counts, b.Count)
+		}
+		if !reflect.DeepEqual(counts, tt.counts) {
+			t.Errorf("%s: counts %v, want %v", tt.name, counts, tt.counts)
+			continue
+		}
+		if len(h.Bins) > 0 && (h.Bins[0].Lo != tt.lo || h.Bins[len(h.Bins)-1].Hi != tt.hi) {
+			t.Errorf("%s: bins span %g..%g, want %g..%g", tt.name, h.Bins[0].Lo, h.Bins[len(h.Bins)-1].Hi, tt.lo, tt.hi)
+		}
+	}
+}
+
+// seq returns n values from start in steps of step.
+func seq(start, step float64, n int) []float64 {
+	out := make([]float64, n)
+	for i := range out {
+		out[i] = start + float64(i)*step
+	}
+	return out
+}
+
+func TestBoxPlot(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   BoxPlot
+	}{
+		{
+			name:   "no outliers",
+			values: []float64{5, 1, 3, 2, 4},
+			want:   BoxPlot{Group: "g", Count: 5, LowerWhisker: 1, Q1: 2, Median: 3, Q3: 4, UpperWhisker: 5, Outliers: []float64{}},
+		},
+		{
+			name:   "outliers on both sides",
+			values: []float64{-20, 10, 11, 12, 13, 14, 60},
+			want:   BoxPlot{Group: "g", Count: 7, LowerWhisker: 10, Q1: 10.5, Median: 12, Q3: 13.5, UpperWhisker: 14, Outliers: []float64{-20, 60}},
+		},
+	}
+	for _, tt := range tests {
+		if got := boxPlot("g", tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestScatter(t *testing.T) {
+	students := []Student{
+		{Emplid: "1", MidSem: 10, Compre: 25},
+		{Emplid: "2", MidSem: 20, Compre: 45},
+		{Emplid: "3", MidSem: 30, Compre: 65},
+		{Emplid: "4", MidSem: 40, Compre: 85, Special: map[string]SpecialMark{FieldMidSem: {State: MarkNotApplicable, Policy: PolicyExclude}}},
+	}
+	sc := scatter(students, FieldMidSem, FieldCompre)
+	if len(sc.Points) != 3 {
+		t.Errorf("%d points, want 3 without the excluded mark", len(sc.Points))
+	}
+	if math.Abs(sc.Slope-2) > 1e-9 || math.Abs(sc.Intercept-5) > 1e-9 || math.Abs(sc.R-1) > 1e-9 {
+		t.Errorf("slope %g intercept %g r %g, want 2, 5 and 1", sc.Slope, sc.Intercept, sc.R)
+	}
+
+	flat := scatter([]Student{{MidSem: 10, Compre: 5}, {MidSem: 10, Compre: 9}}, FieldMidSem, FieldCompre)
+	if flat.Slope != 0 || flat.R != 0 || flat.Intercept != 7 {
+		t.Errorf("constant x gave slope %g r %g intercept %g", flat.Slope, flat.R, flat.Intercept)
+	}
+}
+
+func TestBuildCharts(t *testing.T) {
+	students := syntheticStudents(40)
+	data := BuildCharts(students)
+	if len(data.Histograms) != len(statComponents(students)) {
+		t.Errorf("%d histograms, want one per component", len(data.Histograms))
+	}
+	var boxed int
+	for _, b := range data.BoxPlots {
+		boxed += b.Count
+	}
+	if boxed != len(students) {
+		t.Errorf("box plots hold %d students, want %d", boxed, len(students))
+	}
+	if data.Scatter == nil || len(data.Scatter.Points) != len(students) {
+		t.Errorf("scatter %+v", data.Scatter)
+	}
+	if empty := BuildCharts(nil); len(empty.Histograms) != 0 || empty.Scatter != nil {
+		t.Errorf("charts for no students: %+v", empty)
+	}
+}
+
+func TestRenderSVG(t *testing.T) {
+	students := syntheticStudents(40)
+	students[0].Branches = []string{"R&D <dual>"}
+	charts := RenderSVG(BuildCharts(students))
+
+	var names []string
+	for _, c := range charts {
+		names = append(names, c.Name)
+		// Every chart is well-formed XML, whatever the labels hold.
+		dec := xml.NewDecoder(strings.NewReader(c.SVG))
+		for {
+			_, err := dec.Token()
+			if err != nil {
+				if err != io.EOF {
+					t.Errorf("%s: %v", c.Name, err)
+				}
+				break
+			}
+		}
+	}
+	for _, want := range []string{"histogram_Total", "boxplot_branches", "scatter_MidSem_Compre"} {
+		if !contains(names, want) {
+			t.Errorf("charts %v lack %s", names, want)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "charts")
+	paths, err := WriteSVGCharts(dir, BuildCharts(students))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != len(charts) {
+		t.Errorf("wrote %d files, want %d", len(paths), len(charts))
+	}
+	if _, err := os.Stat(filepath.Join(dir, "boxplot_branches.svg")); err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,223 @@
+package gradebook
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Canonical gradebook fields that parseStudent knows how to fill in.
+const (
+	FieldClassNo    = "ClassNo"
+	FieldEmplid     = "Emplid"
+	FieldCampusID   = "CampusID"
+	FieldQuiz       = "Quiz"
+	FieldMidSem     = "MidSem"
+	FieldLabTest    = "LabTest"
+	FieldWeeklyLabs = "WeeklyLabs"
+	FieldPreCompre  = "PreCompre"
+	FieldCompre     = "Compre"
+	FieldTotal      = "Total"
+)
+
+var canonicalFields = []string{
+	FieldClassNo, FieldEmplid, FieldCampusID,
+	FieldQuiz, FieldMidSem, FieldLabTest, FieldWeeklyLabs,
+	FieldPreCompre, FieldCompre, FieldTotal,
+}
+
+// Fields without which a sheet cannot be turned into students.
+var requiredFields = []string{FieldEmplid, FieldCampusID, FieldTotal}
+
+var defaultSynonyms = map[string][]string{
+	FieldClassNo:    {"Class No", "Class Nbr", "Class Number", "Section"},
+	FieldEmplid:     {"Emplid", "Empl ID", "Employee ID", "Student ID"},
+	FieldCampusID:   {"Campus ID", "ID No", "ID Number", "BITS ID"},
+	FieldQuiz:       {"Quiz", "Quizzes"},
+	FieldMidSem:     {"Mid Sem", "Mid-Semester", "Mid Semester", "Midterm"},
+	FieldLabTest:    {"Lab Test"},
+	FieldWeeklyLabs: {"Weekly Labs", "Weekly Lab", "Labs"},
+	FieldPreCompre:  {"Pre Compre", "Pre-Comprehensive", "Total Pre Compre"},
+	FieldCompre:     {"Compre", "Comprehensive", "Compre Exam", "End Sem"},
+	FieldTotal:      {"Total", "Grand Total", "Total Marks"},
+}
+
+// ColumnConfig is the user-supplied mapping file. Synonyms are added to the
+// built-in ones for each canonical field; Extra lists additional evaluation
+// components to pick up by header name ("*" picks up every unmapped column).
+// MaxMarks bounds each component during validation. SpecialMarks says how
+// AB, NA, -, I and blank cells are read.
+type ColumnConfig struct {
+	Synonyms     map[string][]string `json:"synonyms" yaml:"synonyms"`
+	Extra        []string            `json:"extra" yaml:"extra"`
+	MaxMarks     map[string]float64  `json:"max_marks" yaml:"max_marks"`
+	SpecialMarks SpecialMarkConfig   `json:"special_marks" yaml:"special_marks"`
+}
+
+// columnIndex records where each field was found in the header row.
+// Makeup maps components to their makeup exam column.
+type columnIndex struct {
+	HeaderRow int
+	Header    []string
+	Fields    map[string]int
+	Extra     map[string]int
+	Makeup    map[string]int
+
+	special SpecialMarkConfig
+}
+
+func LoadColumnConfig(path string) (ColumnConfig, error) {
+	var cfg ColumnConfig
+	if path == "" {
+		return cfg, nil
+	}
+	if err := LoadConfigFile(path, &cfg); err != nil {
+		return cfg, err
+	}
+	for field := range cfg.Synonyms {
+		if !isCanonicalField(field) {
+			return cfg, fmt.Errorf("%s: unknown field %q in synonyms", path, field)
+		}
+	}
+	if err := cfg.SpecialMarks.check(); err != nil {
+		return cfg, fmt.Errorf("%s: special_marks: %w", path, err)
+	}
+	return cfg, nil
+}
+
+func isCanonicalField(field string) bool {
+	for _, f := range canonicalFields {
+		if f == field {
+			return true
+		}
+	}
+	return false
+}
+
+// normalizeHeader folds case and drops punctuation so that "Mid Sem",
+// "MidSem" and "mid_sem" all compare equal.
+func normalizeHeader(h string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(h) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			b.WriteRune(r)
+		}
+	}
+	return b.String()
+}
+
+// lookup returns a normalized header -> canonical field table.
+func (cfg ColumnConfig) lookup() map[string]string {
+	table := make(map[string]string)
+	for _, field := range canonicalFields {
+		table[normalizeHeader(field)] = field
+		for _, name := range defaultSynonyms[field] {
+			table[normalizeHeader(name)] = field
+		}
+	}
+	for field, names := range cfg.Synonyms {
+		for _, name := range names {
+			table[normalizeHeader(name)] = field
+		}
+	}
+	return table
+}
+
+// indexHeader maps a single header row onto canonical fields and extras.
+func (cfg ColumnConfig) indexHeader(header []string) columnIndex {
+	table := cfg.lookup()
+	idx := columnIndex{
+		Header: header, Fields: make(map[string]int), Extra: make(map[string]int), Makeup: make(map[string]int),
+		special: cfg.SpecialMarks,
+	}
+	makeup := make(map[string]string)
+	for component, name := range cfg.SpecialMarks.Makeup {
+		makeup[normalizeHeader(name)] = component
+	}
+
+	wantExtra := make(map[string]string)
+	allExtra := false
+	for _, name := range cfg.Extra {
+		if name == "*" {
+			allExtra = true
+			continue
+		}
+		wantExtra[normalizeHeader(name)] = name
+	}
+
+	for i, h := range header {
+		key := normalizeHeader(h)
+		if key == "" {
+			continue
+		}
+		if component, ok := makeup[key]; ok {
+			idx.Makeup[component] = i
+			continue
+		}
+		if field, ok := table[key]; ok {
+			if _, seen := idx.Fields[field]; !seen {
+				idx.Fields[field] = i
+			}
+			continue
+		}
+		if name, ok := wantExtra[key]; ok {
+			idx.Extra[name] = i
+		} else if allExtra {
+			idx.Extra[strings.TrimSpace(h)] = i
+		}
+	}
+	return idx
+}
+
+// headerSearchRows is how far down a sheet the header row may be.
+const headerSearchRows = 10
+
+// findColumns scans the first few rows for the one that looks most like a
+// header, since exported gradebooks sometimes carry a title row or two.
+func findColumns(rows [][]string, cfg ColumnConfig) (columnIndex, error) {
+	best := columnIndex{HeaderRow: -1}
+	for i := 0; i < len(rows) && i < headerSearchRows; i++ {
+		idx := cfg.indexHeader(rows[i])
+		idx.HeaderRow = i
+		if len(idx.Fields) > len(best.Fields) {
+			best = idx
+		}
+	}
+	if best.HeaderRow < 0 {
+		return best, fmt.Errorf("no header row found")
+	}
+
+	var missing []string
+	for _, field := range requiredFields {
+		if _, ok := best.Fields[field]; !ok {
+			missing = append(missing, field)
+		}
+	}
+	if len(missing) > 0 {
+		return best, fmt.Errorf("header row %d is missing required columns: %s",
+			best.HeaderRow+1, strings.Join(missing, ", "))
+	}
+	return best, nil
+}
+
+// cell returns the named field of row, or "" when the column is absent or
+// the row is short.
+func (idx columnIndex) cell(row []string, field string) string {
+	i, ok := idx.Fields[field]
+	if !ok || i >= len(row) {
+		return ""
+	}
+	return strings.TrimSpace(row[i])
+}
+
+// makeup reads the makeup exam score for component. It returns "" and ok
+// false when there is no makeup column, and the cell text with ok false
+// when the cell does not hold a number.
+func (idx columnIndex) makeup(row []string, component string) (float64, string, bool) {
+	i, ok := idx.Makeup[component]
+	if !ok || i >= len(row) {
+		return 0, "", false
+	}
+	raw := strings.TrimSpace(row[i])
+	v, err := parseFloat(raw)
+	return v, raw, err == nil
+}
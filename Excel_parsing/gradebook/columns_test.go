@@ -0,0 +1,152 @@
+package gradebook
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestNormalizeHeader(t *testing.T) {
+	for in, want := range map[string]string{
+		"Mid Sem":     "midsem",
+		"MidSem":      "midsem",
+		"mid_sem":     "midsem",
+		" Class No. ": "classno",
+		"Quiz 2":      "quiz2",
+		"---":         "",
+	} {
+		if got := normalizeHeader(in); got != want {
+			t.Errorf("normalizeHeader(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestIndexHeader(t *testing.T) {
+	tests := []struct {
+		name   string
+		cfg    ColumnConfig
+		header []string
+		fields map[string]int
+		extra  map[string]int
+	}{
+		{
+			name:   "canonical names",
+			header: []string{"Emplid", "Campus ID", "MidSem", "Compre", "Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldCampusID: 1, FieldMidSem: 2, FieldCompre: 3, FieldTotal: 4},
+			extra:  map[string]int{},
+		},
+		{
+			name:   "built-in synonyms",
+			header: []string{"Student ID", "BITS ID", "Mid-Semester", "End Sem", "Grand Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldCampusID: 1, FieldMidSem: 2, FieldCompre: 3, FieldTotal: 4},
+			extra:  map[string]int{},
+		},
+		{
+			name:   "configured synonyms",
+			cfg:    ColumnConfig{Synonyms: map[string][]string{FieldMidSem: {"Test 1"}}},
+			header: []string{"Emplid", "TEST-1", "Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldMidSem: 1, FieldTotal: 2},
+			extra:  map[string]int{},
+		},
+		{
+			name:   "first match wins",
+			header: []string{"Emplid", "Total", "Grand Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldTotal: 1},
+			extra:  map[string]int{},
+		},
+		{
+			name:   "named extras",
+			cfg:    ColumnConfig{Extra: []string{"Project", "Viva"}},
+			header: []string{"Emplid", "project", "Attendance", "Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldTotal: 3},
+			extra:  map[string]int{"Project": 1},
+		},
+		{
+			name:   "every unmapped column",
+			cfg:    ColumnConfig{Extra: []string{"*"}},
+			header: []string{"Emplid", " Project ", "", "Total"},
+			fields: map[string]int{FieldEmplid: 0, FieldTotal: 3},
+			extra:  map[string]int{"Project": 1},
+		},
+	}
+	for _, tt := range tests {
+		idx := tt.cfg.indexHeader(tt.header)
+		if !reflect.DeepEqual(idx.Fields, tt.fields) {
+			t.Errorf("%s: fields %v, want %v", tt.name, idx.Fields, tt.fields)
+		}
+		if !reflect.DeepEqual(idx.Extra, tt.extra) {
+			t.Errorf("%s: extra %v, want %v", tt.name, idx.Extra, tt.extra)
+		}
+	}
+}
+
+func TestFindColumns(t *testing.T) {
+	tests := []struct {
+		name      string
+		rows      [][]string
+		headerRow int
+		err       bool
+	}{
+		{"first row", [][]string{{"Emplid", "Campus ID", "Total"}, {"1", "2022A7PS0001P", "50"}}, 0, false},
+		{"below a title", [][]string{{"CS F111 marks"}, {}, {"Emplid", "Campus ID", "Total"}}, 2, false},
+		{"missing Total", [][]string{{"Emplid", "Campus ID", "Compre"}}, 0, true},
+		{"empty sheet", nil, -1, true},
+	}
+	for _, tt := range tests {
+		idx, err := findColumns(tt.rows, ColumnConfig{})
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+		}
+		if idx.HeaderRow != tt.headerRow {
+			t.Errorf("%s: header row %d, want %d", tt.name, idx.HeaderRow, tt.headerRow)
+		}
+	}
+}
+
+func TestLoadColumnConfig(t *testing.T) {
+	tests := []struct {
+		name, file, content string
+		want                ColumnConfig
+		err                 bool
+	}{
+		{
+			name: "json", file: "columns.json",
+			content: `{"synonyms": {"MidSem": ["Test 1"]}, "extra": ["Project"], "max_marks": {"Total": 200}}`,
+			want: ColumnConfig{
+				Synonyms: map[string][]string{FieldMidSem: {"Test 1"}},
+				Extra:    []string{"Project"},
+				MaxMarks: map[string]float64{FieldTotal: 200},
+			},
+		},
+		{
+			name: "yaml", file: "columns.yaml",
+			content: "synonyms:\n  Compre: [Final]\nspecial_marks:\n  markers:\n    ML: absent\n",
+			want: ColumnConfig{
+				Synonyms:     map[string][]string{FieldCompre: {"Final"}},
+				SpecialMarks: SpecialMarkConfig{Markers: map[string]string{"ML": MarkAbsent}},
+			},
+		},
+		{name: "unknown field", file: "columns.json", content: `{"synonyms": {"Midterm": ["Test 1"]}}`, err: true},
+		{name: "unknown marker state", file: "columns.yml", content: "special_marks:\n  markers:\n    ML: sick\n", err: true},
+		{name: "malformed", file: "columns.json", content: `{"synonyms":`, err: true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), tt.file)
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadColumnConfig(path)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(cfg, tt.want) {
+			t.Errorf("%s: loaded %+v, want %+v", tt.name, cfg, tt.want)
+		}
+	}
+
+	if cfg, err := LoadColumnConfig(""); err != nil || !reflect.DeepEqual(cfg, ColumnConfig{}) {
+		t.Errorf("no config file gave %+v, %v", cfg, err)
+	}
+}
@@ -0,0 +1,293 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// CorrelationReport holds the Pearson and Spearman correlations between
+// every pair of evaluation components, in the order of Components, and a
+// linear model predicting Compre from the pre-compre components.
+type CorrelationReport struct {
+	Components []string    `json:"components"`
+	Pearson    [][]float64 `json:"pearson"`
+	Spearman   [][]float64 `json:"spearman"`
+	Regression *Regression `json:"regression,omitempty"`
+}
+
+// Regression is an ordinary least squares fit of Response on Predictors.
+// Standardized coefficients are in standard deviations of the response per
+// standard deviation of the predictor, so they compare across components
+// marked out of different totals.
+type Regression struct {
+	Response     string                  `json:"response"`
+	Count        int                     `json:"count"`
+	Intercept    float64                 `json:"intercept"`
+	Coefficients []RegressionCoefficient `json:"coefficients"`
+	RSquared     float64                 `json:"r_squared"`
+	AdjRSquared  float64                 `json:"adjusted_r_squared"`
+	ResidualSD   float64                 `json:"residual_sd"`
+}
+
+type RegressionCoefficient struct {
+	Predictor    string  `json:"predictor"`
+	Estimate     float64 `json:"estimate"`
+	StdError     float64 `json:"std_error"`
+	T            float64 `json:"t"`
+	Standardized float64 `json:"standardized"`
+}
+
+// Correlations computes the correlation matrices over the evaluation
+// components present on students and regresses Compre on the components
+// marked before it. PreCompre is left out of the predictors as it is
+// their sum. The regression is omitted when there are too few students or
+// the predictors are collinear.
+func Correlations(students []Student) CorrelationReport {
+	var components []string
+	for _, c := range statComponents(students) {
+		if c == FieldTotal || c == FieldRecomputedTotal || c == FieldNormalizedTotal {
+			continue
+		}
+		if _, sd := meanSD(componentValues(students, c)); sd > 0 {
+			components = append(components, c)
+		}
+	}
+	report := CorrelationReport{Components: components, Pearson: [][]float64{}, Spearman: [][]float64{}}
+	for _, a := range components {
+		pearsonRow := make([]float64, len(components))
+		spearmanRow := make([]float64, len(components))
+		for j, b := range components {
+			xs, ys := pairedValues(students, a, b)
+			pearsonRow[j] = pearson(xs, ys)
+			spearmanRow[j] = pearson(ranks(xs), ranks(ys))
+		}
+		report.Pearson = append(report.Pearson, pearsonRow)
+		report.Spearman = append(report.Spearman, spearmanRow)
+	}
+
+	var predictors []string
+	for _, c := range components {
+		if c != FieldPreCompre && c != FieldCompre {
+			predictors = append(predictors, c)
+		}
+	}
+	if contains(components, FieldCompre) && len(predictors) > 0 {
+		report.Regression = regress(students, FieldCompre, predictors)
+	}
+	return report
+}
+
+// pairedValues returns a and b for the students that have both.
+func pairedValues(students []Student, a, b string) ([]float64, []float64) {
+	var xs, ys []float64
+	for _, s := range students {
+		x, okX := s.Component(a)
+		y, okY := s.Component(b)
+		if okX && okY {
+			xs, ys = append(xs, x), append(ys, y)
+		}
+	}
+	return xs, ys
+}
+
+func pearson(xs, ys []float64) float64 {
+	mx, sx := meanSD(xs)
+	my, sy := meanSD(ys)
+	if sx == 0 || sy == 0 {
+		return 0
+	}
+	var cov float64
+	for i := range xs {
+		cov += (xs[i] - mx) * (ys[i] - my)
+	}
+	return cov / float64(len(xs)) / (sx * sy)
+}
+
+// ranks replaces values by their ranks, giving ties the average rank.
+func ranks(values []float64) []float64 {
+	order := make([]int, len(values))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool { return values[order[i]] < values[order[j]] })
+	out := make([]float64, len(values))
+	for i := 0; i < len(order); {
+		j := i
+		for j < len(order) && values[order[j]] == values[order[i]] {
+			j++
+		}
+		avg := float64(i+j+1) / 2
+		for k := i; k < j; k++ {
+			out[order[k]] = avg
+		}
+		i = j
+	}
+	return out
+}
+
+// regress fits response on predictors by solving the normal equations.
+func regress(students []Student, response string, predictors []string) *Regression {
+	p := len(predictors) + 1
+	var x [][]float64
+	var y []float64
+	for _, s := range students {
+		row := []float64{1}
+		ok := true
+		for _, c := range predictors {
+			v, has := s.Component(c)
+			ok = ok && has
+			row = append(row, v)
+		}
+		v, has := s.Component(response)
+		if ok && has {
+			x, y = append(x, row), append(y, v)
+		}
+	}
+	n := len(y)
+	if n <= p {
+		return nil
+	}
+
+	xtx := make([][]float64, p)
+	xty := make([]float64, p)
+	for i := range xtx {
+		xtx[i] = make([]float64, p)
+	}
+	for r := range x {
+		for i := 0; i < p; i++ {
+			xty[i] += x[r][i] * y[r]
+			for j := 0; j < p; j++ {
+				xtx[i][j] += x[r][i] * x[r][j]
+			}
+		}
+	}
+	inv, err := invert(xtx)
+	if err != nil {
+		return nil
+	}
+	beta := make([]float64, p)
+	for i := range beta {
+		for j := range xty {
+			beta[i] += inv[i][j] * xty[j]
+		}
+	}
+
+	my, sy := meanSD(y)
+	var rss, tss float64
+	for r := range x {
+		fitted := 0.0
+		for i := range beta {
+			fitted += beta[i] * x[r][i]
+		}
+		rss += (y[r] - fitted) * (y[r] - fitted)
+		tss += (y[r] - my) * (y[r] - my)
+	}
+	sigma2 := rss / float64(n-p)
+	reg := &Regression{
+		Response:     response,
+		Count:        n,
+		Intercept:    beta[0],
+		Coefficients: []RegressionCoefficient{},
+		ResidualSD:   math.Sqrt(sigma2),
+	}
+	if tss > 0 {
+		reg.RSquared = 1 - rss/tss
+		reg.AdjRSquared = 1 - (rss/float64(n-p))/(tss/float64(n-1))
+	}
+	for i, c := range predictors {
+		k := i + 1
+		coef := RegressionCoefficient{Predictor: c, Estimate: beta[k], StdError: math.Sqrt(sigma2 * inv[k][k])}
+		if coef.StdError > 0 {
+			coef.T = coef.Estimate / coef.StdError
+		}
+		if _, sx := meanSD(column(x, k)); sy > 0 {
+			coef.Standardized = beta[k] * sx / sy
+		}
+		reg.Coefficients = append(reg.Coefficients, coef)
+	}
+	return reg
+}
+
+// column returns the k-th column of a design matrix.
+func column(x [][]float64, k int) []float64 {
+	out := make([]float64, len(x))
+	for r := range x {
+		out[r] = x[r][k]
+	}
+	return out
+}
+
+// invert inverts a symmetric matrix by Gauss-Jordan elimination with
+// partial pivoting.
+func invert(m [][]float64) ([][]float64, error) {
+	n := len(m)
+	a := make([][]float64, n)
+	for i := range m {
+		a[i] = make([]float64, 2*n)
+		copy(a[i], m[i])
+		a[i][n+i] = 1
+	}
+	for col := 0; col < n; col++ {
+		pivot := col
+		for r := col + 1; r < n; r++ {
+			if math.Abs(a[r][col]) > math.Abs(a[pivot][col]) {
+				pivot = r
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-9*math.Max(1, math.Abs(m[col][col])) {
+			return nil, fmt.Errorf("matrix is singular")
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+		d := a[col][col]
+		for j := range a[col] {
+			a[col][j] /= d
+		}
+		for r := 0; r < n; r++ {
+			if r == col || a[r][col] == 0 {
+				continue
+			}
+			f := a[r][col]
+			for j := range a[r] {
+				a[r][j] -= f * a[col][j]
+			}
+		}
+	}
+	inv := make([][]float64, n)
+	for i := range a {
+		inv[i] = a[i][n:]
+	}
+	return inv, nil
+}
+
+func correlationTables(r *CorrelationReport) []Table {
+	matrix := func(name, title string, values [][]float64) Table {
+		t := Table{Name: name, Title: title, Header: append([]string{"Component"}, r.Components...)}
+		for i, c := range r.Components {
+			row := []interface{}{c}
+			for _, v := range values[i] {
+				row = append(row, v)
+			}
+			t.Rows = append(t.Rows, row)
+		}
+		return t
+	}
+	tables := []Table{
+		matrix("correlation_pearson", "Correlation: Pearson", r.Pearson),
+		matrix("correlation_spearman", "Correlation: Spearman", r.Spearman),
+	}
+	if reg := r.Regression; reg != nil {
+		t := Table{
+			Name: "regression",
+			Title: fmt.Sprintf("Regression: %s (n=%d, R² %.3f, adjusted %.3f, residual SD %.2f)",
+				reg.Response, reg.Count, reg.RSquared, reg.AdjRSquared, reg.ResidualSD),
+			Header: []string{"Term", "Estimate", "Std Error", "t", "Standardized"},
+			Rows:   [][]interface{}{{"(Intercept)", reg.Intercept, "", "", ""}},
+		}
+		for _, c := range reg.Coefficients {
+			t.Rows = append(t.Rows, []interface{}{c.Predictor, c.Estimate, c.StdError, c.T, c.Standardized})
+		}
+		tables = append(tables, t)
+	}
+	return tables
+}
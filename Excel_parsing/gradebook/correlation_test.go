@@ -0,0 +1,117 @@
+package gradebook
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+func TestRanks(t *testing.T) {
+	tests := []struct {
+		values, want []float64
+	}{
+		{[]float64{30, 10, 20}, []float64{3, 1, 2}},
+		{[]float64{5, 5, 1}, []float64{2.5, 2.5, 1}},
+		{[]float64{7, 7, 7}, []float64{2, 2, 2}},
+		{nil, []float64{}},
+	}
+	for _, tt := range tests {
+		if got := ranks(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ranks(%v) = %v, want %v", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestPearson(t *testing.T) {
+	tests := []struct {
+		name   string
+		xs, ys []float64
+		want   float64
+	}{
+		{"increasing", []float64{1, 2, 3, 4}, []float64{2, 4, 6, 8}, 1},
+		{"decreasing", []float64{1, 2, 3, 4}, []float64{8, 6, 4, 2}, -1},
+		{"uncorrelated", []float64{1, 2, 3, 4}, []float64{1, -1, -1, 1}, 0},
+		{"constant", []float64{1, 2, 3}, []float64{5, 5, 5}, 0},
+	}
+	for _, tt := range tests {
+		if got := pearson(tt.xs, tt.ys); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("%s: pearson = %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+// regressionStudents have Compre = 5 + 2 Quiz - 0.5 MidSem exactly.
+func regressionStudents() []Student {
+	var students []Student
+	for i := 0; i < 12; i++ {
+		quiz, mid := float64(i), float64((i*i)%7)
+		students = append(students, Student{Emplid: string(rune('a' + i)), Quiz: quiz, MidSem: mid, Compre: 5 + 2*quiz - 0.5*mid})
+	}
+	return students
+}
+
+func TestRegressExactFit(t *testing.T) {
+	reg := regress(regressionStudents(), FieldCompre, []string{FieldQuiz, FieldMidSem})
+	if reg == nil {
+		t.Fatal("no regression")
+	}
+	if reg.Count != 12 || math.Abs(reg.Intercept-5) > 1e-9 || math.Abs(reg.RSquared-1) > 1e-9 {
+		t.Errorf("n=%d intercept %g R² %g, want n=12 intercept 5 R² 1", reg.Count, reg.Intercept, reg.RSquared)
+	}
+	for i, want := range []float64{2, -0.5} {
+		if got := reg.Coefficients[i].Estimate; math.Abs(got-want) > 1e-9 {
+			t.Errorf("%s coefficient %g, want %g", reg.Coefficients[i].Predictor, got, want)
+		}
+	}
+}
+
+// TestRegressStandardizedUsesFitSample checks that the predictor SD is
+// taken over the students in the fit, not everyone with that predictor.
+func TestRegressStandardizedUsesFitSample(t *testing.T) {
+	students := regressionStudents()
+	fitted := append([]Student(nil), students...)
+	// An outlying Quiz from a student whose Compre is excluded must not
+	// change the standardized coefficients.
+	students = append(students, Student{
+		Emplid: "z", Quiz: 1000, MidSem: 3,
+		Special: map[string]SpecialMark{FieldCompre: {Marker: "NA", State: MarkNotApplicable, Policy: PolicyExclude}},
+	})
+
+	want := regress(fitted, FieldCompre, []string{FieldQuiz, FieldMidSem})
+	got := regress(students, FieldCompre, []string{FieldQuiz, FieldMidSem})
+	if got.Count != want.Count {
+		t.Fatalf("fit %d students, want %d", got.Count, want.Count)
+	}
+	for i := range want.Coefficients {
+		g, w := got.Coefficients[i].Standardized, want.Coefficients[i].Standardized
+		if math.Abs(g-w) > 1e-9 {
+			t.Errorf("%s standardized %g, want %g", want.Coefficients[i].Predictor, g, w)
+		}
+	}
+	_, sx := meanSD(componentValues(fitted, FieldQuiz))
+	_, sy := meanSD(componentValues(fitted, FieldCompre))
+	if s := got.Coefficients[0].Standardized; math.Abs(s-2*sx/sy) > 1e-9 {
+		t.Errorf("Quiz standardized %g, want %g", s, 2*sx/sy)
+	}
+}
+
+func TestRegressTooFewStudents(t *testing.T) {
+	if reg := regress(regressionStudents()[:3], FieldCompre, []string{FieldQuiz, FieldMidSem}); reg != nil {
+		t.Errorf("fit %+v from 3 students and 3 terms", reg)
+	}
+}
+
+func TestCorrelationsSkipsConstantComponents(t *testing.T) {
+	r := Correlations(regressionStudents())
+	if want := []string{FieldQuiz, FieldMidSem, FieldCompre}; !reflect.DeepEqual(r.Components, want) {
+		t.Errorf("components %v, want %v", r.Components, want)
+	}
+	for i := range r.Components {
+		if math.Abs(r.Pearson[i][i]-1) > 1e-12 || math.Abs(r.Spearman[i][i]-1) > 1e-12 {
+			t.Errorf("%s does not correlate with itself", r.Components[i])
+		}
+	}
+	if r.Regression == nil {
+		t.Error("no regression of Compre")
+	}
+}
@@ -0,0 +1,317 @@
+package gradebook
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"text/tabwriter"
+)
+
+// Diff is what changed between two versions of a gradebook, matching
+// students by Emplid. Ranks and grades are worked out for each version
+// with the same options. Changed lists the students whose own marks or ID
+// fields changed; Moved lists those whose rank or grade changed only
+// because others' marks did. Quarantined lists the students with a row
+// that validation left out of either version; they are not counted as
+// added or removed.
+type Diff struct {
+	Old         string             `json:"old"`
+	New         string             `json:"new"`
+	RankedBy    string             `json:"ranked_by"`
+	Summary     DiffSummary        `json:"summary"`
+	Added       []DiffStudent      `json:"added"`
+	Removed     []DiffStudent      `json:"removed"`
+	Changed     []StudentChange    `json:"changed"`
+	Moved       []StudentChange    `json:"moved"`
+	Quarantined []QuarantineChange `json:"quarantined"`
+}
+
+// Version is one side of a comparison: a name for it, the students loaded
+// and the validation report of loading them.
+type Version struct {
+	Name       string
+	Students   []Student
+	Validation ValidationReport
+}
+
+// DiffSummary counts the changes. Changed counts students with a mark or
+// field change; RankChanges and GradeChanges count every student whose
+// rank or grade moved, whether or not their own marks did.
+type DiffSummary struct {
+	OldStudents    int `json:"old_students"`
+	NewStudents    int `json:"new_students"`
+	Added          int `json:"added"`
+	Removed        int `json:"removed"`
+	Changed        int `json:"changed"`
+	MarkChanges    int `json:"mark_changes"`
+	RankChanges    int `json:"rank_changes"`
+	GradeChanges   int `json:"grade_changes"`
+	OldQuarantined int `json:"old_quarantined"`
+	NewQuarantined int `json:"new_quarantined"`
+}
+
+// DiffStudent is a student present in only one version.
+type DiffStudent struct {
+	Emplid   string  `json:"emplid"`
+	CampusID string  `json:"campus_id"`
+	Total    float64 `json:"total"`
+	Rank     int     `json:"rank"`
+	Grade    string  `json:"grade,omitempty"`
+}
+
+// StudentChange is a student present in both versions whose marks, ID
+// fields, rank or grade differ. A rank of 0 means unranked.
+type StudentChange struct {
+	Emplid   string        `json:"emplid"`
+	CampusID string        `json:"campus_id"`
+	Fields   []FieldChange `json:"fields,omitempty"`
+	Marks    []MarkChange  `json:"marks,omitempty"`
+	OldRank  int           `json:"old_rank"`
+	NewRank  int           `json:"new_rank"`
+	OldGrade string        `json:"old_grade,omitempty"`
+	NewGrade string        `json:"new_grade,omitempty"`
+}
+
+// QuarantineChange is a student with a row quarantined in either version.
+// Old and New are those rows; Ranked is the student as ranked in the other
+// version when only one version used them.
+type QuarantineChange struct {
+	Emplid string          `json:"emplid"`
+	Old    *QuarantinedRow `json:"old,omitempty"`
+	New    *QuarantinedRow `json:"new,omitempty"`
+	Ranked *DiffStudent    `json:"ranked,omitempty"`
+}
+
+type FieldChange struct {
+	Field string `json:"field"`
+	Old   string `json:"old"`
+	New   string `json:"new"`
+}
+
+type MarkChange struct {
+	Component string  `json:"component"`
+	Old       float64 `json:"old"`
+	New       float64 `json:"new"`
+	Delta     float64 `json:"delta"`
+}
+
+// markEpsilon absorbs floating point noise when comparing marks.
+const markEpsilon = 1e-9
+
+// Compare ranks and grades both versions with opts and lists what changed
+// from before to after. Students are listed in order of their rank, and
+// quarantined rows in the order they were read.
+func Compare(before, after Version, opts Options) (Diff, error) {
+	opts.Rank.Top = 0
+	opts.Charts, opts.Anomalies, opts.Correlations = false, nil, false
+	oldReport, err := Generate(before.Students, opts)
+	if err != nil {
+		return Diff{}, fmt.Errorf("%s: %w", before.Name, err)
+	}
+	newReport, err := Generate(after.Students, opts)
+	if err != nil {
+		return Diff{}, fmt.Errorf("%s: %w", after.Name, err)
+	}
+
+	d := Diff{
+		Old: before.Name, New: after.Name, RankedBy: newReport.RankedBy,
+		Added: []DiffStudent{}, Removed: []DiffStudent{}, Changed: []StudentChange{}, Moved: []StudentChange{},
+		Quarantined: []QuarantineChange{},
+	}
+	d.Summary.OldStudents = len(oldReport.OverallTopStudents)
+	d.Summary.NewStudents = len(newReport.OverallTopStudents)
+	d.Summary.OldQuarantined = len(before.Validation.Quarantined)
+	d.Summary.NewQuarantined = len(after.Validation.Quarantined)
+
+	// Quarantined rows are matched by Emplid too; rows without one cannot
+	// be matched and are listed on their own.
+	quarantined := make(map[string]int)
+	quarantine := func(q QuarantinedRow, old bool) {
+		i, ok := quarantined[q.Emplid]
+		if !ok || q.Emplid == "" {
+			i = len(d.Quarantined)
+			quarantined[q.Emplid] = i
+			d.Quarantined = append(d.Quarantined, QuarantineChange{Emplid: q.Emplid})
+		}
+		c := &d.Quarantined[i]
+		switch {
+		case old && c.Old == nil:
+			c.Old = &q
+		case !old && c.New == nil:
+			c.New = &q
+		}
+	}
+	for _, q := range before.Validation.Quarantined {
+		quarantine(q, true)
+	}
+	for _, q := range after.Validation.Quarantined {
+		quarantine(q, false)
+	}
+	// quarantinedRanked records r against its quarantined row, if it has
+	// one, instead of listing it as added or removed.
+	quarantinedRanked := func(r RankedStudent) bool {
+		i, ok := quarantined[r.Emplid]
+		if !ok || r.Emplid == "" {
+			return false
+		}
+		ds := diffStudent(r)
+		d.Quarantined[i].Ranked = &ds
+		return true
+	}
+
+	oldByID := make(map[string]RankedStudent, len(oldReport.OverallTopStudents))
+	for _, r := range oldReport.OverallTopStudents {
+		oldByID[r.Emplid] = r
+	}
+	newByID := make(map[string]bool, len(newReport.OverallTopStudents))
+	components := mergeComponents(statComponents(before.Students), statComponents(after.Students))
+	for _, n := range newReport.OverallTopStudents {
+		newByID[n.Emplid] = true
+		o, ok := oldByID[n.Emplid]
+		if !ok {
+			if !quarantinedRanked(n) {
+				d.Added = append(d.Added, diffStudent(n))
+			}
+			continue
+		}
+		c := StudentChange{
+			Emplid: n.Emplid, CampusID: n.CampusID,
+			OldRank: o.Rank, NewRank: n.Rank, OldGrade: o.Grade, NewGrade: n.Grade,
+		}
+		for _, f := range []struct{ name, old, new string }{
+			{FieldCampusID, o.CampusID, n.CampusID},
+			{FieldClassNo, o.ClassNo, n.ClassNo},
+		} {
+			if f.old != f.new {
+				c.Fields = append(c.Fields, FieldChange{Field: f.name, Old: f.old, New: f.new})
+			}
+		}
+		for _, comp := range components {
+			ov, _ := o.Component(comp)
+			nv, _ := n.Component(comp)
+			if math.Abs(nv-ov) > markEpsilon {
+				c.Marks = append(c.Marks, MarkChange{Component: comp, Old: ov, New: nv, Delta: nv - ov})
+			}
+		}
+		if c.OldRank != c.NewRank {
+			d.Summary.RankChanges++
+		}
+		if c.OldGrade != c.NewGrade {
+			d.Summary.GradeChanges++
+		}
+		switch {
+		case len(c.Fields) > 0 || len(c.Marks) > 0:
+			d.Changed = append(d.Changed, c)
+			d.Summary.MarkChanges += len(c.Marks)
+		case c.OldRank != c.NewRank || c.OldGrade != c.NewGrade:
+			d.Moved = append(d.Moved, c)
+		}
+	}
+	for _, o := range oldReport.OverallTopStudents {
+		if !newByID[o.Emplid] && !quarantinedRanked(o) {
+			d.Removed = append(d.Removed, diffStudent(o))
+		}
+	}
+	d.Summary.Added, d.Summary.Removed, d.Summary.Changed = len(d.Added), len(d.Removed), len(d.Changed)
+	return d, nil
+}
+
+func diffStudent(r RankedStudent) DiffStudent {
+	return DiffStudent{Emplid: r.Emplid, CampusID: r.CampusID, Total: r.Total, Rank: r.Rank, Grade: r.Grade}
+}
+
+// mergeComponents returns a followed by the names in b it lacks.
+func mergeComponents(a, b []string) []string {
+	out := append([]string(nil), a...)
+	for _, name := range b {
+		if !contains(out, name) {
+			out = append(out, name)
+		}
+	}
+	return out
+}
+
+// PrintDiff renders d as plain text on w.
+func PrintDiff(w io.Writer, d Diff) {
+	s := d.Summary
+	fmt.Fprintf(w, "Diff %s -> %s (ranked by %s)\n", d.Old, d.New, d.RankedBy)
+	fmt.Fprintf(w, "Students: %d -> %d, %d added, %d removed, %d changed (%d marks), %d rank changes, %d grade changes\n",
+		s.OldStudents, s.NewStudents, s.Added, s.Removed, s.Changed, s.MarkChanges, s.RankChanges, s.GradeChanges)
+	fmt.Fprintf(w, "Quarantined rows: %d -> %d\n", s.OldQuarantined, s.NewQuarantined)
+
+	students := func(title string, list []DiffStudent) {
+		if len(list) == 0 {
+			return
+		}
+		fmt.Fprintf(w, "\n%s\n", title)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(tw, "Emplid\tCampus ID\tTotal\tRank\tGrade\t")
+		for _, st := range list {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", st.Emplid, st.CampusID, FormatCell(st.Total), rankText(st.Rank), st.Grade)
+		}
+		tw.Flush()
+	}
+	students("Added", d.Added)
+	students("Removed", d.Removed)
+
+	printChanges(w, "Changed", d.Changed)
+	printChanges(w, "Rank or Grade Changed Only", d.Moved)
+
+	if len(d.Quarantined) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\nQuarantined\n")
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Emplid\tOld Row\tNew Row\tRanked\t")
+	row := func(q *QuarantinedRow) string {
+		if q == nil {
+			return ""
+		}
+		return fmt.Sprintf("%s row %d", q.Sheet, q.Row)
+	}
+	for _, q := range d.Quarantined {
+		ranked := ""
+		if r := q.Ranked; r != nil {
+			ranked = fmt.Sprintf("%s, rank %s", FormatCell(r.Total), rankText(r.Rank))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t\n", q.Emplid, row(q.Old), row(q.New), ranked)
+	}
+	tw.Flush()
+}
+
+func printChanges(w io.Writer, title string, list []StudentChange) {
+	if len(list) == 0 {
+		return
+	}
+	fmt.Fprintf(w, "\n%s\n", title)
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(tw, "Emplid\tCampus ID\tRank\tGrade\tChanges\t")
+	for _, c := range list {
+		rank := rankText(c.NewRank)
+		if c.OldRank != c.NewRank {
+			rank = rankText(c.OldRank) + " -> " + rankText(c.NewRank)
+		}
+		grade := c.NewGrade
+		if c.OldGrade != c.NewGrade {
+			grade = fmt.Sprintf("%s -> %s", c.OldGrade, c.NewGrade)
+		}
+		var changes []string
+		for _, f := range c.Fields {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s", f.Field, f.Old, f.New))
+		}
+		for _, m := range c.Marks {
+			changes = append(changes, fmt.Sprintf("%s %s -> %s (%+.2f)", m.Component, FormatCell(m.Old), FormatCell(m.New), m.Delta))
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t\n", c.Emplid, c.CampusID, rank, grade, strings.Join(changes, ", "))
+	}
+	tw.Flush()
+}
+
+// rankText renders a rank, where 0 is an unranked student.
+func rankText(rank int) string {
+	if rank == 0 {
+		return "unranked"
+	}
+	return fmt.Sprint(rank)
+}
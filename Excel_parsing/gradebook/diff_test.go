@@ -0,0 +1,127 @@
+package gradebook
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func diffVersion(name string, students []Student, quarantined ...QuarantinedRow) Version {
+	return Version{Name: name, Students: students, Validation: ValidationReport{Quarantined: quarantined}}
+}
+
+func TestCompare(t *testing.T) {
+	before := []Student{
+		{Emplid: "1", CampusID: "2022A7PS0001P", ClassNo: "1", Compre: 40, Total: 90},
+		{Emplid: "2", CampusID: "2022A7PS0002P", ClassNo: "1", Compre: 30, Total: 80},
+		{Emplid: "3", CampusID: "2022A7PS0003P", ClassNo: "1", Compre: 20, Total: 70},
+		{Emplid: "4", CampusID: "2022A7PS0004P", ClassNo: "1", Compre: 10, Total: 60},
+	}
+	after := []Student{
+		// 3 overtakes 2, who only moves.
+		{Emplid: "1", CampusID: "2022A7PS0001P", ClassNo: "1", Compre: 40, Total: 90},
+		{Emplid: "2", CampusID: "2022A7PS0002P", ClassNo: "1", Compre: 30, Total: 80},
+		{Emplid: "3", CampusID: "2022A7PS0003P", ClassNo: "2", Compre: 35, Total: 85},
+		{Emplid: "5", CampusID: "2022A7PS0005P", ClassNo: "1", Compre: 5, Total: 50},
+	}
+	d, err := Compare(diffVersion("old", before), diffVersion("new", after), Options{Rank: DefaultRankOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(d.Added) != 1 || d.Added[0].Emplid != "5" {
+		t.Errorf("added %+v, want 5", d.Added)
+	}
+	if len(d.Removed) != 1 || d.Removed[0].Emplid != "4" {
+		t.Errorf("removed %+v, want 4", d.Removed)
+	}
+	if len(d.Changed) != 1 || d.Changed[0].Emplid != "3" {
+		t.Fatalf("changed %+v, want 3", d.Changed)
+	}
+	c := d.Changed[0]
+	if want := []FieldChange{{Field: FieldClassNo, Old: "1", New: "2"}}; !reflect.DeepEqual(c.Fields, want) {
+		t.Errorf("field changes %+v, want %+v", c.Fields, want)
+	}
+	wantMarks := []MarkChange{
+		{Component: FieldCompre, Old: 20, New: 35, Delta: 15},
+		{Component: FieldTotal, Old: 70, New: 85, Delta: 15},
+	}
+	if !reflect.DeepEqual(c.Marks, wantMarks) {
+		t.Errorf("mark changes %+v, want %+v", c.Marks, wantMarks)
+	}
+	if c.OldRank != 3 || c.NewRank != 2 {
+		t.Errorf("3 moved from %d to %d, want 3 to 2", c.OldRank, c.NewRank)
+	}
+	if len(d.Moved) != 1 || d.Moved[0].Emplid != "2" || d.Moved[0].NewRank != 3 {
+		t.Errorf("moved %+v, want 2 down to 3", d.Moved)
+	}
+	want := DiffSummary{OldStudents: 4, NewStudents: 4, Added: 1, Removed: 1, Changed: 1, MarkChanges: 2, RankChanges: 2}
+	if d.Summary != want {
+		t.Errorf("summary %+v, want %+v", d.Summary, want)
+	}
+}
+
+// TestCompareQuarantined checks that a row quarantined in one version is
+// reported as such rather than as a student added or removed.
+func TestCompareQuarantined(t *testing.T) {
+	students := []Student{
+		{Emplid: "1", CampusID: "2022A7PS0001P", Total: 90},
+		{Emplid: "2", CampusID: "2022A7PS0002P", Total: 80},
+		{Emplid: "3", CampusID: "2022A7PS0003P", Total: 70},
+	}
+	before := diffVersion("old", students[:2],
+		QuarantinedRow{Sheet: "Sheet1", Row: 4, Emplid: "3"},
+		QuarantinedRow{Sheet: "Sheet1", Row: 5, Emplid: "9"},
+		QuarantinedRow{Sheet: "Sheet1", Row: 6})
+	after := diffVersion("new", []Student{students[0], students[2]},
+		QuarantinedRow{Sheet: "Sheet1", Row: 3, Emplid: "2"},
+		QuarantinedRow{Sheet: "Sheet1", Row: 5, Emplid: "9"})
+	d, err := Compare(before, after, Options{Rank: DefaultRankOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Added) != 0 || len(d.Removed) != 0 {
+		t.Errorf("added %+v and removed %+v, want quarantine changes", d.Added, d.Removed)
+	}
+
+	type row struct {
+		emplid   string
+		old, new int
+		ranked   int
+	}
+	var got []row
+	for _, q := range d.Quarantined {
+		r := row{emplid: q.Emplid}
+		if q.Old != nil {
+			r.old = q.Old.Row
+		}
+		if q.New != nil {
+			r.new = q.New.Row
+		}
+		if q.Ranked != nil {
+			r.ranked = q.Ranked.Rank
+		}
+		got = append(got, r)
+	}
+	want := []row{
+		{emplid: "3", old: 4, ranked: 2}, // fixed in the new version
+		{emplid: "9", old: 5, new: 5},    // broken in both
+		{emplid: "", old: 6},             // no Emplid to match
+		{emplid: "2", new: 3, ranked: 2}, // broken in the new version
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("quarantined %+v, want %+v", got, want)
+	}
+	if d.Summary.OldQuarantined != 3 || d.Summary.NewQuarantined != 2 {
+		t.Errorf("quarantined %d -> %d rows, want 3 -> 2", d.Summary.OldQuarantined, d.Summary.NewQuarantined)
+	}
+
+	var out bytes.Buffer
+	PrintDiff(&out, d)
+	for _, s := range []string{"Quarantined rows: 3 -> 2", "Sheet1 row 4", "70.00, rank 2"} {
+		if !strings.Contains(out.String(), s) {
+			t.Errorf("printed diff lacks %q:\n%s", s, out.String())
+		}
+	}
+}
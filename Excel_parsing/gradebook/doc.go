@@ -0,0 +1,12 @@
+// Package gradebook reads course gradebooks from XLSX, ODS, CSV or TSV
+// sources, validates each row and builds the summary report: averages,
+// descriptive statistics, rankings, letter grades and total checks. The
+// report can be printed or exported as JSON, CSV, XLSX, Markdown or HTML.
+//
+// A typical caller loads one or more workbooks and generates a report:
+//
+//	students, validation, err := gradebook.Load(r, "marks.xlsx", gradebook.LoadOptions{})
+//	report, err := gradebook.Generate(students, gradebook.Options{Rank: gradebook.DefaultRankOptions()})
+//	report.Validation = &validation
+//	_, err = gradebook.Export(report, "json", "summary.json")
+package gradebook
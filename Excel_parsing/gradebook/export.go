@@ -0,0 +1,499 @@
+package gradebook
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+// Exporter writes a report to output, which is a file path, or "-" for
+// standard output, for single-file formats and a directory for CSV. An
+// empty output selects the exporter's default location.
+type Exporter struct {
+	DefaultOutput string
+	Write         func(report SummaryReport, output string) error
+}
+
+var exporters = map[string]Exporter{
+	"json":     {"summary_report.json", exportJSON},
+	"csv":      {"summary_report", exportCSV},
+	"xlsx":     {"summary_report.xlsx", exportXLSX},
+	"markdown": {"summary_report.md", exportMarkdown},
+	"html":     {"summary_report.html", exportHTML},
+}
+
+func ExporterNames() []string {
+	var names []string
+	for name := range exporters {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Export runs the named exporter and returns where the report went.
+func Export(report SummaryReport, format, output string) (string, error) {
+	if format == "md" {
+		format = "markdown"
+	}
+	exp, ok := exporters[format]
+	if !ok {
+		return "", fmt.Errorf("unknown export format %q (want one of %s)",
+			format, strings.Join(ExporterNames(), ", "))
+	}
+	if output == "" {
+		output = exp.DefaultOutput
+	}
+	return output, exp.Write(report, output)
+}
+
+// Table is one section of a report in a format-neutral shape. Cells hold
+// strings, ints or float64s so that spreadsheet exporters can keep numbers
+// numeric.
+type Table struct {
+	Name   string
+	Title  string
+	Header []string
+	Rows   [][]interface{}
+}
+
+// Tables returns the report as the tables every exporter renders, for
+// callers that lay them out themselves.
+func Tables(report SummaryReport) []Table {
+	return reportTables(report)
+}
+
+// StudentTable lays out ranked students the way the ranking sections of a
+// report are rendered.
+func StudentTable(ranked []RankedStudent, rankedBy string) Table {
+	return rankedTable("students", "Students", ranked, rankedBy)
+}
+
+// FormatCell renders one table cell as text.
+func FormatCell(v interface{}) string {
+	switch v := v.(type) {
+	case float64:
+		return strconv.FormatFloat(v, 'f', 2, 64)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// reportTables flattens a report into the tables every exporter renders.
+func reportTables(report SummaryReport) []Table {
+	var tables []Table
+
+	if report.Where != "" {
+		tables = append(tables, Table{Name: "filter", Title: "Filter", Header: []string{"Where", "Students"},
+			Rows: [][]interface{}{{report.Where, report.Statistics.Overall[FieldTotal].Count}}})
+	}
+
+	general := Table{Name: "general_averages", Title: "General Averages", Header: []string{"Component", "Average"}}
+	for _, k := range sortedKeys(report.GeneralAverages) {
+		general.Rows = append(general.Rows, []interface{}{k, report.GeneralAverages[k]})
+	}
+	tables = append(tables, general)
+
+	branch := Table{Name: "branch_averages", Title: "Branch Averages", Header: []string{"Branch", "Average Total"}}
+	for _, k := range sortedKeys(report.BranchAverages) {
+		branch.Rows = append(branch.Rows, []interface{}{k, report.BranchAverages[k]})
+	}
+	tables = append(tables, branch)
+
+	tables = append(tables, statisticsTables(&report.Statistics)...)
+	if report.SpecialMarks != nil {
+		tables = append(tables, specialMarkTable(report.SpecialMarks))
+	}
+	if report.Rules != nil {
+		tables = append(tables, ruleAuditTable(report.Rules))
+	}
+	if report.Normalization != nil {
+		tables = append(tables, normalizationTable(report.Normalization))
+	}
+	if report.Correlations != nil {
+		tables = append(tables, correlationTables(report.Correlations)...)
+	}
+
+	if report.Grading != nil {
+		tables = append(tables, gradingTables(report.Grading)...)
+	}
+	if report.CutoffSuggestions != nil {
+		tables = append(tables, cutoffSuggestionTable(report.CutoffSuggestions))
+	}
+	if report.TotalCheck != nil {
+		tables = append(tables, totalCheckTable(report.TotalCheck))
+	}
+	if report.Anomalies != nil {
+		tables = append(tables, anomalyTable(report.Anomalies))
+	}
+
+	top := rankedTable(overallTopName, "Overall Top Students", report.OverallTopStudents, report.RankedBy)
+	tables = append(tables, top)
+
+	var branches []string
+	for b := range report.BranchRankings {
+		branches = append(branches, b)
+	}
+	sort.Strings(branches)
+	for _, b := range branches {
+		tables = append(tables, rankedTable(branchRankingName+fileSlug(b), branchRankingTitle+b, report.BranchRankings[b], report.RankedBy))
+	}
+
+	if report.Validation != nil && len(report.Validation.Issues) > 0 {
+		issues := Table{Name: "validation_issues", Title: "Validation Issues",
+			Header: []string{"File", "Sheet", "Row", "Cell", "Column", "Value", "Severity", "Message"}}
+		for _, is := range report.Validation.Issues {
+			issues.Rows = append(issues.Rows, []interface{}{
+				is.File, is.Sheet, is.Row, is.Cell, is.Column, is.Value, is.Severity, is.Message})
+		}
+		tables = append(tables, issues)
+	}
+	return tables
+}
+
+// rankedTable renders a ranking. The score column is only shown when the
+// ranking is by something other than Total.
+func rankedTable(name, title string, ranked []RankedStudent, rankedBy string) Table {
+	students := make([]Student, len(ranked))
+	for i, r := range ranked {
+		students[i] = r.Student
+	}
+	extras := extraNames(students)
+	graded, recomputed, normalized := false, false, false
+	for _, s := range students {
+		graded = graded || s.Grade != ""
+		recomputed = recomputed || s.RecomputedTotal != nil
+		normalized = normalized || s.NormalizedTotal != nil
+	}
+	showScore := rankedBy != "" && rankedBy != FieldTotal && rankedBy != FieldRecomputedTotal && rankedBy != FieldNormalizedTotal
+	t := Table{Name: name, Title: title, Header: []string{"Rank", "Percentile", "Emplid", "Campus ID", "Class No"}}
+	t.Header = append(t.Header, numericFields...)
+	if recomputed {
+		t.Header = append(t.Header, FieldRecomputedTotal)
+	}
+	if normalized {
+		t.Header = append(t.Header, FieldNormalizedTotal)
+	}
+	t.Header = append(t.Header, extras...)
+	if graded {
+		t.Header = append(t.Header, "Grade")
+	}
+	if showScore {
+		t.Header = append(t.Header, rankedBy)
+	}
+
+	for i, s := range students {
+		row := []interface{}{ranked[i].Rank, ranked[i].Percentile, s.Emplid, s.CampusID, s.ClassNo}
+		if ranked[i].Unranked {
+			row[0], row[1] = "unranked", ""
+		}
+		for _, field := range numericFields {
+			row = append(row, markCell(s, field))
+		}
+		if recomputed {
+			row = append(row, optionalCell(s, FieldRecomputedTotal))
+		}
+		if normalized {
+			row = append(row, optionalCell(s, FieldNormalizedTotal))
+		}
+		for _, name := range extras {
+			row = append(row, markCell(s, name))
+		}
+		if graded {
+			row = append(row, s.Grade)
+		}
+		if showScore {
+			if ranked[i].Unranked {
+				row = append(row, "")
+			} else {
+				row = append(row, ranked[i].Score)
+			}
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+// optionalCell is a computed score, blank for a student who has none.
+func optionalCell(s Student, name string) interface{} {
+	if v, ok := s.Component(name); ok {
+		return v
+	}
+	return ""
+}
+
+// markCell is a component's mark as shown in a ranking: the marker for
+// special marks that were not replaced by a makeup score, and nothing for
+// excluded blanks.
+func markCell(s Student, name string) interface{} {
+	if m, ok := s.Special[name]; ok && m.Makeup == nil && (m.Marker != "" || m.Policy == PolicyExclude) {
+		return m.Marker
+	}
+	v, _ := s.component(name)
+	return v
+}
+
+// extraNames returns every extra component present on any of students.
+func extraNames(students []Student) []string {
+	seen := make(map[string]bool)
+	for _, s := range students {
+		for name := range s.Extra {
+			seen[name] = true
+		}
+	}
+	var names []string
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+const (
+	overallTopName     = "overall_top_students"
+	branchRankingName  = "branch_ranking_"
+	branchRankingTitle = "Branch Ranking: "
+)
+
+// fileSlug makes a group label safe to use in a file name.
+func fileSlug(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '-':
+			b.WriteRune(r)
+		case b.Len() > 0 && !strings.HasSuffix(b.String(), "_"):
+			b.WriteByte('_')
+		}
+	}
+	return strings.TrimSuffix(b.String(), "_")
+}
+
+func sortedKeys(m map[string]float64) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// createOutput opens path for writing, with "-" meaning standard output.
+func createOutput(path string) (io.WriteCloser, error) {
+	if path == "-" {
+		return nopCloser{os.Stdout}, nil
+	}
+	return os.Create(path)
+}
+
+type nopCloser struct{ io.Writer }
+
+func (nopCloser) Close() error { return nil }
+
+func exportJSON(report SummaryReport, output string) error {
+	file, err := createOutput(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+// exportCSV writes the ranked students into the output directory as one
+// file per section (ClassNo), ranked within the section, and every other
+// table of the report as a file of its own. It cannot write to standard
+// output.
+func exportCSV(report SummaryReport, output string) error {
+	if output == "-" {
+		return fmt.Errorf("csv writes one file per table and cannot go to standard output; give a directory")
+	}
+	if err := os.MkdirAll(output, 0o755); err != nil {
+		return err
+	}
+	for _, t := range reportTables(report) {
+		if t.Name == overallTopName || strings.HasPrefix(t.Name, branchRankingName) {
+			continue
+		}
+		if err := writeCSVTable(filepath.Join(output, t.Name+".csv"), t); err != nil {
+			return err
+		}
+	}
+	used := make(map[string]bool)
+	for _, section := range sortedRankingKeys(report.SectionRankings) {
+		name := sectionFileName(section, used)
+		t := rankedTable(name, "Section "+section, report.SectionRankings[section], report.RankedBy)
+		if err := writeCSVTable(filepath.Join(output, name+".csv"), t); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// sectionFileName names a section's CSV file. Sections whose labels slug
+// to the same name, or to nothing, are told apart by a numeric suffix.
+func sectionFileName(section string, used map[string]bool) string {
+	slug := fileSlug(section)
+	if slug == "" {
+		slug = "none"
+	}
+	name := "section_" + slug
+	for i := 2; used[name]; i++ {
+		name = fmt.Sprintf("section_%s_%d", slug, i)
+	}
+	used[name] = true
+	return name
+}
+
+func writeCSVTable(path string, t Table) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	w := csv.NewWriter(file)
+	w.Write(t.Header)
+	for _, row := range t.Rows {
+		record := make([]string, len(row))
+		for i, v := range row {
+			if f, ok := v.(float64); ok {
+				record[i] = strconv.FormatFloat(f, 'f', -1, 64)
+			} else {
+				record[i] = FormatCell(v)
+			}
+		}
+		w.Write(record)
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func exportMarkdown(report SummaryReport, output string) error {
+	file, err := createOutput(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	fmt.Fprintln(file, "# Summary Report")
+	for _, t := range reportTables(report) {
+		fmt.Fprintf(file, "\n## %s\n\n", t.Title)
+		writeMarkdownTable(file, t)
+	}
+	return nil
+}
+
+func writeMarkdownTable(w io.Writer, t Table) {
+	escape := strings.NewReplacer("|", `\|`, "\n", " ")
+	fmt.Fprintf(w, "| %s |\n", strings.Join(t.Header, " | "))
+	align := make([]string, len(t.Header))
+	for i := range align {
+		align[i] = "---"
+	}
+	if len(t.Rows) > 0 {
+		for i, v := range t.Rows[0] {
+			switch v.(type) {
+			case int, float64:
+				align[i] = "---:"
+			}
+		}
+	}
+	fmt.Fprintf(w, "| %s |\n", strings.Join(align, " | "))
+	for _, row := range t.Rows {
+		cells := make([]string, len(row))
+		for i, v := range row {
+			cells[i] = escape.Replace(FormatCell(v))
+		}
+		fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+	}
+}
+
+var htmlReport = template.Must(template.New("report").Funcs(template.FuncMap{
+	"cell": FormatCell,
+	"numeric": func(v interface{}) bool {
+		switch v.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	},
+}).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Summary Report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+th { background: #f0f0f0; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr:nth-child(even) td { background: #fafafa; }
+</style>
+</head>
+<body>
+<h1>Summary Report</h1>
+{{range .}}
+<h2 id="{{.Name}}">{{.Title}}</h2>
+<table>
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if numeric .}} class="num"{{end}}>{{cell .}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+{{end}}
+</body>
+</html>
+`))
+
+func exportHTML(report SummaryReport, output string) error {
+	file, err := createOutput(output)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return htmlReport.Execute(file, reportTables(report))
+}
+
+// PrintReport renders the report as plain-text tables on w.
+func PrintReport(w io.Writer, report SummaryReport) {
+	var tables []Table
+	for _, t := range reportTables(report) {
+		// Validation issues are already printed by PrintValidationReport.
+		if t.Name != "validation_issues" {
+			tables = append(tables, t)
+		}
+	}
+	printTables(w, tables)
+}
+
+func printTables(w io.Writer, tables []Table) {
+	for i, t := range tables {
+		if i > 0 {
+			fmt.Fprintln(w)
+		}
+		fmt.Fprintf(w, "%s\n", t.Title)
+		tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintf(tw, "%s\t\n", strings.Join(t.Header, "\t"))
+		for _, row := range t.Rows {
+			for _, v := range row {
+				fmt.Fprintf(tw, "%s\t", FormatCell(v))
+			}
+			fmt.Fprintln(tw)
+		}
+		tw.Flush()
+	}
+}
@@ -0,0 +1,119 @@
+package gradebook
+
+import (
+	"encoding/csv"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf8"
+)
+
+func TestXLSXSheetName(t *testing.T) {
+	long := strings.Repeat("Electrical and Electronics ", 2)
+	tests := []struct {
+		name string
+		used []string
+		in   string
+		want string
+	}{
+		{"plain", nil, "A7", "A7"},
+		{"forbidden characters", nil, "A7/B3: [dual]", "A7_B3_ _dual_"},
+		{"trimmed to 31", nil, long, "Electrical and Electronics Elec"},
+		{"multibyte trimmed by rune", nil, strings.Repeat("é", 40), strings.Repeat("é", 31)},
+		{"taken", []string{"A7"}, "A7", "A7 (2)"},
+		{"taken ignoring case", []string{"summary"}, "Summary", "Summary (2)"},
+		{"taken twice", []string{"A7", "A7 (2)"}, "A7", "A7 (3)"},
+		{"long and taken", []string{long}, long, "Electrical and Electronics  (2)"},
+		{"empty", nil, "", "Sheet"},
+		{"quotes", nil, "'A7'", "A7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			used := make(map[string]bool)
+			for _, u := range tt.used {
+				xlsxSheetName(u, used)
+			}
+			got := xlsxSheetName(tt.in, used)
+			if got != tt.want {
+				t.Errorf("xlsxSheetName(%q) = %q, want %q", tt.in, got, tt.want)
+			}
+			if !utf8.ValidString(got) || utf8.RuneCountInString(got) > 31 {
+				t.Errorf("xlsxSheetName(%q) = %q is not a valid sheet name", tt.in, got)
+			}
+			if !used[strings.ToLower(got)] {
+				t.Errorf("%q was not recorded as used", got)
+			}
+		})
+	}
+}
+
+func TestSectionFileName(t *testing.T) {
+	used := make(map[string]bool)
+	for _, tt := range []struct{ section, want string }{
+		{"1", "section_1"},
+		{"L2/T1", "section_L2_T1"},
+		{"L2 T1", "section_L2_T1_2"},
+		{"", "section_none"},
+		{"?", "section_none_2"},
+	} {
+		if got := sectionFileName(tt.section, used); got != tt.want {
+			t.Errorf("sectionFileName(%q) = %q, want %q", tt.section, got, tt.want)
+		}
+	}
+}
+
+func TestExportCSVOneFilePerSection(t *testing.T) {
+	students := []Student{
+		{Emplid: "1", ClassNo: "1", CampusID: "2022A7PS0001P", Total: 50},
+		{Emplid: "2", ClassNo: "1", CampusID: "2022A3PS0002P", Total: 70},
+		{Emplid: "3", ClassNo: "2", CampusID: "2022A7PS0003P", Total: 60},
+		{Emplid: "4", ClassNo: "2", CampusID: "2022A7PS0004P", Total: 90},
+		{Emplid: "5", ClassNo: "2", CampusID: "2022A7PS0005P", Total: 10},
+	}
+	report, err := Generate(students, Options{Rank: DefaultRankOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	if err := exportCSV(report, dir); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"overall_top_students.csv", "branch_ranking_A7PS.csv"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("%s written; student rows belong in the section files", name)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "general_averages.csv")); err != nil {
+		t.Errorf("summary table missing: %v", err)
+	}
+
+	for _, tt := range []struct {
+		file  string
+		order []string
+		ranks []string
+	}{
+		{"section_1.csv", []string{"2", "1"}, []string{"1", "2"}},
+		{"section_2.csv", []string{"4", "3", "5"}, []string{"1", "2", "3"}},
+	} {
+		f, err := os.Open(filepath.Join(dir, tt.file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		records, err := csv.NewReader(f).ReadAll()
+		f.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(records) != len(tt.order)+1 {
+			t.Fatalf("%s: %d rows, want %d students and a header", tt.file, len(records), len(tt.order))
+		}
+		for i, emplid := range tt.order {
+			row := records[i+1]
+			if row[0] != tt.ranks[i] || row[2] != emplid {
+				t.Errorf("%s row %d: rank %s Emplid %s, want rank %s Emplid %s", tt.file, i+1, row[0], row[2], tt.ranks[i], emplid)
+			}
+		}
+	}
+}
@@ -0,0 +1,269 @@
+package gradebook
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// exportXLSX writes a Summary sheet holding every non-branch section
+// followed by one sheet per branch ranking.
+func exportXLSX(report SummaryReport, output string) error {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	styles, err := newXLSXStyles(f)
+	if err != nil {
+		return err
+	}
+
+	const summary = "Summary"
+	if err := f.SetSheetName(f.GetSheetName(0), summary); err != nil {
+		return err
+	}
+
+	used := map[string]bool{}
+	for _, name := range []string{summary, chartDataSheet, chartSheet} {
+		xlsxSheetName(name, used)
+	}
+	row := 1
+	for _, t := range reportTables(report) {
+		if strings.HasPrefix(t.Name, branchRankingName) {
+			sheet := xlsxSheetName(strings.TrimPrefix(t.Title, branchRankingTitle), used)
+			if _, err := f.NewSheet(sheet); err != nil {
+				return err
+			}
+			if _, err := writeXLSXTable(f, sheet, 1, t, styles, false); err != nil {
+				return err
+			}
+			f.SetPanes(sheet, &excelize.Panes{Freeze: true, YSplit: 1, TopLeftCell: "A2", ActivePane: "bottomLeft"})
+			continue
+		}
+		next, err := writeXLSXTable(f, summary, row, t, styles, true)
+		if err != nil {
+			return err
+		}
+		row = next + 1
+	}
+	f.SetColWidth(summary, "A", "A", 14)
+	if report.Charts != nil {
+		if err := addXLSXCharts(f, *report.Charts, styles); err != nil {
+			return err
+		}
+	}
+	if output == "-" {
+		_, err := f.WriteTo(os.Stdout)
+		return err
+	}
+	return f.SaveAs(output)
+}
+
+type xlsxStyles struct {
+	title, header, number int
+}
+
+func newXLSXStyles(f *excelize.File) (xlsxStyles, error) {
+	var s xlsxStyles
+	var err error
+	if s.title, err = f.NewStyle(&excelize.Style{Font: &excelize.Font{Bold: true, Size: 13}}); err != nil {
+		return s, err
+	}
+	if s.header, err = f.NewStyle(&excelize.Style{
+		Font:   &excelize.Font{Bold: true},
+		Fill:   excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"DDEBF7"}},
+		Border: []excelize.Border{{Type: "bottom", Color: "000000", Style: 1}},
+	}); err != nil {
+		return s, err
+	}
+	numFmt := "0.00"
+	s.number, err = f.NewStyle(&excelize.Style{CustomNumFmt: &numFmt})
+	return s, err
+}
+
+// writeXLSXTable writes t starting at startRow and returns the first free
+// row after it.
+func writeXLSXTable(f *excelize.File, sheet string, startRow int, t Table, styles xlsxStyles, withTitle bool) (int, error) {
+	row := startRow
+	if withTitle {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		f.SetCellValue(sheet, cell, t.Title)
+		f.SetCellStyle(sheet, cell, cell, styles.title)
+		row++
+	}
+
+	header := make([]interface{}, len(t.Header))
+	for i, h := range t.Header {
+		header[i] = h
+	}
+	first, _ := excelize.CoordinatesToCellName(1, row)
+	last, _ := excelize.CoordinatesToCellName(len(header), row)
+	if err := f.SetSheetRow(sheet, first, &header); err != nil {
+		return row, err
+	}
+	f.SetCellStyle(sheet, first, last, styles.header)
+	row++
+
+	for _, values := range t.Rows {
+		cell, _ := excelize.CoordinatesToCellName(1, row)
+		r := values
+		if err := f.SetSheetRow(sheet, cell, &r); err != nil {
+			return row, err
+		}
+		for i, v := range values {
+			if _, ok := v.(float64); ok {
+				c, _ := excelize.CoordinatesToCellName(i+1, row)
+				f.SetCellStyle(sheet, c, c, styles.number)
+			}
+		}
+		row++
+	}
+	return row, nil
+}
+
+// xlsxSheetName makes name a valid sheet name that is not yet in used,
+// and records it there. It drops the characters Excel refuses, trims to
+// Excel's 31 character limit and, since sheet names are compared ignoring
+// case, adds a numeric suffix to a name already taken.
+func xlsxSheetName(name string, used map[string]bool) string {
+	const maxLen = 31
+	name = strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`:\/?*[]`, r) {
+			return '_'
+		}
+		return r
+	}, name)
+	name = strings.Trim(name, "' ")
+	if name == "" {
+		name = "Sheet"
+	}
+	base := []rune(name)
+	candidate := name
+	for i := 1; ; i++ {
+		suffix := ""
+		if i > 1 {
+			suffix = fmt.Sprintf(" (%d)", i)
+		}
+		r := base
+		if len(r)+len([]rune(suffix)) > maxLen {
+			r = r[:maxLen-len([]rune(suffix))]
+		}
+		candidate = string(r) + suffix
+		if !used[strings.ToLower(candidate)] {
+			break
+		}
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
+
+const (
+	chartDataSheet = "Chart Data"
+	chartSheet     = "Charts"
+)
+
+// addXLSXCharts writes the chart data to its own sheet and draws native
+// Excel charts from it on a Charts sheet: a column chart per histogram,
+// the branch box plots as stacked columns (an invisible base up to Q1,
+// then Q1 to median and median to Q3; whiskers are listed in the data)
+// and the scatter plot.
+func addXLSXCharts(f *excelize.File, data ChartData, styles xlsxStyles) error {
+	for _, sheet := range []string{chartSheet, chartDataSheet} {
+		if _, err := f.NewSheet(sheet); err != nil {
+			return err
+		}
+	}
+	ref := func(col, first, last int) string {
+		a, _ := excelize.CoordinatesToCellName(col, first, true)
+		b, _ := excelize.CoordinatesToCellName(col, last, true)
+		return fmt.Sprintf("'%s'!%s:%s", chartDataSheet, a, b)
+	}
+	var charts []*excelize.Chart
+	row := 1
+
+	for _, h := range data.Histograms {
+		t := Table{Title: "Histogram: " + h.Component, Header: []string{h.Component, "Students"}}
+		for _, bin := range h.Bins {
+			t.Rows = append(t.Rows, []interface{}{tickLabel(bin.Lo) + "–" + tickLabel(bin.Hi), bin.Count})
+		}
+		next, err := writeXLSXTable(f, chartDataSheet, row, t, styles, true)
+		if err != nil {
+			return err
+		}
+		if len(h.Bins) > 0 {
+			first := row + 2
+			charts = append(charts, &excelize.Chart{
+				Type:   excelize.Col,
+				Title:  []excelize.RichTextRun{{Text: "Distribution of " + h.Component}},
+				Series: []excelize.ChartSeries{{Name: h.Component, Categories: ref(1, first, next-1), Values: ref(2, first, next-1)}},
+				Legend: excelize.ChartLegend{Position: "none"},
+				XAxis:  excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: h.Component}}},
+				YAxis:  excelize.ChartAxis{MajorGridLines: true, Title: []excelize.RichTextRun{{Text: "Students"}}},
+			})
+		}
+		row = next + 1
+	}
+
+	if len(data.BoxPlots) > 0 {
+		t := Table{Title: data.BoxPlotOf + " by Branch",
+			Header: []string{"Branch", "Students", "Lower Whisker", "Q1", "Median", "Q3", "Upper Whisker", "Outliers", "Q1 to Median", "Median to Q3"}}
+		for _, b := range data.BoxPlots {
+			outliers := make([]string, len(b.Outliers))
+			for i, o := range b.Outliers {
+				outliers[i] = tickLabel(o)
+			}
+			t.Rows = append(t.Rows, []interface{}{b.Group, b.Count, b.LowerWhisker, b.Q1, b.Median, b.Q3, b.UpperWhisker,
+				strings.Join(outliers, ", "), b.Median - b.Q1, b.Q3 - b.Median})
+		}
+		next, err := writeXLSXTable(f, chartDataSheet, row, t, styles, true)
+		if err != nil {
+			return err
+		}
+		first, last := row+2, next-1
+		white := excelize.Fill{Type: "pattern", Pattern: 1, Color: []string{"FFFFFF"}}
+		charts = append(charts, &excelize.Chart{
+			Type:  excelize.ColStacked,
+			Title: []excelize.RichTextRun{{Text: t.Title}},
+			Series: []excelize.ChartSeries{
+				{Name: "Q1", Categories: ref(1, first, last), Values: ref(4, first, last), Fill: white},
+				{Name: "Q1 to Median", Categories: ref(1, first, last), Values: ref(9, first, last)},
+				{Name: "Median to Q3", Categories: ref(1, first, last), Values: ref(10, first, last)},
+			},
+			Legend: excelize.ChartLegend{Position: "none"},
+			YAxis:  excelize.ChartAxis{MajorGridLines: true, Title: []excelize.RichTextRun{{Text: data.BoxPlotOf}}},
+		})
+		row = next + 1
+	}
+
+	if sc := data.Scatter; sc != nil && len(sc.Points) > 0 {
+		t := Table{Title: sc.Y + " vs " + sc.X, Header: []string{"Emplid", sc.X, sc.Y}}
+		for _, pt := range sc.Points {
+			t.Rows = append(t.Rows, []interface{}{pt.Emplid, pt.X, pt.Y})
+		}
+		next, err := writeXLSXTable(f, chartDataSheet, row, t, styles, true)
+		if err != nil {
+			return err
+		}
+		first, last := row+2, next-1
+		charts = append(charts, &excelize.Chart{
+			Type:   excelize.Scatter,
+			Title:  []excelize.RichTextRun{{Text: fmt.Sprintf("%s (r = %.2f)", t.Title, sc.R)}},
+			Series: []excelize.ChartSeries{{Name: sc.Y, Categories: ref(2, first, last), Values: ref(3, first, last), Marker: excelize.ChartMarker{Symbol: "circle", Size: 5}}},
+			Legend: excelize.ChartLegend{Position: "none"},
+			XAxis:  excelize.ChartAxis{Title: []excelize.RichTextRun{{Text: sc.X}}},
+			YAxis:  excelize.ChartAxis{MajorGridLines: true, Title: []excelize.RichTextRun{{Text: sc.Y}}},
+		})
+	}
+	f.SetColWidth(chartDataSheet, "A", "A", 16)
+
+	// Two charts per row, each about 15 rows tall.
+	for i, c := range charts {
+		c.Dimension = excelize.ChartDimension{Width: 480, Height: 290}
+		cell, _ := excelize.CoordinatesToCellName(1+9*(i%2), 1+16*(i/2))
+		if err := f.AddChart(chartSheet, cell, c); err != nil {
+			return err
+		}
+	}
+	return nil
+}
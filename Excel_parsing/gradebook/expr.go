@@ -0,0 +1,271 @@
+package gradebook
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// scoreExpr is a compiled arithmetic expression over student components,
+// such as "MidSem + Compre" or "(Quiz + LabTest) / 2".
+type scoreExpr interface {
+	eval(s Student) float64
+}
+
+// scoreMissing reports whether e has no value for s: it uses a component
+// s has no score for, such as a mark left out by the exclude policy, or
+// divides by zero. Callers check it before eval.
+func scoreMissing(e scoreExpr, s Student) bool {
+	switch e := e.(type) {
+	case fieldExpr:
+		_, ok := s.Component(string(e))
+		return !ok
+	case negExpr:
+		return scoreMissing(e.x, s)
+	case binaryExpr:
+		if scoreMissing(e.l, s) || scoreMissing(e.r, s) {
+			return true
+		}
+		return e.op == '/' && e.r.eval(s) == 0
+	}
+	return false
+}
+
+type numberExpr float64
+
+func (n numberExpr) eval(Student) float64 { return float64(n) }
+
+type fieldExpr string
+
+func (f fieldExpr) eval(s Student) float64 {
+	v, _ := s.Component(string(f))
+	return v
+}
+
+type derivedExpr func(Student) float64
+
+func (d derivedExpr) eval(s Student) float64 { return d(s) }
+
+type negExpr struct{ x scoreExpr }
+
+func (n negExpr) eval(s Student) float64 { return -n.x.eval(s) }
+
+type binaryExpr struct {
+	op   byte
+	l, r scoreExpr
+}
+
+func (b binaryExpr) eval(s Student) float64 {
+	l, r := b.l.eval(s), b.r.eval(s)
+	switch b.op {
+	case '+':
+		return l + r
+	case '-':
+		return l - r
+	case '*':
+		return l * r
+	default:
+		return l / r
+	}
+}
+
+// compileScoreExpr parses src. Identifiers are matched case-insensitively
+// against known, the component names available on the students; a name
+// that is not a single word, such as an extra column "Quiz 1", is written
+// in backquotes: `Quiz 1` / 2.
+func compileScoreExpr(src string, known []string) (scoreExpr, error) {
+	p := &exprParser{src: src, known: known}
+	p.next()
+	e, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d in %q", p.tok, p.start, src)
+	}
+	return e, nil
+}
+
+type exprParser struct {
+	src   string
+	pos   int
+	start int
+	tok   string
+	known []string
+	// derived are extra numeric identifiers, such as year in filters.
+	derived map[string]func(Student) float64
+}
+
+// next advances to the following token: a number, an identifier, a
+// backquoted name, a quoted string, a two-character operator or a single
+// punctuation character. At the end of input tok is "".
+func (p *exprParser) next() {
+	for p.pos < len(p.src) && (p.src[p.pos] == ' ' || p.src[p.pos] == '\t') {
+		p.pos++
+	}
+	p.start = p.pos
+	if p.pos >= len(p.src) {
+		p.tok = ""
+		return
+	}
+	c, size := utf8.DecodeRuneInString(p.src[p.pos:])
+	switch {
+	case isDigit(c) || c == '.':
+		p.skip(func(r rune) bool { return isDigit(r) || r == '.' })
+	case isIdentStart(c):
+		p.skip(func(r rune) bool { return isIdentStart(r) || unicode.IsDigit(r) })
+	case c == '`':
+		// Names are taken literally up to the closing backquote; an
+		// unterminated one is reported by primary.
+		p.pos++
+		if end := strings.IndexByte(p.src[p.pos:], '`'); end >= 0 {
+			p.pos += end + 1
+		} else {
+			p.pos = len(p.src)
+		}
+	case c == '"' || c == '\'':
+		// An unterminated string runs to the end and is reported by the
+		// caller when it fails to unquote.
+		p.pos++
+		for p.pos < len(p.src) && rune(p.src[p.pos]) != c {
+			if p.src[p.pos] == '\\' {
+				p.pos++
+			}
+			p.pos++
+		}
+		p.pos = min(p.pos+1, len(p.src))
+	case p.pos+1 < len(p.src) && isTwoCharOp(p.src[p.pos:p.pos+2]):
+		p.pos += 2
+	default:
+		p.pos += size
+	}
+	p.tok = p.src[p.start:p.pos]
+}
+
+// skip advances past the runes that satisfy in.
+func (p *exprParser) skip(in func(rune) bool) {
+	for p.pos < len(p.src) {
+		r, size := utf8.DecodeRuneInString(p.src[p.pos:])
+		if !in(r) {
+			return
+		}
+		p.pos += size
+	}
+}
+
+// isDigit accepts ASCII digits only, so that a number is always something
+// strconv.ParseFloat can read.
+func isDigit(r rune) bool { return r >= '0' && r <= '9' }
+
+func isIdentStart(r rune) bool { return unicode.IsLetter(r) || r == '_' }
+
+func isTwoCharOp(s string) bool {
+	switch s {
+	case "&&", "||", "==", "!=", "<=", ">=", "=~", "!~":
+		return true
+	}
+	return false
+}
+
+func (p *exprParser) sum() (scoreExpr, error) {
+	l, err := p.product()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "+" || p.tok == "-" {
+		op := p.tok[0]
+		p.next()
+		r, err := p.product()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryExpr{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) product() (scoreExpr, error) {
+	l, err := p.unary()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "*" || p.tok == "/" {
+		op := p.tok[0]
+		p.next()
+		r, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		l = binaryExpr{op: op, l: l, r: r}
+	}
+	return l, nil
+}
+
+func (p *exprParser) unary() (scoreExpr, error) {
+	if p.tok == "-" {
+		p.next()
+		x, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return negExpr{x}, nil
+	}
+	return p.primary()
+}
+
+func (p *exprParser) primary() (scoreExpr, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return nil, fmt.Errorf("unexpected end of expression %q", p.src)
+	case tok == "(":
+		p.next()
+		e, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		if p.tok != ")" {
+			return nil, fmt.Errorf("missing ) in %q", p.src)
+		}
+		p.next()
+		return e, nil
+	case isDigit(rune(tok[0])) || tok[0] == '.':
+		v, err := strconv.ParseFloat(tok, 64)
+		if err != nil {
+			return nil, fmt.Errorf("bad number %q in %q", tok, p.src)
+		}
+		p.next()
+		return numberExpr(v), nil
+	case tok[0] == '`':
+		if len(tok) < 2 || tok[len(tok)-1] != '`' {
+			return nil, fmt.Errorf("unterminated ` in %q", p.src)
+		}
+		return p.name(tok[1 : len(tok)-1])
+	case isIdentStart(firstRune(tok)):
+		return p.name(tok)
+	}
+	return nil, fmt.Errorf("unexpected %q at offset %d in %q", tok, p.start, p.src)
+}
+
+// name resolves a component, or a derived number such as year, and
+// advances past it.
+func (p *exprParser) name(name string) (scoreExpr, error) {
+	for _, known := range p.known {
+		if strings.EqualFold(known, name) {
+			p.next()
+			return fieldExpr(known), nil
+		}
+	}
+	if f, ok := p.derived[strings.ToLower(name)]; ok {
+		p.next()
+		return derivedExpr(f), nil
+	}
+	return nil, fmt.Errorf("unknown component %q in %q", name, p.src)
+}
+
+func firstRune(s string) rune {
+	r, _ := utf8.DecodeRuneInString(s)
+	return r
+}
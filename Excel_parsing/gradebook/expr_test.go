@@ -0,0 +1,62 @@
+package gradebook
+
+import (
+	"math"
+	"testing"
+)
+
+func TestCompileScoreExpr(t *testing.T) {
+	s := Student{Quiz: 10, MidSem: 30, Compre: 40, Total: 80, Extra: map[string]float64{"Project": 12, "Quiz 1": 4, "Übung": 6}}
+	known := append(append([]string(nil), numericFields...), "Project", "Quiz 1", "Übung")
+	tests := []struct {
+		src  string
+		want float64
+	}{
+		{"Total", 80},
+		{"total", 80},
+		{"  MidSem+Compre ", 70},
+		{"MidSem + Compre * 2", 110},
+		{"(MidSem + Compre) * 2", 140},
+		{"Total - MidSem - Compre", 10},
+		{"Total / 4 / 2", 10},
+		{"-Quiz + 2.5", -7.5},
+		{"--Quiz", 10},
+		{".5 * Project", 6},
+		{"`Quiz 1` * 2", 8},
+		{"`quiz 1`+`Total`", 84},
+		{"Übung + übung", 12},
+	}
+	for _, tt := range tests {
+		e, err := compileScoreExpr(tt.src, known)
+		if err != nil {
+			t.Errorf("%q: %v", tt.src, err)
+			continue
+		}
+		if got := e.eval(s); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%q = %g, want %g", tt.src, got, tt.want)
+		}
+	}
+}
+
+func TestCompileScoreExprErrors(t *testing.T) {
+	for _, src := range []string{
+		"",
+		"Total +",
+		"(Total + Compre",
+		"Total Compre",
+		"Attendance",
+		"1.2.3",
+		"Total % 2",
+		"Total)",
+		"* Total",
+		"`Quiz 1",
+		"`Quiz 1`",
+		"Quiz 1",
+		"Total × 2",
+		"٣ + Total",
+	} {
+		if _, err := compileScoreExpr(src, numericFields); err == nil {
+			t.Errorf("%q compiled", src)
+		}
+	}
+}
@@ -0,0 +1,424 @@
+package gradebook
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Filter is a compiled --where expression that selects students, such as
+//
+//	Compre < 20 && MidSem > 40 || branch == "A7"
+//
+// Numeric operands are components or arithmetic over them, plus year (the
+// admission year from the campus ID); a component whose name is not a
+// single word is written in backquotes, as in `Quiz 1` > 5. Text operands
+// are branch, grade, campus, Emplid, CampusID, ClassNo, Source and Sheet.
+// Comparisons are == != < <= > >=, text also supports =~ and !~ against a
+// regular expression, and either side may test membership with in [...]
+// or not in [...]. Conditions combine with !, && and || and parentheses.
+//
+// branch matches a student's degree codes (A7, B5) as well as their branch
+// names, and Source the workbook's path as well as its file name; text
+// comparisons ignore case.
+//
+// A numeric comparison on a mark the student has no score for, such as an
+// absence left out by the exclude policy, or on a division by zero, is
+// false whatever the operator:
+// neither Compre < 20 nor Compre >= 20, nor Compre not in [...], picks up
+// an absentee, while !(Compre < 20) does.
+type Filter struct {
+	src  string
+	cond condExpr
+}
+
+// CompileFilter parses src against the components present on students.
+func CompileFilter(src string, students []Student) (*Filter, error) {
+	p := &filterParser{exprParser{src: src, known: statComponents(students), derived: derivedNumbers}}
+	p.next()
+	cond, err := p.or()
+	if err != nil {
+		return nil, err
+	}
+	if p.tok != "" {
+		return nil, fmt.Errorf("unexpected %q at offset %d in %q", p.tok, p.start, src)
+	}
+	return &Filter{src: src, cond: cond}, nil
+}
+
+func (f *Filter) String() string { return f.src }
+
+// Match reports whether s satisfies the filter. A nil filter matches
+// everyone.
+func (f *Filter) Match(s Student) bool {
+	return f == nil || f.cond.test(s)
+}
+
+// Apply returns the students that match.
+func (f *Filter) Apply(students []Student) []Student {
+	if f == nil {
+		return students
+	}
+	var out []Student
+	for _, s := range students {
+		if f.cond.test(s) {
+			out = append(out, s)
+		}
+	}
+	return out
+}
+
+var derivedNumbers = map[string]func(Student) float64{
+	"year": func(s Student) float64 {
+		if s.Program != nil {
+			y, _ := strconv.ParseFloat(s.Program.Year, 64)
+			return y
+		}
+		if len(s.CampusID) >= 4 {
+			y, _ := strconv.ParseFloat(s.CampusID[:4], 64)
+			return y
+		}
+		return 0
+	},
+}
+
+// textFields yield every value a text operand has for a student; a test
+// passes when any of them does.
+var textFields = map[string]func(Student) []string{
+	"branch": func(s Student) []string {
+		values := append([]string(nil), studentBranches(s)...)
+		if s.Program != nil {
+			values = append(values, s.Program.FirstDegree)
+			if s.Program.SecondDegree != "" {
+				values = append(values, s.Program.SecondDegree)
+			}
+		}
+		return values
+	},
+	"grade": func(s Student) []string { return []string{s.Grade} },
+	"campus": func(s Student) []string {
+		if s.Program == nil {
+			return []string{""}
+		}
+		return []string{s.Program.Campus}
+	},
+	"emplid":   func(s Student) []string { return []string{s.Emplid} },
+	"campusid": func(s Student) []string { return []string{s.CampusID} },
+	"classno":  func(s Student) []string { return []string{s.ClassNo} },
+	"source":   func(s Student) []string { return []string{s.Source, filepath.Base(s.Source)} },
+	"sheet":    func(s Student) []string { return []string{s.Sheet} },
+}
+
+type condExpr interface {
+	test(s Student) bool
+}
+
+type andCond struct{ l, r condExpr }
+
+func (c andCond) test(s Student) bool { return c.l.test(s) && c.r.test(s) }
+
+type orCond struct{ l, r condExpr }
+
+func (c orCond) test(s Student) bool { return c.l.test(s) || c.r.test(s) }
+
+type notCond struct{ x condExpr }
+
+func (c notCond) test(s Student) bool { return !c.x.test(s) }
+
+type numberCond struct {
+	op   string
+	l, r scoreExpr
+}
+
+func (c numberCond) test(s Student) bool {
+	if scoreMissing(c.l, s) || scoreMissing(c.r, s) {
+		return false
+	}
+	return compareNumbers(c.op, c.l.eval(s), c.r.eval(s))
+}
+
+func compareNumbers(op string, l, r float64) bool {
+	switch op {
+	case "==":
+		return l == r
+	case "!=":
+		return l != r
+	case "<":
+		return l < r
+	case "<=":
+		return l <= r
+	case ">":
+		return l > r
+	default:
+		return l >= r
+	}
+}
+
+type numberInCond struct {
+	x      scoreExpr
+	values []float64
+	negate bool
+}
+
+func (c numberInCond) test(s Student) bool {
+	if scoreMissing(c.x, s) {
+		return false
+	}
+	v := c.x.eval(s)
+	for _, want := range c.values {
+		if v == want {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+// textCond tests a text operand; match is applied to each of its values.
+// Negated operators (!=, !~, not in) pass only when no value matches.
+type textCond struct {
+	field  func(Student) []string
+	match  func(string) bool
+	negate bool
+}
+
+func (c textCond) test(s Student) bool {
+	for _, v := range c.field(s) {
+		if c.match(v) {
+			return !c.negate
+		}
+	}
+	return c.negate
+}
+
+type filterParser struct {
+	exprParser
+}
+
+func (p *filterParser) or() (condExpr, error) {
+	l, err := p.and()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "||" || strings.EqualFold(p.tok, "or") {
+		p.next()
+		r, err := p.and()
+		if err != nil {
+			return nil, err
+		}
+		l = orCond{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) and() (condExpr, error) {
+	l, err := p.not()
+	if err != nil {
+		return nil, err
+	}
+	for p.tok == "&&" || strings.EqualFold(p.tok, "and") {
+		p.next()
+		r, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		l = andCond{l, r}
+	}
+	return l, nil
+}
+
+func (p *filterParser) not() (condExpr, error) {
+	if p.tok == "!" || strings.EqualFold(p.tok, "not") {
+		p.next()
+		x, err := p.not()
+		if err != nil {
+			return nil, err
+		}
+		return notCond{x}, nil
+	}
+	if p.tok == "(" {
+		// A parenthesis either groups conditions or starts arithmetic such
+		// as (MidSem + Compre) / 2 > 50; try the former and fall back.
+		pos, start, tok := p.pos, p.start, p.tok
+		p.next()
+		if cond, err := p.or(); err == nil && p.tok == ")" {
+			p.next()
+			if !isNumberOperator(p.tok) {
+				return cond, nil
+			}
+		}
+		p.pos, p.start, p.tok = pos, start, tok
+	}
+	return p.comparison()
+}
+
+func isNumberOperator(tok string) bool {
+	switch tok {
+	case "+", "-", "*", "/", "==", "!=", "<", "<=", ">", ">=", "in", "not":
+		return true
+	}
+	return false
+}
+
+func (p *filterParser) comparison() (condExpr, error) {
+	if field, ok := textFields[strings.ToLower(p.tok)]; ok {
+		p.next()
+		return p.textComparison(field)
+	}
+
+	l, err := p.sum()
+	if err != nil {
+		return nil, err
+	}
+	op := p.tok
+	switch op {
+	case "==", "!=", "<", "<=", ">", ">=":
+		p.next()
+		r, err := p.sum()
+		if err != nil {
+			return nil, err
+		}
+		return numberCond{op: op, l: l, r: r}, nil
+	case "in", "not":
+		negate, err := p.inOperator()
+		if err != nil {
+			return nil, err
+		}
+		items, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		values := make([]float64, len(items))
+		for i, item := range items {
+			if values[i], err = strconv.ParseFloat(item, 64); err != nil {
+				return nil, fmt.Errorf("%q is not a number in %q", item, p.src)
+			}
+		}
+		return numberInCond{x: l, values: values, negate: negate}, nil
+	case "":
+		return nil, fmt.Errorf("expected a comparison at the end of %q", p.src)
+	}
+	return nil, fmt.Errorf("expected a comparison, found %q at offset %d in %q", op, p.start, p.src)
+}
+
+func (p *filterParser) textComparison(field func(Student) []string) (condExpr, error) {
+	op := p.tok
+	switch op {
+	case "==", "!=":
+		p.next()
+		want, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		match := func(v string) bool { return strings.EqualFold(v, want) }
+		return textCond{field: field, match: match, negate: op == "!="}, nil
+	case "=~", "!~":
+		p.next()
+		pattern, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		re, err := regexp.Compile("(?i)" + pattern)
+		if err != nil {
+			return nil, fmt.Errorf("bad regular expression %q: %w", pattern, err)
+		}
+		return textCond{field: field, match: re.MatchString, negate: op == "!~"}, nil
+	case "in", "not":
+		negate, err := p.inOperator()
+		if err != nil {
+			return nil, err
+		}
+		items, err := p.list()
+		if err != nil {
+			return nil, err
+		}
+		match := func(v string) bool {
+			for _, item := range items {
+				if strings.EqualFold(v, item) {
+					return true
+				}
+			}
+			return false
+		}
+		return textCond{field: field, match: match, negate: negate}, nil
+	case "":
+		return nil, fmt.Errorf("expected a comparison at the end of %q", p.src)
+	}
+	return nil, fmt.Errorf("expected ==, !=, =~, !~ or in, found %q at offset %d in %q", op, p.start, p.src)
+}
+
+// inOperator consumes "in" or "not in" and reports whether it was negated.
+func (p *filterParser) inOperator() (bool, error) {
+	negate := p.tok == "not"
+	if negate {
+		p.next()
+	}
+	if p.tok != "in" {
+		return false, fmt.Errorf("expected in, found %q at offset %d in %q", p.tok, p.start, p.src)
+	}
+	p.next()
+	return negate, nil
+}
+
+// list parses [a, b, ...] or (a, b, ...).
+func (p *filterParser) list() ([]string, error) {
+	closing := map[string]string{"[": "]", "(": ")"}[p.tok]
+	if closing == "" {
+		return nil, fmt.Errorf("expected a list after in, found %q in %q", p.tok, p.src)
+	}
+	p.next()
+	var items []string
+	for p.tok != closing {
+		item, err := p.literal()
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+		if p.tok == "," {
+			p.next()
+		} else if p.tok != closing {
+			return nil, fmt.Errorf("expected , or %s in list, found %q in %q", closing, p.tok, p.src)
+		}
+	}
+	p.next()
+	return items, nil
+}
+
+// literal reads a quoted string, a number or a bare word; bare words let
+// codes such as A7 be written without quotes.
+func (p *filterParser) literal() (string, error) {
+	tok := p.tok
+	switch {
+	case tok == "":
+		return "", fmt.Errorf("unexpected end of expression %q", p.src)
+	case tok[0] == '"' || tok[0] == '\'':
+		if len(tok) < 2 || tok[len(tok)-1] != tok[0] {
+			return "", fmt.Errorf("unterminated string in %q", p.src)
+		}
+		// Backslashes are kept so regular expressions such as "^\d+$"
+		// need no double escaping; only an escaped quote is unescaped.
+		quote := tok[:1]
+		p.next()
+		return strings.ReplaceAll(tok[1:len(tok)-1], `\`+quote, quote), nil
+	case tok == "-":
+		p.next()
+		v, err := p.literal()
+		return "-" + v, err
+	case isWordStart(firstRune(tok)):
+		// A bare word followed directly by digits, like 2023A7, lexes as a
+		// number then an identifier; join adjacent tokens back up.
+		start, end := p.start, p.pos
+		p.next()
+		for p.tok != "" && p.start == end && isWordStart(firstRune(p.tok)) {
+			end = p.pos
+			p.next()
+		}
+		return p.src[start:end], nil
+	}
+	return "", fmt.Errorf("expected a value, found %q at offset %d in %q", tok, p.start, p.src)
+}
+
+func isWordStart(r rune) bool {
+	return r == '.' || isDigit(r) || isIdentStart(r)
+}
@@ -0,0 +1,145 @@
+package gradebook
+
+import (
+	"reflect"
+	"testing"
+)
+
+// filterStudents are three students from two workbooks; 3 is a dual
+// degree student from Goa, grouped under their first degree, B4.
+func filterStudents(t *testing.T) []Student {
+	t.Helper()
+	students := []Student{
+		{Emplid: "1", CampusID: "2022A7PS0001P", ClassNo: "1", MidSem: 45, Compre: 15, Total: 60, Grade: "C", Source: "sem1/CSF111.xlsx", Sheet: "Marks", Extra: map[string]float64{"Mid Lab": 3}},
+		{Emplid: "2", CampusID: "2023A3PS0002P", ClassNo: "2", MidSem: 30, Compre: 60, Total: 90, Grade: "A", Source: "sem1/CSF111.xlsx", Sheet: "Marks", Extra: map[string]float64{"Mid Lab": 7}},
+		{Emplid: "3", CampusID: "2021B4A70003G", ClassNo: "2", MidSem: 20, Compre: 40, Total: 60, Grade: "C-", Source: "sem2/CSF111.xlsx", Sheet: "Sheet1"},
+	}
+	r, err := NewBranchRegistry(BranchConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.assign(students)
+	return students
+}
+
+func TestFilter(t *testing.T) {
+	students := filterStudents(t)
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"Compre < 20 && MidSem > 40", []string{"1"}},
+		{"Compre < 20 and MidSem > 40 or branch == A3", []string{"1", "2"}},
+		{"!(Total == 60)", []string{"2"}},
+		{"not Total == 60", []string{"2"}},
+		{"(MidSem + Compre) / 2 > 40", []string{"2"}},
+		{"(Total > 80 || Compre > 35) && ClassNo == 2", []string{"2", "3"}},
+		{"-MidSem < -40", []string{"1"}},
+		{"year >= 2022", []string{"1", "2"}},
+		{"year in [2021, 2023]", []string{"2", "3"}},
+		{"Total not in (60)", []string{"2"}},
+		{`branch == "computer science"`, []string{"1"}},
+		{"branch == A7", []string{"1", "3"}},
+		{"branch != A7", []string{"2"}},
+		{"branch in [B4, A3]", []string{"2", "3"}},
+		{"branch not in [A3]", []string{"1", "3"}},
+		{"campus == G", []string{"3"}},
+		{`grade =~ "^C"`, []string{"1", "3"}},
+		{`grade !~ "-$"`, []string{"1", "2"}},
+		{"CampusID == 2022A7PS0001P", []string{"1"}},
+		{`emplid =~ "^\d$"`, []string{"1", "2", "3"}},
+		{"source == CSF111.xlsx", []string{"1", "2", "3"}},
+		{`source == "sem2/CSF111.xlsx"`, []string{"3"}},
+		{"sheet == 'marks'", []string{"1", "2"}},
+		{"`Mid Lab` > 5 && `mid lab` < 9", []string{"2"}},
+		{"campus in [G, P] && branch != Économie", []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		f, err := CompileFilter(tt.where, students)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		var got []string
+		for _, s := range f.Apply(students) {
+			got = append(got, s.Emplid)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matched %v, want %v", tt.where, got, tt.want)
+		}
+	}
+}
+
+func TestFilterErrors(t *testing.T) {
+	students := filterStudents(t)
+	for _, where := range []string{
+		"",
+		"Total",
+		"Total >",
+		"Attendance > 3",
+		"branch",
+		"branch < A7",
+		`branch =~ "("`,
+		`grade == "A`,
+		"Total in [A]",
+		"Total in 60",
+		"branch in [A7 A3]",
+		"Total not 60",
+		"`branch` == A7",
+		"`Mid Lab > 5",
+		"(Total > 50",
+		"Total > 50)",
+	} {
+		if _, err := CompileFilter(where, students); err == nil {
+			t.Errorf("%q compiled", where)
+		}
+	}
+}
+
+func TestNilFilter(t *testing.T) {
+	var f *Filter
+	students := filterStudents(t)
+	if !f.Match(students[0]) || len(f.Apply(students)) != len(students) {
+		t.Error("a nil filter does not match everyone")
+	}
+}
+
+func TestFilterMissingMarks(t *testing.T) {
+	// 2 was absent for the Compre and left out; 3 scored zero in it.
+	students := []Student{
+		{Emplid: "1", MidSem: 30, Compre: 10, Total: 40},
+		{Emplid: "2", MidSem: 40, Total: 40, Special: map[string]SpecialMark{
+			FieldCompre: {Marker: "AB", State: MarkAbsent, Policy: PolicyExclude},
+		}},
+		{Emplid: "3", MidSem: 20, Compre: 0, Total: 20, Special: map[string]SpecialMark{
+			FieldCompre: {Marker: "AB", State: MarkAbsent, Policy: PolicyZero},
+		}},
+	}
+	tests := []struct {
+		where string
+		want  []string
+	}{
+		{"Compre < 20", []string{"1", "3"}},
+		{"Compre >= 20", nil},
+		{"Compre != 10", []string{"3"}},
+		{"MidSem + Compre > 35", []string{"1"}},
+		{"Compre in [0, 10]", []string{"1", "3"}},
+		{"Compre not in [10]", []string{"3"}},
+		{"!(Compre < 20)", []string{"2"}},
+		{"Compre < 20 || MidSem > 35", []string{"1", "2", "3"}},
+	}
+	for _, tt := range tests {
+		f, err := CompileFilter(tt.where, students)
+		if err != nil {
+			t.Errorf("%s: %v", tt.where, err)
+			continue
+		}
+		var got []string
+		for _, s := range f.Apply(students) {
+			got = append(got, s.Emplid)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: matched %v, want %v", tt.where, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,76 @@
+package gradebook
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const apiMarks = `Class No.,Emplid,Campus ID,Mid Sem,Compre,Total
+1,101,2022A7PS0001P,30,50,80
+1,102,2022A3PS0002P,20,40,60
+2,103,2023A7PS0003P,25,45,70
+2,104,2023A7PS0004P,10,20,30
+2,,2023A7PS0005P,10,20,30
+`
+
+// TestLoadGenerateExport drives the package the way an embedding tool
+// would: load from a reader, build the report and export it.
+func TestLoadGenerateExport(t *testing.T) {
+	students, validation, err := Load(strings.NewReader(apiMarks), "marks.csv", LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 4 || len(validation.Issues) == 0 {
+		t.Fatalf("loaded %d students with %d issues, want 4 and the row without an Emplid", len(students), len(validation.Issues))
+	}
+
+	report, err := Generate(students, Options{Rank: DefaultRankOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var top []string
+	for _, r := range report.OverallTopStudents {
+		top = append(top, r.Emplid)
+	}
+	if want := []string{"101", "103", "102"}; !reflect.DeepEqual(top, want) {
+		t.Errorf("top students %v, want %v", top, want)
+	}
+	if students[0].Emplid != "101" || students[1].Emplid != "102" {
+		t.Error("Generate reordered the caller's students")
+	}
+
+	path := filepath.Join(t.TempDir(), "summary.json")
+	out, err := Export(report, "json", path)
+	if err != nil || out != path {
+		t.Fatalf("exported to %q: %v", out, err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !json.Valid(data) {
+		t.Errorf("%s is not JSON", path)
+	}
+}
+
+func TestAPIErrors(t *testing.T) {
+	if _, _, err := Load(strings.NewReader("Name,Marks\nA,1\n"), "marks.csv", LoadOptions{}); err == nil {
+		t.Error("loaded a sheet without an Emplid column")
+	}
+	if _, err := Generate(nil, Options{Rank: RankOptions{By: "Attendance"}}); err == nil {
+		t.Error("ranked by an unknown component")
+	}
+	if _, err := Export(SummaryReport{}, "docx", ""); err == nil {
+		t.Error("exported as docx")
+	}
+	if _, err := Export(SummaryReport{}, "csv", "-"); err == nil {
+		t.Error("exported csv to standard output")
+	}
+	if _, err := os.Stat("-"); !os.IsNotExist(err) {
+		t.Error(`csv export to "-" created a directory`)
+	}
+}
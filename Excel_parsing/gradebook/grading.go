@@ -0,0 +1,272 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Letter grades from best to worst. NC (not cleared) is awarded below the
+// configured floor rather than through a cutoff of its own.
+var letterGrades = []string{"A", "A-", "B", "B-", "C", "C-", "D", "E"}
+
+const gradeNC = "NC"
+
+// Grading schemes.
+const (
+	SchemeFixed      = "fixed"
+	SchemeMeanSD     = "meansd"
+	SchemePercentile = "percentile"
+	SchemeFile       = "file"
+)
+
+var GradingSchemes = []string{SchemeFixed, SchemeMeanSD, SchemePercentile, SchemeFile}
+
+// GradingConfig describes how letter grades are awarded from Total.
+// Cutoffs are minimum Totals (fixed and file schemes), Bands are numbers of
+// standard deviations from the mean (meansd) and Quotas are the percentage
+// of the class given each grade (percentile). Totals below NCBelow get NC
+// whatever the scheme.
+type GradingConfig struct {
+	Scheme  string             `json:"scheme" yaml:"scheme"`
+	Cutoffs map[string]float64 `json:"cutoffs" yaml:"cutoffs"`
+	Bands   map[string]float64 `json:"bands" yaml:"bands"`
+	Quotas  map[string]float64 `json:"quotas" yaml:"quotas"`
+	NCBelow float64            `json:"nc_below" yaml:"nc_below"`
+}
+
+// Default fixed cutoffs, as percentages of the maximum Total.
+var defaultFixedCutoffs = map[string]float64{
+	"A": 80, "A-": 70, "B": 60, "B-": 55, "C": 50, "C-": 45, "D": 40, "E": 0,
+}
+
+var defaultBands = map[string]float64{
+	"A": 1.5, "A-": 1, "B": 0.5, "B-": 0, "C": -0.5, "C-": -1, "D": -1.5, "E": math.Inf(-1),
+}
+
+var defaultQuotas = map[string]float64{
+	"A": 10, "A-": 15, "B": 20, "B-": 20, "C": 15, "C-": 10, "D": 5, "E": 5,
+}
+
+// GradingSummary is the grading section of the report. By is the score
+// graded on, Total unless grades follow the normalized total. Ungraded
+// counts the students without that score, who get no grade and take no
+// part in working out the cutoffs.
+type GradingSummary struct {
+	Scheme       string                    `json:"scheme"`
+	By           string                    `json:"by"`
+	Cutoffs      map[string]float64        `json:"cutoffs"`
+	Distribution map[string]int            `json:"distribution"`
+	ByBranch     map[string]map[string]int `json:"by_branch"`
+	BySection    map[string]map[string]int `json:"by_section"`
+	Ungraded     int                       `json:"ungraded,omitempty"`
+}
+
+func LoadGradingConfig(path, scheme string) (GradingConfig, error) {
+	var cfg GradingConfig
+	if path != "" {
+		if err := LoadConfigFile(path, &cfg); err != nil {
+			return cfg, err
+		}
+	}
+	if scheme != "" {
+		cfg.Scheme = scheme
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = SchemeFixed
+	}
+	if !contains(GradingSchemes, cfg.Scheme) {
+		return cfg, fmt.Errorf("unknown grading scheme %q (want one of %s)",
+			cfg.Scheme, strings.Join(GradingSchemes, ", "))
+	}
+	if cfg.Scheme == SchemeFile && len(cfg.Cutoffs) == 0 {
+		return cfg, fmt.Errorf("grading scheme %q needs cutoffs in a grading config file", SchemeFile)
+	}
+	for _, m := range []map[string]float64{cfg.Cutoffs, cfg.Bands, cfg.Quotas} {
+		for grade := range m {
+			if !contains(letterGrades, grade) {
+				return cfg, fmt.Errorf("unknown grade %q in grading config", grade)
+			}
+		}
+	}
+	return cfg, nil
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// gradeCutoffs works out the minimum Total for each grade under cfg.
+// maxTotal scales the default fixed cutoffs, which are percentages.
+func gradeCutoffs(scores []float64, cfg GradingConfig, maxTotal float64) map[string]float64 {
+	cutoffs := make(map[string]float64)
+	switch cfg.Scheme {
+	case SchemeFixed, SchemeFile:
+		if len(cfg.Cutoffs) > 0 {
+			for g, c := range cfg.Cutoffs {
+				cutoffs[g] = c
+			}
+			break
+		}
+		for g, pct := range defaultFixedCutoffs {
+			cutoffs[g] = pct / 100 * maxTotal
+		}
+
+	case SchemeMeanSD:
+		bands := cfg.Bands
+		if len(bands) == 0 {
+			bands = defaultBands
+		}
+		mean, sd := meanSD(scores)
+		for g, k := range bands {
+			cutoffs[g] = mean + k*sd
+		}
+
+	case SchemePercentile:
+		quotas := cfg.Quotas
+		if len(quotas) == 0 {
+			quotas = defaultQuotas
+		}
+		sorted := append([]float64(nil), scores...)
+		sort.Sort(sort.Reverse(sort.Float64Slice(sorted)))
+		if len(sorted) == 0 {
+			break
+		}
+		cumulative := 0.0
+		for _, g := range letterGrades {
+			q, ok := quotas[g]
+			if !ok || q <= 0 {
+				continue
+			}
+			cumulative += q
+			i := int(math.Ceil(cumulative/100*float64(len(sorted)))) - 1
+			if i >= len(sorted) {
+				i = len(sorted) - 1
+			}
+			if i < 0 {
+				i = 0
+			}
+			cutoffs[g] = sorted[i]
+		}
+	}
+	return cutoffs
+}
+
+// gradeFor returns the best grade whose cutoff Total reaches.
+func gradeFor(total float64, cutoffs map[string]float64, ncBelow float64) string {
+	if total < ncBelow {
+		return gradeNC
+	}
+	for _, g := range letterGrades {
+		if c, ok := cutoffs[g]; ok && total >= c {
+			return g
+		}
+	}
+	return gradeNC
+}
+
+// assignGrades sets Grade on every student with a score and summarises
+// the outcome.
+func assignGrades(students []Student, cfg GradingConfig, maxTotal float64, by string) GradingSummary {
+	scores := make([]float64, len(students))
+	scored := make([]bool, len(students))
+	var present []float64
+	for i, s := range students {
+		scores[i], scored[i] = s.Component(by)
+		if scored[i] {
+			present = append(present, scores[i])
+		}
+	}
+	cutoffs := gradeCutoffs(present, cfg, maxTotal)
+	summary := GradingSummary{
+		Scheme:       cfg.Scheme,
+		By:           by,
+		Cutoffs:      make(map[string]float64),
+		Distribution: make(map[string]int),
+		ByBranch:     make(map[string]map[string]int),
+		BySection:    make(map[string]map[string]int),
+	}
+	for g, c := range cutoffs {
+		// JSON cannot carry -Inf; an open bottom band is reported as absent.
+		if !math.IsInf(c, 0) {
+			summary.Cutoffs[g] = c
+		}
+	}
+
+	for i := range students {
+		s := &students[i]
+		if !scored[i] {
+			s.Grade = ""
+			summary.Ungraded++
+			continue
+		}
+		s.Grade = gradeFor(scores[i], cutoffs, cfg.NCBelow)
+		summary.Distribution[s.Grade]++
+		for _, b := range studentBranches(*s) {
+			countGrade(summary.ByBranch, b, s.Grade)
+		}
+		countGrade(summary.BySection, s.ClassNo, s.Grade)
+	}
+	return summary
+}
+
+func countGrade(m map[string]map[string]int, key, grade string) {
+	if m[key] == nil {
+		m[key] = make(map[string]int)
+	}
+	m[key][grade]++
+}
+
+// allGrades lists grades in report order, including NC.
+func allGrades() []string {
+	return append(append([]string(nil), letterGrades...), gradeNC)
+}
+
+func gradingTables(g *GradingSummary) []Table {
+	by := g.By
+	if by == "" {
+		by = FieldTotal
+	}
+	cut := Table{Name: "grade_cutoffs", Title: "Grade Cutoffs (" + g.Scheme + ")", Header: []string{"Grade", "Minimum " + by}}
+	for _, grade := range letterGrades {
+		if c, ok := g.Cutoffs[grade]; ok {
+			cut.Rows = append(cut.Rows, []interface{}{grade, c})
+		}
+	}
+
+	title := "Grade Distribution"
+	if g.Ungraded > 0 {
+		title += fmt.Sprintf(" (%d ungraded without a %s score)", g.Ungraded, by)
+	}
+	dist := Table{Name: "grade_distribution", Title: title, Header: append([]string{"Group"}, allGrades()...)}
+	addRow := func(label string, counts map[string]int) {
+		row := []interface{}{label}
+		for _, grade := range allGrades() {
+			row = append(row, counts[grade])
+		}
+		dist.Rows = append(dist.Rows, row)
+	}
+	addRow("Overall", g.Distribution)
+	for _, key := range sortedGroupKeys(g.ByBranch) {
+		addRow("Branch "+key, g.ByBranch[key])
+	}
+	for _, key := range sortedGroupKeys(g.BySection) {
+		addRow("Section "+key, g.BySection[key])
+	}
+	return []Table{cut, dist}
+}
+
+func sortedGroupKeys(m map[string]map[string]int) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,150 @@
+package gradebook
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestGradeCutoffs(t *testing.T) {
+	// Ten students scoring 10, 20, ..., 100: mean 55, SD about 28.72.
+	var scores []float64
+	for i := 1; i <= 10; i++ {
+		scores = append(scores, float64(10*i))
+	}
+	_, sd := meanSD(scores)
+	tests := []struct {
+		name     string
+		cfg      GradingConfig
+		maxTotal float64
+		want     map[string]float64
+	}{
+		{
+			name: "fixed defaults scaled", cfg: GradingConfig{Scheme: SchemeFixed}, maxTotal: 200,
+			want: map[string]float64{"A": 160, "A-": 140, "B": 120, "B-": 110, "C": 100, "C-": 90, "D": 80, "E": 0},
+		},
+		{
+			name: "file cutoffs", cfg: GradingConfig{Scheme: SchemeFile, Cutoffs: map[string]float64{"A": 90, "E": 30}}, maxTotal: 100,
+			want: map[string]float64{"A": 90, "E": 30},
+		},
+		{
+			name: "mean and SD bands", cfg: GradingConfig{Scheme: SchemeMeanSD, Bands: map[string]float64{"A": 1, "B": 0, "E": math.Inf(-1)}},
+			want: map[string]float64{"A": 55 + sd, "B": 55, "E": math.Inf(-1)},
+		},
+		{
+			name: "percentile quotas", cfg: GradingConfig{Scheme: SchemePercentile, Quotas: map[string]float64{"A": 20, "B": 30, "C": 0, "E": 50}},
+			want: map[string]float64{"A": 90, "B": 60, "E": 10},
+		},
+		{
+			name: "quotas over 100%", cfg: GradingConfig{Scheme: SchemePercentile, Quotas: map[string]float64{"A": 15, "E": 100}},
+			want: map[string]float64{"A": 90, "E": 10},
+		},
+	}
+	for _, tt := range tests {
+		got := gradeCutoffs(scores, tt.cfg, tt.maxTotal)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: cutoffs %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for g, c := range tt.want {
+			if math.Abs(got[g]-c) > 1e-9 && got[g] != c {
+				t.Errorf("%s: %s cutoff %g, want %g", tt.name, g, got[g], c)
+			}
+		}
+	}
+	if got := gradeCutoffs(nil, GradingConfig{Scheme: SchemePercentile}, 100); len(got) != 0 {
+		t.Errorf("percentile cutoffs with no scores: %v", got)
+	}
+}
+
+func TestGradeFor(t *testing.T) {
+	cutoffs := map[string]float64{"A": 80, "B": 60, "D": 40}
+	tests := []struct {
+		total, ncBelow float64
+		want           string
+	}{
+		{95, 0, "A"},
+		{80, 0, "A"},
+		{79.99, 0, "B"},
+		{40, 0, "D"},
+		{39, 0, gradeNC},
+		{85, 90, gradeNC},
+	}
+	for _, tt := range tests {
+		if got := gradeFor(tt.total, cutoffs, tt.ncBelow); got != tt.want {
+			t.Errorf("gradeFor(%g, nc below %g) = %s, want %s", tt.total, tt.ncBelow, got, tt.want)
+		}
+	}
+}
+
+func TestAssignGrades(t *testing.T) {
+	students := []Student{
+		{Emplid: "1", CampusID: "2022A7PS0001P", ClassNo: "1", Total: 85},
+		{Emplid: "2", CampusID: "2022A3PS0002P", ClassNo: "1", Total: 65},
+		{Emplid: "3", CampusID: "2022A7PS0003P", ClassNo: "2", Total: 62},
+		{Emplid: "4", CampusID: "2022A3PS0004P", ClassNo: "2", Total: 20},
+	}
+	cfg := GradingConfig{Scheme: SchemeFixed, NCBelow: 25}
+	summary := assignGrades(students, cfg, 100, FieldTotal)
+
+	var grades []string
+	for _, s := range students {
+		grades = append(grades, s.Grade)
+	}
+	if want := []string{"A", "B", "B", gradeNC}; !reflect.DeepEqual(grades, want) {
+		t.Errorf("grades %v, want %v", grades, want)
+	}
+	if want := map[string]int{"A": 1, "B": 2, gradeNC: 1}; !reflect.DeepEqual(summary.Distribution, want) {
+		t.Errorf("distribution %v, want %v", summary.Distribution, want)
+	}
+	if want := map[string]int{"B": 1, gradeNC: 1}; !reflect.DeepEqual(summary.BySection["2"], want) {
+		t.Errorf("section 2 %v, want %v", summary.BySection["2"], want)
+	}
+	if summary.Cutoffs["A"] != 80 || summary.Ungraded != 0 {
+		t.Errorf("cutoffs %v, %d ungraded", summary.Cutoffs, summary.Ungraded)
+	}
+
+	// An open bottom band cannot go into JSON and is left out.
+	summary = assignGrades(students, GradingConfig{Scheme: SchemeMeanSD}, 100, FieldTotal)
+	if _, ok := summary.Cutoffs["E"]; ok || len(summary.Cutoffs) != len(letterGrades)-1 {
+		t.Errorf("mean/SD cutoffs %v, want all but E", summary.Cutoffs)
+	}
+}
+
+func TestLoadGradingConfig(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	file := write("grading.yaml", "scheme: file\ncutoffs:\n  A: 75\n  E: 30\nnc_below: 20\n")
+	tests := []struct {
+		name, path, scheme string
+		want               GradingConfig
+		err                bool
+	}{
+		{name: "defaults", want: GradingConfig{Scheme: SchemeFixed}},
+		{name: "scheme flag", scheme: SchemeMeanSD, want: GradingConfig{Scheme: SchemeMeanSD}},
+		{name: "file", path: file, want: GradingConfig{Scheme: SchemeFile, Cutoffs: map[string]float64{"A": 75, "E": 30}, NCBelow: 20}},
+		{name: "flag overrides file", path: file, scheme: SchemePercentile,
+			want: GradingConfig{Scheme: SchemePercentile, Cutoffs: map[string]float64{"A": 75, "E": 30}, NCBelow: 20}},
+		{name: "unknown scheme", scheme: "curve", err: true},
+		{name: "file scheme without cutoffs", scheme: SchemeFile, err: true},
+		{name: "unknown grade", path: write("bad.json", `{"quotas": {"A+": 5}}`), err: true},
+	}
+	for _, tt := range tests {
+		cfg, err := LoadGradingConfig(tt.path, tt.scheme)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && !reflect.DeepEqual(cfg, tt.want) {
+			t.Errorf("%s: %+v, want %+v", tt.name, cfg, tt.want)
+		}
+	}
+}
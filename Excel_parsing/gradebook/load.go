@@ -0,0 +1,281 @@
+package gradebook
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+// SheetSelection says which sheets of each workbook to read. With neither
+// field set only the first sheet is read.
+type SheetSelection struct {
+	Name string
+	All  bool
+}
+
+// LoadOptions configure a Loader. A nil Branches uses the built-in
+// registry.
+type LoadOptions struct {
+	Columns  ColumnConfig
+	Reader   ReaderOptions
+	Sheets   SheetSelection
+	Branches *BranchRegistry
+}
+
+// Loader reads gradebooks into students. It keeps one validation report
+// across everything it loads so that duplicate Emplids are caught between
+// sheets and workbooks.
+type Loader struct {
+	opts    LoadOptions
+	v       *validator
+	unknown map[string]bool
+}
+
+// NewLoader returns a Loader; see LoadOptions for the defaults.
+func NewLoader(opts LoadOptions) (*Loader, error) {
+	if opts.Branches == nil {
+		reg, err := NewBranchRegistry(BranchConfig{})
+		if err != nil {
+			return nil, err
+		}
+		opts.Branches = reg
+	}
+	return &Loader{
+		opts:    opts,
+		v:       newValidator(opts.Columns, opts.Branches),
+		unknown: make(map[string]bool),
+	}, nil
+}
+
+// Load reads a gradebook from a single file in one call.
+func Load(r io.Reader, name string, opts LoadOptions) ([]Student, ValidationReport, error) {
+	l, err := NewLoader(opts)
+	if err != nil {
+		return nil, ValidationReport{}, err
+	}
+	students, err := l.Load(r, name)
+	return students, l.Validation(), err
+}
+
+// LoadFile reads the selected sheets of the workbook at path.
+func (l *Loader) LoadFile(path string) ([]Student, error) {
+	return l.LoadFiles([]string{path})
+}
+
+// LoadFiles reads several workbooks concurrently. Students come back in
+// the order of paths, and validation, including duplicate Emplids across
+// files, is reported exactly as if the files were read one by one.
+//
+// Rows are validated as they are parsed rather than collected per
+// workbook: each workbook being read holds at most rowBuffer parsed rows
+// until the validator reaches it, so apart from the students returned,
+// memory is bounded by GOMAXPROCS workbooks in flight. XLSX sheets are
+// read row by row; the CSV and ODS readers hold one sheet at a time.
+func (l *Loader) LoadFiles(paths []string) ([]Student, error) {
+	type workbookRows struct {
+		rows    chan parsedRow
+		skipped []SkippedSheet
+		err     error
+	}
+	results := make([]*workbookRows, len(paths))
+	for i := range results {
+		results[i] = &workbookRows{rows: make(chan parsedRow, rowBuffer)}
+	}
+	done := make(chan struct{})
+	defer close(done)
+
+	// Workbooks start in the order of paths, so the one the validator
+	// waits for always holds a slot and the others cannot starve it.
+	sem := make(chan struct{}, runtime.GOMAXPROCS(0))
+	go func() {
+		for i, path := range paths {
+			select {
+			case sem <- struct{}{}:
+			case <-done:
+				return
+			}
+			go func(r *workbookRows, path string) {
+				defer func() { <-sem }()
+				defer close(r.rows)
+				wb, err := OpenWorkbook(path, l.opts.Reader)
+				if err != nil {
+					r.err = err
+					return
+				}
+				defer wb.Close()
+				r.skipped, r.err = l.parseWorkbook(wb, filepath.Clean(path), func(p parsedRow) bool {
+					select {
+					case r.rows <- p:
+						return true
+					case <-done:
+						return false
+					}
+				})
+			}(results[i], path)
+		}
+	}()
+
+	var students []Student
+	for i, r := range results {
+		for p := range r.rows {
+			if s, ok := l.v.add(p); ok {
+				students = append(students, s)
+			}
+		}
+		if r.err != nil {
+			return nil, fmt.Errorf("%s: %w", paths[i], r.err)
+		}
+		l.v.report.SkippedSheets = append(l.v.report.SkippedSheets, r.skipped...)
+	}
+	l.assignBranches(students)
+	return students, nil
+}
+
+// rowBuffer is how many parsed rows a workbook can get ahead of the
+// validator.
+const rowBuffer = 256
+
+// Load reads the selected sheets of a workbook from r. The name is used to
+// detect the format and is recorded as every student's Source.
+func (l *Loader) Load(r io.Reader, name string) ([]Student, error) {
+	wb, err := OpenWorkbookReader(r, name, l.opts.Reader)
+	if err != nil {
+		return nil, err
+	}
+	defer wb.Close()
+
+	var students []Student
+	skipped, err := l.parseWorkbook(wb, name, func(p parsedRow) bool {
+		if s, ok := l.v.add(p); ok {
+			students = append(students, s)
+		}
+		return true
+	})
+	if err != nil {
+		return nil, err
+	}
+	l.v.report.SkippedSheets = append(l.v.report.SkippedSheets, skipped...)
+	l.assignBranches(students)
+	return students, nil
+}
+
+func (l *Loader) assignBranches(students []Student) {
+	for _, code := range l.opts.Branches.assign(students) {
+		l.unknown[code] = true
+	}
+}
+
+// parseWorkbook parses the selected sheets of wb, handing each row to emit
+// in order; it stops early when emit returns false. It does not touch the
+// loader's report, so workbooks can be parsed concurrently.
+func (l *Loader) parseWorkbook(wb Workbook, name string, emit func(parsedRow) bool) ([]SkippedSheet, error) {
+	sheets, err := selectSheets(wb.Sheets(), l.opts.Sheets)
+	if err != nil {
+		return nil, err
+	}
+
+	var skipped []SkippedSheet
+	for _, sheet := range sheets {
+		err := l.parseSheet(wb, name, sheet, emit)
+		if err != nil {
+			var headerErr headerError
+			if l.opts.Sheets.All && errors.As(err, &headerErr) {
+				// Workbooks often carry notes or pivot sheets next to the
+				// gradebook; skip those rather than failing the run.
+				skipped = append(skipped, SkippedSheet{File: name, Sheet: sheet, Reason: headerErr.Error()})
+				continue
+			}
+			return nil, fmt.Errorf("sheet %q: %w", sheet, err)
+		}
+	}
+	return skipped, nil
+}
+
+// headerError is returned when a sheet has no usable header row.
+type headerError struct{ error }
+
+// errStopped is returned when emit asks parsing to stop.
+var errStopped = errors.New("stopped")
+
+// parseSheet streams the rows of one sheet to emit. Only the first few
+// rows are buffered, to find the header; the rest are parsed as they are
+// read.
+func (l *Loader) parseSheet(wb Workbook, file, sheet string, emit func(parsedRow) bool) error {
+	it, err := wb.Rows(sheet)
+	if err != nil {
+		return err
+	}
+	defer it.Close()
+
+	var head [][]string
+	for len(head) < headerSearchRows && it.Next() {
+		head = append(head, it.Row())
+	}
+	if err := it.Err(); err != nil {
+		return err
+	}
+	cols, err := findColumns(head, l.opts.Columns)
+	if err != nil {
+		return headerError{err}
+	}
+
+	add := func(i int, row []string) bool {
+		if isBlankRow(row) {
+			return true
+		}
+		p := l.v.parseRow(file, sheet, i+1, row, &cols)
+		p.student.Source = file
+		p.student.Sheet = sheet
+		p.student.Row = i + 1
+		return emit(p)
+	}
+	for i := cols.HeaderRow + 1; i < len(head); i++ {
+		if !add(i, head[i]) {
+			return errStopped
+		}
+	}
+	for i := len(head); it.Next(); i++ {
+		if !add(i, it.Row()) {
+			return errStopped
+		}
+	}
+	return it.Err()
+}
+
+// Validation returns the issues found in everything loaded so far.
+func (l *Loader) Validation() ValidationReport {
+	return l.v.report
+}
+
+// UnknownBranchCodes lists degree codes seen so far that the branch
+// registry has no name for.
+func (l *Loader) UnknownBranchCodes() []string {
+	codes := make([]string, 0, len(l.unknown))
+	for code := range l.unknown {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+func selectSheets(sheets []string, sel SheetSelection) ([]string, error) {
+	if len(sheets) == 0 {
+		return nil, fmt.Errorf("no sheets found")
+	}
+	switch {
+	case sel.All:
+		return sheets, nil
+	case sel.Name != "":
+		for _, s := range sheets {
+			if s == sel.Name {
+				return []string{s}, nil
+			}
+		}
+		return nil, fmt.Errorf("no sheet named %q", sel.Name)
+	default:
+		return sheets[:1], nil
+	}
+}
@@ -0,0 +1,292 @@
+package gradebook
+
+import (
+	"fmt"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/xuri/excelize/v2"
+)
+
+var gradebookHeader = []interface{}{
+	"Class No.", "Emplid", "Campus ID", "Quiz", "Mid-Sem", "Lab Test",
+	"Weekly Labs", "Pre-Compre", "Compre", "Total",
+}
+
+// writeGradebook writes students to a new XLSX gradebook at path.
+func writeGradebook(tb testing.TB, path string, students []Student) {
+	tb.Helper()
+	f := excelize.NewFile()
+	defer f.Close()
+	sw, err := f.NewStreamWriter("Sheet1")
+	if err != nil {
+		tb.Fatal(err)
+	}
+	if err := sw.SetRow("A1", gradebookHeader); err != nil {
+		tb.Fatal(err)
+	}
+	for i, s := range students {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		row := []interface{}{
+			s.ClassNo, s.Emplid, s.CampusID, s.Quiz, s.MidSem, s.LabTest,
+			s.WeeklyLabs, s.PreCompre, s.Compre, s.Total,
+		}
+		if err := sw.SetRow(cell, row); err != nil {
+			tb.Fatal(err)
+		}
+	}
+	if err := sw.Flush(); err != nil {
+		tb.Fatal(err)
+	}
+	if err := f.SaveAs(path); err != nil {
+		tb.Fatal(err)
+	}
+}
+
+// writeGradebooks splits students over files workbooks in a temporary
+// directory and returns their paths.
+func writeGradebooks(tb testing.TB, students []Student, files int) []string {
+	tb.Helper()
+	dir := tb.TempDir()
+	var paths []string
+	per := (len(students) + files - 1) / files
+	for i := 0; i < files; i++ {
+		lo, hi := i*per, (i+1)*per
+		if hi > len(students) {
+			hi = len(students)
+		}
+		path := filepath.Join(dir, fmt.Sprintf("part%02d.xlsx", i))
+		writeGradebook(tb, path, students[lo:hi])
+		paths = append(paths, path)
+	}
+	return paths
+}
+
+func TestLoadFilesKeepsPathOrder(t *testing.T) {
+	// More rows per workbook than rowBuffer, so workers block on the
+	// validator while it is still reading an earlier workbook.
+	students := syntheticStudents(4 * (rowBuffer + 50))
+	paths := writeGradebooks(t, students, 4)
+
+	l, err := NewLoader(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.LoadFiles(paths)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != len(students) {
+		t.Fatalf("loaded %d students, want %d", len(got), len(students))
+	}
+	for i := range got {
+		if got[i].Emplid != students[i].Emplid {
+			t.Fatalf("student %d is %s, want %s", i, got[i].Emplid, students[i].Emplid)
+		}
+	}
+	if v := l.Validation(); v.RowsRead != len(students) || v.RowsUsed != len(students) || len(v.Quarantined) != 0 {
+		t.Errorf("read %d rows, used %d, quarantined %d; want all %d used", v.RowsRead, v.RowsUsed, len(v.Quarantined), len(students))
+	}
+}
+
+func TestLoadFilesDuplicatesAcrossFiles(t *testing.T) {
+	students := syntheticStudents(3)
+	dir := t.TempDir()
+	first, second := filepath.Join(dir, "a.xlsx"), filepath.Join(dir, "b.xlsx")
+	writeGradebook(t, first, students[:2])
+	writeGradebook(t, second, []Student{students[2], students[0]})
+
+	l, err := NewLoader(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := l.LoadFiles([]string{first, second})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 3 {
+		t.Errorf("loaded %d students, want 3", len(got))
+	}
+	v := l.Validation()
+	if len(v.Quarantined) != 1 || v.Quarantined[0].Emplid != students[0].Emplid || v.Quarantined[0].Row != 3 {
+		t.Fatalf("quarantined %+v, want the second copy of %s", v.Quarantined, students[0].Emplid)
+	}
+	var found bool
+	for _, issue := range v.Issues {
+		if strings.HasPrefix(issue.Message, "duplicate Emplid") {
+			found = true
+			if !strings.Contains(issue.Message, "a.xlsx") {
+				t.Errorf("duplicate reported against %q, want the first file", issue.Message)
+			}
+		}
+	}
+	if !found {
+		t.Error("no duplicate Emplid issue")
+	}
+}
+
+func TestLoadFilesMissingFile(t *testing.T) {
+	paths := writeGradebooks(t, syntheticStudents(2*rowBuffer), 2)
+	missing := filepath.Join(t.TempDir(), "missing.xlsx")
+	// The missing file comes first, so the loader returns while the other
+	// workbook is still waiting to hand over its rows.
+	l, err := NewLoader(LoadOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := l.LoadFiles(append([]string{missing}, paths...)); err == nil || !strings.Contains(err.Error(), "missing.xlsx") {
+		t.Errorf("got error %v, want one naming the missing file", err)
+	}
+}
+
+func TestSelectSheets(t *testing.T) {
+	sheets := []string{"Sheet1", "Notes", "Sem2"}
+	tests := []struct {
+		sel  SheetSelection
+		want []string
+		err  bool
+	}{
+		{SheetSelection{}, []string{"Sheet1"}, false},
+		{SheetSelection{Name: "Sem2"}, []string{"Sem2"}, false},
+		{SheetSelection{Name: "sem2"}, nil, true},
+		{SheetSelection{All: true}, sheets, false},
+	}
+	for _, tt := range tests {
+		got, err := selectSheets(sheets, tt.sel)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%+v: got %v, %v, want %v", tt.sel, got, err, tt.want)
+		}
+	}
+	if _, err := selectSheets(nil, SheetSelection{All: true}); err == nil {
+		t.Error("a workbook without sheets was accepted")
+	}
+}
+
+// TestLoadSheets reads a workbook with a gradebook on Sheet1 and Sem2 and
+// notes in between.
+func TestLoadSheets(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "marks.xlsx")
+	writeGradebook(t, path, syntheticStudents(3))
+	f, err := excelize.OpenFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.NewSheet("Notes")
+	f.SetSheetRow("Notes", "A1", &[]interface{}{"Moderated on 3 May"})
+	f.NewSheet("Sem2")
+	f.SetSheetRow("Sem2", "A1", &gradebookHeader)
+	for i, s := range syntheticStudents(5)[3:] {
+		f.SetSheetRow("Sem2", fmt.Sprintf("A%d", i+2), &[]interface{}{s.ClassNo, s.Emplid, s.CampusID, 0, 0, 0, 0, 0, 0, s.Total})
+	}
+	if err := f.Save(); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+
+	tests := []struct {
+		sel      SheetSelection
+		students int
+		skipped  []string
+		err      bool
+	}{
+		{SheetSelection{}, 3, nil, false},
+		{SheetSelection{Name: "Sem2"}, 2, nil, false},
+		{SheetSelection{All: true}, 5, []string{"Notes"}, false},
+		{SheetSelection{Name: "Notes"}, 0, nil, true},
+	}
+	for _, tt := range tests {
+		l, err := NewLoader(LoadOptions{Sheets: tt.sel})
+		if err != nil {
+			t.Fatal(err)
+		}
+		students, err := l.LoadFile(path)
+		if (err != nil) != tt.err {
+			t.Errorf("%+v: error %v, want error %v", tt.sel, err, tt.err)
+			continue
+		}
+		if len(students) != tt.students {
+			t.Errorf("%+v: %d students, want %d", tt.sel, len(students), tt.students)
+		}
+		var skipped []string
+		for _, sk := range l.Validation().SkippedSheets {
+			skipped = append(skipped, sk.Sheet)
+		}
+		if !reflect.DeepEqual(skipped, tt.skipped) {
+			t.Errorf("%+v: skipped %v, want %v", tt.sel, skipped, tt.skipped)
+		}
+	}
+}
+
+func BenchmarkLoadFiles(b *testing.B) {
+	paths := writeGradebooks(b, syntheticStudents(20000), 8)
+
+	b.Run("one by one", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l, err := NewLoader(LoadOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			for _, path := range paths {
+				if _, err := l.LoadFile(path); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}
+	})
+	b.Run("concurrent", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			l, err := NewLoader(LoadOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := l.LoadFiles(paths); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+// BenchmarkReadRows compares reading a whole sheet into memory, as the
+// loader did before, with the row iterator it uses now.
+func BenchmarkReadRows(b *testing.B) {
+	path := filepath.Join(b.TempDir(), "gradebook.xlsx")
+	writeGradebook(b, path, syntheticStudents(20000))
+
+	b.Run("baseline GetRows", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			f, err := excelize.OpenFile(path)
+			if err != nil {
+				b.Fatal(err)
+			}
+			if _, err := f.GetRows("Sheet1"); err != nil {
+				b.Fatal(err)
+			}
+			f.Close()
+		}
+	})
+	b.Run("row iterator", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			wb, err := OpenWorkbook(path, ReaderOptions{})
+			if err != nil {
+				b.Fatal(err)
+			}
+			it, err := wb.Rows("Sheet1")
+			if err != nil {
+				b.Fatal(err)
+			}
+			for it.Next() {
+			}
+			if err := it.Err(); err != nil {
+				b.Fatal(err)
+			}
+			it.Close()
+			wb.Close()
+		}
+	})
+}
@@ -0,0 +1,174 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+const FieldNormalizedTotal = "NormalizedTotal"
+
+// Normalization methods. Z-scores standardize each section to mean 0 and
+// SD 1; meansd maps each section linearly onto the course mean and SD;
+// percentile gives each student the course score at their percentile
+// within their section.
+const (
+	NormalizeZScore     = "zscore"
+	NormalizeMeanSD     = "meansd"
+	NormalizePercentile = "percentile"
+)
+
+var NormalizeMethods = []string{NormalizeZScore, NormalizeMeanSD, NormalizePercentile}
+
+// NormalizeOptions select the method and the component normalized across
+// sections (ClassNo), Total by default.
+type NormalizeOptions struct {
+	Method string
+	Of     string
+}
+
+// NormalizationReport shows how each section was adjusted. Shift is the
+// change in the section mean; Scale is the factor applied to distances
+// from the section mean, and is zero for percentile equating, which is not
+// linear. LargestChange is the biggest change to any one student.
+type NormalizationReport struct {
+	Method     string              `json:"method"`
+	Of         string              `json:"of"`
+	CourseMean float64             `json:"course_mean"`
+	CourseSD   float64             `json:"course_sd"`
+	Sections   []SectionAdjustment `json:"sections"`
+}
+
+type SectionAdjustment struct {
+	Section        string  `json:"section"`
+	Count          int     `json:"count"`
+	Mean           float64 `json:"mean"`
+	SD             float64 `json:"sd"`
+	NormalizedMean float64 `json:"normalized_mean"`
+	NormalizedSD   float64 `json:"normalized_sd"`
+	Shift          float64 `json:"shift"`
+	Scale          float64 `json:"scale,omitempty"`
+	LargestChange  float64 `json:"largest_change"`
+}
+
+// normalize sets NormalizedTotal on every student with a score to
+// normalize. A section whose scores are all equal gets the course mean
+// (zero as a z-score).
+func normalize(students []Student, opts NormalizeOptions) (NormalizationReport, error) {
+	if opts.Method == "" {
+		opts.Method = NormalizeZScore
+	}
+	if !contains(NormalizeMethods, opts.Method) {
+		return NormalizationReport{}, fmt.Errorf("unknown normalization %q (want %s)", opts.Method, strings.Join(NormalizeMethods, ", "))
+	}
+	if opts.Of == "" {
+		opts.Of = FieldTotal
+	}
+	if len(students) > 0 && !contains(statComponents(students), opts.Of) {
+		return NormalizationReport{}, fmt.Errorf("cannot normalize unknown component %q", opts.Of)
+	}
+
+	// Students without the score are left out and keep no NormalizedTotal.
+	scores := make([]float64, len(students))
+	var course []float64
+	bySection := make(map[string][]int)
+	for i, s := range students {
+		v, ok := s.Component(opts.Of)
+		students[i].NormalizedTotal = nil
+		if !ok {
+			continue
+		}
+		scores[i] = v
+		course = append(course, v)
+		bySection[s.ClassNo] = append(bySection[s.ClassNo], i)
+	}
+	courseMean, courseSD := meanSD(course)
+	sortedCourse := append([]float64(nil), course...)
+	sort.Float64s(sortedCourse)
+	report := NormalizationReport{
+		Method: opts.Method, Of: opts.Of, CourseMean: courseMean, CourseSD: courseSD, Sections: []SectionAdjustment{},
+	}
+
+	var sections []string
+	for name := range bySection {
+		sections = append(sections, name)
+	}
+	sort.Strings(sections)
+	for _, name := range sections {
+		members := bySection[name]
+		raw := make([]float64, len(members))
+		for k, i := range members {
+			raw[k] = scores[i]
+		}
+		mean, sd := meanSD(raw)
+		sorted := append([]float64(nil), raw...)
+		sort.Float64s(sorted)
+
+		adj := SectionAdjustment{Section: name, Count: len(members), Mean: mean, SD: sd}
+		normalized := make([]float64, len(members))
+		for k, x := range raw {
+			var v float64
+			switch opts.Method {
+			case NormalizeZScore:
+				if sd > 0 {
+					v = (x - mean) / sd
+				}
+			case NormalizeMeanSD:
+				v = courseMean
+				if sd > 0 {
+					v += (x - mean) / sd * courseSD
+				}
+			case NormalizePercentile:
+				v = quantile(sortedCourse, midPercentile(sorted, x))
+			}
+			normalized[k] = v
+			students[members[k]].NormalizedTotal = &normalized[k]
+			if opts.Method != NormalizeZScore {
+				adj.LargestChange = math.Max(adj.LargestChange, math.Abs(v-x))
+			}
+		}
+		adj.NormalizedMean, adj.NormalizedSD = meanSD(normalized)
+		adj.Shift = adj.NormalizedMean - mean
+		if sd > 0 {
+			switch opts.Method {
+			case NormalizeZScore:
+				adj.Scale = 1 / sd
+			case NormalizeMeanSD:
+				adj.Scale = courseSD / sd
+			}
+		}
+		report.Sections = append(report.Sections, adj)
+	}
+	return report, nil
+}
+
+// midPercentile is the fraction of sorted below x, counting ties as half,
+// so that the lowest and highest of n students map to 1/2n and 1-1/2n
+// rather than the extremes of the course.
+func midPercentile(sorted []float64, x float64) float64 {
+	below := sort.SearchFloat64s(sorted, x)
+	equal := sort.Search(len(sorted), func(i int) bool { return sorted[i] > x }) - below
+	return (float64(below) + float64(equal)/2) / float64(len(sorted))
+}
+
+func normalizationTable(r *NormalizationReport) Table {
+	t := Table{
+		Name: "normalization",
+		Title: fmt.Sprintf("Normalization: %s of %s across sections (course mean %.2f, SD %.2f)",
+			r.Method, r.Of, r.CourseMean, r.CourseSD),
+		Header: []string{"Section", "N", "Mean", "SD", "Normalized Mean", "Normalized SD", "Shift", "Scale", "Largest Change"},
+	}
+	for _, s := range r.Sections {
+		// Z-scores are on another scale, so a change per student means nothing.
+		scale, largest := interface{}(""), interface{}("")
+		if s.Scale != 0 {
+			scale = s.Scale
+		}
+		if r.Method != NormalizeZScore {
+			largest = s.LargestChange
+		}
+		t.Rows = append(t.Rows, []interface{}{s.Section, s.Count, s.Mean, s.SD, s.NormalizedMean, s.NormalizedSD, s.Shift, scale, largest})
+	}
+	return t
+}
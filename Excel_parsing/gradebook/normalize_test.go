@@ -0,0 +1,146 @@
+package gradebook
+
+import (
+	"math"
+	"strings"
+	"testing"
+)
+
+// normalizeStudents are two sections of three, each 10 marks apart, the
+// second 30 marks ahead in Total; 7 in the second section has neither
+// score and is left out.
+func normalizeStudents() []Student {
+	return []Student{
+		{Emplid: "1", ClassNo: "1", MidSem: 10, Total: 40},
+		{Emplid: "2", ClassNo: "1", MidSem: 20, Total: 50},
+		{Emplid: "3", ClassNo: "1", MidSem: 30, Total: 60},
+		{Emplid: "4", ClassNo: "2", MidSem: 10, Total: 70},
+		{Emplid: "5", ClassNo: "2", MidSem: 20, Total: 80},
+		{Emplid: "6", ClassNo: "2", MidSem: 30, Total: 90},
+		{Emplid: "7", ClassNo: "2", Special: map[string]SpecialMark{
+			FieldMidSem: {Marker: "AB", State: MarkAbsent, Policy: PolicyExclude},
+			FieldTotal:  {Marker: "I", State: MarkIncomplete, Policy: PolicyExclude},
+		}},
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	// Each section has a population SD of sqrt(200/3), so its ends are
+	// sqrt(1.5) SD from its mean; the course SD is sqrt(1750/6).
+	z := math.Sqrt(1.5)
+	spread := z * math.Sqrt(1750.0/6)
+	tests := []struct {
+		opts   NormalizeOptions
+		want   []float64 // by Emplid, 1 to 6
+		shifts []float64
+		scale  float64
+	}{
+		{
+			NormalizeOptions{},
+			[]float64{-z, 0, z, -z, 0, z},
+			[]float64{-50, -80},
+			1 / math.Sqrt(200.0/3),
+		},
+		{
+			NormalizeOptions{Method: NormalizeMeanSD},
+			[]float64{65 - spread, 65, 65 + spread, 65 - spread, 65, 65 + spread},
+			[]float64{15, -15},
+			math.Sqrt(1750.0/6) / math.Sqrt(200.0/3),
+		},
+		{
+			// Each end is at the 1/6 or 5/6 point of the course.
+			NormalizeOptions{Method: NormalizePercentile},
+			[]float64{40 + 50.0/6, 65, 80 + 10.0/6, 40 + 50.0/6, 65, 80 + 10.0/6},
+			[]float64{15, -15},
+			0,
+		},
+		{
+			// MidSem is the same in both sections, so nothing moves.
+			NormalizeOptions{Method: NormalizeMeanSD, Of: FieldMidSem},
+			[]float64{10, 20, 30, 10, 20, 30},
+			[]float64{0, 0},
+			1,
+		},
+	}
+	for _, tt := range tests {
+		students := normalizeStudents()
+		report, err := normalize(students, tt.opts)
+		if err != nil {
+			t.Errorf("%+v: %v", tt.opts, err)
+			continue
+		}
+		for i, want := range tt.want {
+			got := students[i].NormalizedTotal
+			if got == nil {
+				t.Errorf("%+v: student %s not normalized", tt.opts, students[i].Emplid)
+			} else if math.Abs(*got-want) > 1e-9 {
+				t.Errorf("%+v: student %s normalized to %g, want %g", tt.opts, students[i].Emplid, *got, want)
+			}
+		}
+		if students[6].NormalizedTotal != nil {
+			t.Errorf("%+v: the student without a score was normalized to %g", tt.opts, *students[6].NormalizedTotal)
+		}
+		if len(report.Sections) != len(tt.shifts) {
+			t.Errorf("%+v: %d sections, want %d", tt.opts, len(report.Sections), len(tt.shifts))
+			continue
+		}
+		for i, s := range report.Sections {
+			if math.Abs(s.Shift-tt.shifts[i]) > 1e-9 || math.Abs(s.Scale-tt.scale) > 1e-9 {
+				t.Errorf("%+v: section %s shifted %g and scaled %g, want %g and %g", tt.opts, s.Section, s.Shift, s.Scale, tt.shifts[i], tt.scale)
+			}
+		}
+	}
+}
+
+func TestNormalizeEqualSection(t *testing.T) {
+	students := []Student{
+		{ClassNo: "1", Total: 40}, {ClassNo: "1", Total: 80},
+		{ClassNo: "2", Total: 55}, {ClassNo: "2", Total: 55},
+	}
+	for method, want := range map[string]float64{NormalizeZScore: 0, NormalizeMeanSD: 57.5} {
+		report, err := normalize(students, NormalizeOptions{Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := *students[2].NormalizedTotal; got != want || report.Sections[1].Scale != 0 {
+			t.Errorf("%s: an all-equal section normalized to %g with scale %g, want %g", method, got, report.Sections[1].Scale, want)
+		}
+	}
+}
+
+func TestNormalizeErrors(t *testing.T) {
+	for _, opts := range []NormalizeOptions{
+		{Method: "rank"},
+		{Of: "Attendance"},
+	} {
+		if _, err := normalize(normalizeStudents(), opts); err == nil {
+			t.Errorf("%+v accepted", opts)
+		}
+	}
+}
+
+func TestMidPercentile(t *testing.T) {
+	sorted := []float64{1, 2, 2, 3}
+	for x, want := range map[float64]float64{0: 0, 1: 0.125, 2: 0.5, 2.5: 0.75, 3: 0.875, 5: 1} {
+		if got := midPercentile(sorted, x); got != want {
+			t.Errorf("midPercentile(%g) = %g, want %g", x, got, want)
+		}
+	}
+}
+
+func TestNormalizationTable(t *testing.T) {
+	for _, method := range NormalizeMethods {
+		report, err := normalize(normalizeStudents(), NormalizeOptions{Method: method})
+		if err != nil {
+			t.Fatal(err)
+		}
+		table := normalizationTable(&report)
+		if len(table.Rows) != 2 || !strings.Contains(table.Title, method+" of Total") {
+			t.Errorf("%s: table %q with %d rows", method, table.Title, len(table.Rows))
+		}
+		// Z-scores have no per-student change to show.
+		if largest := table.Rows[0][8]; (largest == "") != (method == NormalizeZScore) {
+			t.Errorf("%s: largest change %v", method, largest)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package gradebook
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// pdfPage collects the drawing operators of one A4 page. Coordinates are
+// in points from the bottom left corner. Text uses the standard Helvetica
+// fonts, so nothing needs embedding; characters outside Latin-1 are
+// replaced by '?'.
+type pdfPage struct {
+	b strings.Builder
+}
+
+const (
+	pdfWidth  = 595
+	pdfHeight = 842
+)
+
+func (p *pdfPage) text(x, y, size float64, bold bool, s string) {
+	font := "F1"
+	if bold {
+		font = "F2"
+	}
+	fmt.Fprintf(&p.b, "BT /%s %g Tf %.2f %.2f Td (%s) Tj ET\n", font, size, x, y, pdfString(s))
+}
+
+// textRight draws s ending at x, measuring it with approximate Helvetica
+// widths.
+func (p *pdfPage) textRight(x, y, size float64, bold bool, s string) {
+	p.text(x-pdfTextWidth(s, size), y, size, bold, s)
+}
+
+func (p *pdfPage) fill(r, g, b float64) {
+	fmt.Fprintf(&p.b, "%.3f %.3f %.3f rg\n", r, g, b)
+}
+
+func (p *pdfPage) stroke(r, g, b, width float64) {
+	fmt.Fprintf(&p.b, "%.3f %.3f %.3f RG %.2f w\n", r, g, b, width)
+}
+
+func (p *pdfPage) rect(x, y, w, h float64) {
+	fmt.Fprintf(&p.b, "%.2f %.2f %.2f %.2f re f\n", x, y, w, h)
+}
+
+func (p *pdfPage) line(x1, y1, x2, y2 float64) {
+	fmt.Fprintf(&p.b, "%.2f %.2f m %.2f %.2f l S\n", x1, y1, x2, y2)
+}
+
+// pdfString escapes s for a literal string in the WinAnsi encoding.
+func pdfString(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r == '–' || r == '—':
+			b.WriteByte('-')
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// pdfTextWidth estimates the width of s in Helvetica: digits and most
+// letters are close to 0.556 em, narrow punctuation much less.
+func pdfTextWidth(s string, size float64) float64 {
+	var em float64
+	for _, r := range s {
+		switch {
+		case strings.ContainsRune(".,:;'!|il ", r):
+			em += 0.278
+		case r == '-' || r == '(' || r == ')':
+			em += 0.333
+		case r >= 'A' && r <= 'Z':
+			em += 0.667
+		default:
+			em += 0.556
+		}
+	}
+	return em * size
+}
+
+// writePDF writes pages as a PDF document.
+func writePDF(w io.Writer, title string, pages []*pdfPage) error {
+	bw := bufio.NewWriter(w)
+	var offsets []int
+	written := 0
+	emit := func(format string, args ...interface{}) {
+		n, _ := fmt.Fprintf(bw, format, args...)
+		written += n
+	}
+	object := func(body string) {
+		offsets = append(offsets, written)
+		emit("%d 0 obj\n%s\nendobj\n", len(offsets), body)
+	}
+
+	// Objects 1-4 are the catalog, page tree, fonts and info; each page
+	// is then followed by its content stream.
+	emit("%%PDF-1.4\n%%\xe2\xe3\xcf\xd3\n")
+	kids := make([]string, len(pages))
+	for i := range pages {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>")
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(pages)))
+	object("<< /F1 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>" +
+		" /F2 << /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >> >>")
+	object(fmt.Sprintf("<< /Title (%s) /Producer (gradebook) >>", pdfString(title)))
+	for i, page := range pages {
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /Font 3 0 R >> /Contents %d 0 R >>",
+			pdfWidth, pdfHeight, 6+2*i))
+		content := page.b.String()
+		object(fmt.Sprintf("<< /Length %d >>\nstream\n%sendstream", len(content), content))
+	}
+
+	xref := written
+	emit("xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		emit("%010d 00000 n \n", off)
+	}
+	emit("trailer\n<< /Size %d /Root 1 0 R /Info 4 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+	return bw.Flush()
+}
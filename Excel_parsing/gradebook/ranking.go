@@ -0,0 +1,272 @@
+package gradebook
+
+import (
+	"container/heap"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Ranking methods. Competition ranking leaves gaps after ties (1,2,2,4);
+// dense ranking does not (1,2,2,3).
+const (
+	RankCompetition = "competition"
+	RankDense       = "dense"
+)
+
+var RankMethods = []string{RankCompetition, RankDense}
+
+// RankOptions control how students are ordered and numbered. By is a
+// component name or an arithmetic expression over components. TieBreakers
+// are applied in order when scores are equal: components compare higher
+// first, Emplid and CampusID compare in ascending order. Students still
+// equal after every tie-breaker share a rank.
+type RankOptions struct {
+	By          string
+	Method      string
+	TieBreakers []string
+	Top         int
+
+	score scoreExpr
+}
+
+// RankedStudent is a student with their place in a ranking. Percentile is
+// the percentage of the group ranked below, counting ties as half.
+// Unranked students have no score to rank by, such as a mark left out by
+// the exclude policy or a By expression that divides by zero; they come
+// after everyone ranked, with no rank or percentile, and do not count
+// towards anyone else's.
+type RankedStudent struct {
+	Rank       int     `json:"Rank"`
+	Percentile float64 `json:"Percentile"`
+	Score      float64 `json:"Score"`
+	Unranked   bool    `json:"Unranked,omitempty"`
+	Student
+}
+
+func DefaultRankOptions() RankOptions {
+	return RankOptions{By: FieldTotal, Method: RankCompetition, Top: 3}
+}
+
+// compile checks the options against the components present on students.
+func (o *RankOptions) compile(students []Student) error {
+	if o.By == "" {
+		o.By = FieldTotal
+	}
+	if o.Method == "" {
+		o.Method = RankCompetition
+	}
+	if !contains(RankMethods, o.Method) {
+		return fmt.Errorf("unknown rank method %q (want one of %s)", o.Method, strings.Join(RankMethods, ", "))
+	}
+	known := statComponents(students)
+	score, err := compileScoreExpr(o.By, known)
+	if err != nil {
+		return err
+	}
+	o.score = score
+	for i, tb := range o.TieBreakers {
+		if strings.EqualFold(tb, FieldEmplid) || strings.EqualFold(tb, FieldCampusID) {
+			continue
+		}
+		found := false
+		for _, name := range known {
+			if strings.EqualFold(name, tb) {
+				o.TieBreakers[i] = name
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("unknown tie-breaker %q", tb)
+		}
+	}
+	return nil
+}
+
+// scoreOf returns the score s is ranked by, or false when s lacks a
+// component it needs.
+func (o RankOptions) scoreOf(s Student) (float64, bool) {
+	if o.score == nil {
+		return s.Component(FieldTotal)
+	}
+	if scoreMissing(o.score, s) {
+		return 0, false
+	}
+	return o.score.eval(s), true
+}
+
+// compare orders a before b when it returns a negative number. Only the
+// score and the configured tie-breakers take part; equal students tie.
+func (o RankOptions) compare(a, b RankedStudent) int {
+	if a.Score != b.Score {
+		if a.Score > b.Score {
+			return -1
+		}
+		return 1
+	}
+	for _, tb := range o.TieBreakers {
+		switch {
+		case strings.EqualFold(tb, FieldEmplid):
+			if c := strings.Compare(a.Emplid, b.Emplid); c != 0 {
+				return c
+			}
+		case strings.EqualFold(tb, FieldCampusID):
+			if c := strings.Compare(a.CampusID, b.CampusID); c != 0 {
+				return c
+			}
+		default:
+			av, _ := a.Component(tb)
+			bv, _ := b.Component(tb)
+			if av != bv {
+				if av > bv {
+					return -1
+				}
+				return 1
+			}
+		}
+	}
+	return 0
+}
+
+// rankStudents returns a ranked copy of students; the input is not
+// reordered. Students who tie keep a deterministic order by Emplid, as do
+// the unranked students at the end.
+func rankStudents(students []Student, opts RankOptions) []RankedStudent {
+	ranked := make([]RankedStudent, 0, len(students))
+	var unranked []RankedStudent
+	for _, s := range students {
+		score, ok := opts.scoreOf(s)
+		if !ok {
+			unranked = append(unranked, RankedStudent{Student: s, Unranked: true})
+			continue
+		}
+		ranked = append(ranked, RankedStudent{Student: s, Score: score})
+	}
+	sortRanked(ranked, opts)
+	assignRanks(ranked, opts, len(ranked))
+	sort.SliceStable(unranked, func(i, j int) bool { return unranked[i].Emplid < unranked[j].Emplid })
+	return append(ranked, unranked...)
+}
+
+// rankedCount is the number of students in ranked who have a rank.
+func rankedCount(ranked []RankedStudent) int {
+	n := 0
+	for _, r := range ranked {
+		if !r.Unranked {
+			n++
+		}
+	}
+	return n
+}
+
+func sortRanked(ranked []RankedStudent, opts RankOptions) {
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if c := opts.compare(ranked[i], ranked[j]); c != 0 {
+			return c < 0
+		}
+		return ranked[i].Emplid < ranked[j].Emplid
+	})
+}
+
+// assignRanks numbers sorted students who are the best of a group of n.
+func assignRanks(ranked []RankedStudent, opts RankOptions, n int) {
+	for i := 0; i < len(ranked); {
+		j := i
+		for j < len(ranked) && opts.compare(ranked[i], ranked[j]) == 0 {
+			j++
+		}
+		rank := i + 1
+		if opts.Method == RankDense {
+			rank = 1
+			if i > 0 {
+				rank = ranked[i-1].Rank + 1
+			}
+		}
+		below := n - j
+		percentile := (float64(below) + 0.5*float64(j-i)) / float64(n) * 100
+		for k := i; k < j; k++ {
+			ranked[k].Rank = rank
+			ranked[k].Percentile = percentile
+		}
+		i = j
+	}
+}
+
+// topStudents ranks the students placed within the first opts.Top places.
+// With competition ranking a bounded heap finds who is in the last place,
+// so only those students are sorted: O(n log Top) instead of sorting
+// everyone. Dense ranks can reach any depth and fall back to a full sort.
+func topStudents(students []Student, opts RankOptions) []RankedStudent {
+	if opts.Top <= 0 || opts.Top >= len(students) || opts.Method == RankDense {
+		return topRanked(rankStudents(students, opts), opts.Top)
+	}
+	h := &rankHeap{opts: opts}
+	n := 0
+	for _, s := range students {
+		score, ok := opts.scoreOf(s)
+		if !ok {
+			continue
+		}
+		n++
+		r := RankedStudent{Student: s, Score: score}
+		if h.Len() < opts.Top {
+			heap.Push(h, r)
+		} else if opts.compare(r, h.items[0]) < 0 {
+			h.items[0] = r
+			heap.Fix(h, 0)
+		}
+	}
+
+	if h.Len() == 0 {
+		return []RankedStudent{}
+	}
+
+	// Everyone at least as good as the student in the last place is
+	// within it, including ties the heap had no room for.
+	last := h.items[0]
+	var top []RankedStudent
+	for _, s := range students {
+		score, ok := opts.scoreOf(s)
+		if !ok {
+			continue
+		}
+		if r := (RankedStudent{Student: s, Score: score}); opts.compare(r, last) <= 0 {
+			top = append(top, r)
+		}
+	}
+	sortRanked(top, opts)
+	assignRanks(top, opts, n)
+	return top
+}
+
+// rankHeap keeps the worst-placed student at the root.
+type rankHeap struct {
+	items []RankedStudent
+	opts  RankOptions
+}
+
+func (h *rankHeap) Len() int           { return len(h.items) }
+func (h *rankHeap) Less(i, j int) bool { return h.opts.compare(h.items[i], h.items[j]) > 0 }
+func (h *rankHeap) Swap(i, j int)      { h.items[i], h.items[j] = h.items[j], h.items[i] }
+func (h *rankHeap) Push(x interface{}) { h.items = append(h.items, x.(RankedStudent)) }
+func (h *rankHeap) Pop() interface{} {
+	last := h.items[len(h.items)-1]
+	h.items = h.items[:len(h.items)-1]
+	return last
+}
+
+// topRanked keeps everyone ranked within the first top places, so a tie for
+// the last place does not silently drop a student. Unranked students are
+// only kept when there is no limit.
+func topRanked(ranked []RankedStudent, top int) []RankedStudent {
+	if top <= 0 {
+		return ranked
+	}
+	for i, r := range ranked {
+		if r.Unranked || r.Rank > top {
+			return ranked[:i]
+		}
+	}
+	return ranked
+}
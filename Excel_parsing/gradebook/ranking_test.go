@@ -0,0 +1,209 @@
+package gradebook
+
+import (
+	"fmt"
+	"math/rand"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// syntheticStudents returns n students with random marks, reproducibly.
+func syntheticStudents(n int) []Student {
+	branches := []string{"A1", "A3", "A4", "A7", "A8", "AA", "B3", "B5"}
+	rng := rand.New(rand.NewSource(1))
+	students := make([]Student, n)
+	for i := range students {
+		quiz, mid := rng.Float64()*30, rng.Float64()*75
+		lab, weekly := rng.Float64()*30, rng.Float64()*30
+		pre := quiz + mid + lab + weekly
+		compre := rng.Float64() * 100
+		students[i] = Student{
+			ClassNo:  fmt.Sprint(1 + rng.Intn(6)),
+			Emplid:   fmt.Sprint(100000 + i),
+			CampusID: fmt.Sprintf("%d%sPS%04dP", 2021+rng.Intn(4), branches[rng.Intn(len(branches))], i%10000),
+			Quiz:     quiz, MidSem: mid, LabTest: lab, WeeklyLabs: weekly,
+			PreCompre: pre, Compre: compre, Total: pre + compre,
+		}
+	}
+	return students
+}
+
+func totalsOf(students ...float64) []Student {
+	out := make([]Student, len(students))
+	for i, t := range students {
+		out[i] = Student{Emplid: fmt.Sprintf("E%02d", i), Total: t, Compre: float64(i % 3)}
+	}
+	return out
+}
+
+func TestRankStudents(t *testing.T) {
+	tests := []struct {
+		name   string
+		opts   RankOptions
+		totals []float64
+		ids    []string
+		ranks  []int
+	}{
+		{"competition", RankOptions{}, []float64{50, 90, 70, 90}, []string{"E01", "E03", "E02", "E00"}, []int{1, 1, 3, 4}},
+		{"dense", RankOptions{Method: RankDense}, []float64{50, 90, 70, 90}, []string{"E01", "E03", "E02", "E00"}, []int{1, 1, 2, 3}},
+		// Compre is 1 for E01 and 0 for E03, so E01 wins the tie.
+		{"tie-breaker", RankOptions{TieBreakers: []string{FieldCompre}}, []float64{50, 90, 70, 90}, []string{"E01", "E03", "E02", "E00"}, []int{1, 2, 3, 4}},
+		{"emplid tie-breaker", RankOptions{TieBreakers: []string{"emplid"}}, []float64{90, 90}, []string{"E00", "E01"}, []int{1, 2}},
+		// Scores are 50, 80 and 50.
+		{"expression", RankOptions{By: "Total - 10 * Compre"}, []float64{50, 90, 70}, []string{"E01", "E00", "E02"}, []int{1, 2, 2}},
+		// E00 has no Compre to divide by and is left unranked.
+		{"division by zero", RankOptions{By: "Total / Compre"}, []float64{50, 90, 70}, []string{"E01", "E02", "E00"}, []int{1, 2, 0}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			students := totalsOf(tt.totals...)
+			opts := tt.opts
+			if err := opts.compile(students); err != nil {
+				t.Fatal(err)
+			}
+			ranked := rankStudents(students, opts)
+			var ids []string
+			var ranks []int
+			for _, r := range ranked {
+				ids = append(ids, r.Emplid)
+				ranks = append(ranks, r.Rank)
+			}
+			if !reflect.DeepEqual(ids, tt.ids) || !reflect.DeepEqual(ranks, tt.ranks) {
+				t.Errorf("got %v ranked %v, want %v ranked %v", ids, ranks, tt.ids, tt.ranks)
+			}
+		})
+	}
+}
+
+func TestRankOptionsCompile(t *testing.T) {
+	students := totalsOf(10, 20)
+	tests := []struct {
+		name string
+		opts RankOptions
+		err  bool
+	}{
+		{"defaults", RankOptions{}, false},
+		{"expression", RankOptions{By: "midsem + compre"}, false},
+		{"unknown method", RankOptions{Method: "olympic"}, true},
+		{"unknown component", RankOptions{By: "Attendance"}, true},
+		{"unknown tie-breaker", RankOptions{TieBreakers: []string{"Age"}}, true},
+		{"campus ID tie-breaker", RankOptions{TieBreakers: []string{"campusid"}}, false},
+	}
+	for _, tt := range tests {
+		opts := tt.opts
+		if err := opts.compile(students); (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+		}
+	}
+
+	opts := RankOptions{TieBreakers: []string{"compre"}}
+	if err := opts.compile(students); err != nil || opts.TieBreakers[0] != FieldCompre || opts.By != FieldTotal {
+		t.Errorf("compiled to %+v, %v; want By Total and tie-breaker Compre", opts, err)
+	}
+}
+
+func TestPercentileCountsTiesAsHalf(t *testing.T) {
+	ranked := rankStudents(totalsOf(10, 20, 20, 30), RankOptions{Method: RankCompetition})
+	want := []float64{87.5, 50, 50, 12.5}
+	for i, r := range ranked {
+		if r.Percentile != want[i] {
+			t.Errorf("%s: percentile %g, want %g", r.Emplid, r.Percentile, want[i])
+		}
+	}
+}
+
+// TestTopStudentsMatchesFullRanking checks the bounded heap against
+// ranking everyone and cutting, above all when students tie for the last
+// place.
+func TestTopStudentsMatchesFullRanking(t *testing.T) {
+	tests := []struct {
+		name   string
+		totals []float64
+		top    int
+		method string
+		want   int
+	}{
+		{"no ties", []float64{10, 50, 40, 30, 20}, 2, RankCompetition, 2},
+		{"tie for last place", []float64{10, 50, 40, 40, 20}, 2, RankCompetition, 3},
+		{"tie for first place", []float64{50, 50, 50, 40, 20}, 1, RankCompetition, 3},
+		{"tie beyond the heap", []float64{50, 40, 40, 40, 40, 10}, 3, RankCompetition, 5},
+		{"everyone tied", []float64{5, 5, 5, 5}, 2, RankCompetition, 4},
+		{"top of everyone", []float64{10, 20, 30}, 3, RankCompetition, 3},
+		{"top beyond everyone", []float64{10, 20, 30}, 10, RankCompetition, 3},
+		{"dense", []float64{50, 50, 40, 30, 20}, 2, RankDense, 3},
+		{"no limit", []float64{10, 20, 30}, 0, RankCompetition, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			students := totalsOf(tt.totals...)
+			opts := RankOptions{Method: tt.method, Top: tt.top}
+			if err := opts.compile(students); err != nil {
+				t.Fatal(err)
+			}
+			got := topStudents(students, opts)
+			want := topRanked(rankStudents(students, opts), tt.top)
+			if len(got) != tt.want {
+				t.Errorf("%d students in the top %d, want %d", len(got), tt.top, tt.want)
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("heap gave %v, full ranking %v", got, want)
+			}
+		})
+	}
+}
+
+func TestTopStudentsLeavesInputAlone(t *testing.T) {
+	students := totalsOf(10, 50, 40, 30)
+	before := append([]Student(nil), students...)
+	opts := RankOptions{Top: 2}
+	if err := opts.compile(students); err != nil {
+		t.Fatal(err)
+	}
+	topStudents(students, opts)
+	if !reflect.DeepEqual(students, before) {
+		t.Errorf("topStudents reordered its input: %v", students)
+	}
+}
+
+// baselineTopStudents is how the top list was found before ranking
+// options: sort the caller's slice in place by Total and keep the first
+// few.
+func baselineTopStudents(students []Student, top int) []Student {
+	sort.Slice(students, func(i, j int) bool {
+		return students[i].Total > students[j].Total
+	})
+	if len(students) > top {
+		return students[:top]
+	}
+	return students
+}
+
+func BenchmarkTopStudents(b *testing.B) {
+	const top = 10
+	students := syntheticStudents(50000)
+	opts := DefaultRankOptions()
+	opts.Top = top
+	if err := opts.compile(students); err != nil {
+		b.Fatal(err)
+	}
+
+	b.Run("baseline sort", func(b *testing.B) {
+		b.ReportAllocs()
+		work := make([]Student, len(students))
+		for i := 0; i < b.N; i++ {
+			// The baseline sorted the shared slice, so give it unsorted
+			// students every time.
+			b.StopTimer()
+			copy(work, students)
+			b.StartTimer()
+			baselineTopStudents(work, top)
+		}
+	})
+	b.Run("bounded heap", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			topStudents(students, opts)
+		}
+	})
+}
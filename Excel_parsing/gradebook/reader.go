@@ -0,0 +1,219 @@
+package gradebook
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Workbook is a tabular source of gradebook sheets. Every input format is
+// read through this interface so the pipeline never touches a specific
+// library directly.
+type Workbook interface {
+	Sheets() []string
+	Rows(sheet string) (RowIterator, error)
+	Close() error
+}
+
+// RowIterator walks the rows of one sheet in order without holding the
+// whole sheet in memory. Row numbering is dense: a missing row comes back
+// as an empty one.
+type RowIterator interface {
+	Next() bool
+	Row() []string
+	Err() error
+	Close() error
+}
+
+// sliceRows iterates over rows already in memory.
+type sliceRows struct {
+	rows [][]string
+	i    int
+}
+
+func (r *sliceRows) Next() bool {
+	if r.i >= len(r.rows) {
+		return false
+	}
+	r.i++
+	return true
+}
+
+func (r *sliceRows) Row() []string { return r.rows[r.i-1] }
+func (r *sliceRows) Err() error    { return nil }
+func (r *sliceRows) Close() error  { return nil }
+
+// ReadRows collects every row of a sheet.
+func ReadRows(wb Workbook, sheet string) ([][]string, error) {
+	it, err := wb.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	defer it.Close()
+	var rows [][]string
+	for it.Next() {
+		rows = append(rows, it.Row())
+	}
+	return rows, it.Err()
+}
+
+// ReaderOptions tune how input files are opened. An empty Format means the
+// format is detected from the extension or, failing that, the content.
+type ReaderOptions struct {
+	Format    string
+	Delimiter rune
+}
+
+type openFunc func(r io.Reader, name string, opts ReaderOptions) (Workbook, error)
+
+var readers = map[string]openFunc{
+	"xlsx": openXLSX,
+	"csv":  openCSV,
+	"tsv":  openCSV,
+	"ods":  openODS,
+}
+
+var formatByExt = map[string]string{
+	".xlsx": "xlsx",
+	".xlsm": "xlsx",
+	".xltx": "xlsx",
+	".csv":  "csv",
+	".tsv":  "tsv",
+	".tab":  "tsv",
+	".ods":  "ods",
+}
+
+// An OpenDocument file starts with an uncompressed "mimetype" entry, so
+// its local file header is followed directly by the name and the type.
+const odsMimeType = "application/vnd.oasis.opendocument.spreadsheet"
+
+// ReaderFormats lists the input formats that can be read.
+func ReaderFormats() []string {
+	var formats []string
+	for name := range readers {
+		formats = append(formats, name)
+	}
+	sort.Strings(formats)
+	return formats
+}
+
+// OpenWorkbook opens the file at path. The file stays open until the
+// workbook is closed so that delimited text can be streamed.
+func OpenWorkbook(path string, opts ReaderOptions) (Workbook, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	wb, err := OpenWorkbookReader(f, filepath.Base(path), opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	return fileWorkbook{wb, f}, nil
+}
+
+type fileWorkbook struct {
+	Workbook
+	f *os.File
+}
+
+func (w fileWorkbook) Close() error {
+	err := w.Workbook.Close()
+	if cerr := w.f.Close(); err == nil {
+		err = cerr
+	}
+	return err
+}
+
+// OpenWorkbookReader reads a workbook from r. The name is only used to
+// detect the format when opts.Format is empty.
+func OpenWorkbookReader(r io.Reader, name string, opts ReaderOptions) (Workbook, error) {
+	br := bufio.NewReader(r)
+	format := opts.Format
+	if format == "" {
+		head, _ := br.Peek(512)
+		format = detectFormat(name, head)
+	}
+	open, ok := readers[format]
+	if !ok {
+		return nil, fmt.Errorf("unsupported input format %q (want one of %s)",
+			format, strings.Join(ReaderFormats(), ", "))
+	}
+	if format == "tsv" && opts.Delimiter == 0 {
+		opts.Delimiter = '\t'
+	}
+	return open(br, name, opts)
+}
+
+// detectFormat trusts a known extension and otherwise sniffs the first
+// bytes: zip containers are ODS when they open with the OpenDocument
+// mimetype entry and XLSX otherwise; anything else is delimited text.
+func detectFormat(name string, head []byte) string {
+	if format, ok := formatByExt[strings.ToLower(filepath.Ext(name))]; ok {
+		return format
+	}
+	if !bytes.HasPrefix(head, []byte("PK\x03\x04")) {
+		return "csv"
+	}
+	if bytes.Contains(head, []byte("mimetype"+odsMimeType)) {
+		return "ods"
+	}
+	return "xlsx"
+}
+
+type xlsxWorkbook struct {
+	f *excelize.File
+}
+
+func openXLSX(r io.Reader, _ string, _ ReaderOptions) (Workbook, error) {
+	f, err := excelize.OpenReader(r)
+	if err != nil {
+		return nil, err
+	}
+	return xlsxWorkbook{f: f}, nil
+}
+
+func (w xlsxWorkbook) Sheets() []string { return w.f.GetSheetList() }
+
+func (w xlsxWorkbook) Rows(sheet string) (RowIterator, error) {
+	rows, err := w.f.Rows(sheet)
+	if err != nil {
+		return nil, err
+	}
+	return &xlsxRows{rows: rows}, nil
+}
+
+func (w xlsxWorkbook) Close() error { return w.f.Close() }
+
+// xlsxRows streams a worksheet through excelize's row iterator.
+type xlsxRows struct {
+	rows *excelize.Rows
+	row  []string
+	err  error
+}
+
+func (r *xlsxRows) Next() bool {
+	if r.err != nil || !r.rows.Next() {
+		return false
+	}
+	r.row, r.err = r.rows.Columns()
+	return r.err == nil
+}
+
+func (r *xlsxRows) Row() []string { return r.row }
+
+func (r *xlsxRows) Err() error {
+	if r.err != nil {
+		return r.err
+	}
+	return r.rows.Error()
+}
+
+func (r *xlsxRows) Close() error { return r.rows.Close() }
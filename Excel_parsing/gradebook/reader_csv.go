@@ -0,0 +1,171 @@
+package gradebook
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding/charmap"
+	"golang.org/x/text/encoding/unicode"
+	"golang.org/x/text/transform"
+)
+
+// csvWorkbook is a delimited text file read as a single sheet named after
+// the file. Its rows are streamed, so the sheet can be read only once.
+type csvWorkbook struct {
+	name string
+	r    *csv.Reader
+	read bool
+}
+
+var candidateDelimiters = []rune{',', ';', '\t', '|'}
+
+// sniffSize is how much of a file is examined to detect its encoding and
+// delimiter.
+const sniffSize = 64 << 10
+
+func openCSV(r io.Reader, name string, opts ReaderOptions) (Workbook, error) {
+	text, err := decodingReader(bufio.NewReaderSize(r, sniffSize))
+	if err != nil {
+		return nil, err
+	}
+	br := bufio.NewReaderSize(text, sniffSize)
+
+	delim := opts.Delimiter
+	if delim == 0 {
+		head, _ := br.Peek(sniffSize)
+		delim = sniffDelimiter(string(head))
+	}
+	cr := csv.NewReader(br)
+	cr.Comma = delim
+	cr.FieldsPerRecord = -1
+	cr.LazyQuotes = true
+
+	sheet := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	return &csvWorkbook{name: sheet, r: cr}, nil
+}
+
+func (w *csvWorkbook) Sheets() []string { return []string{w.name} }
+
+func (w *csvWorkbook) Rows(sheet string) (RowIterator, error) {
+	if sheet != w.name {
+		return nil, fmt.Errorf("sheet %s does not exist", sheet)
+	}
+	if w.read {
+		return nil, fmt.Errorf("sheet %s has already been read", sheet)
+	}
+	w.read = true
+	return &csvRows{r: w.r}, nil
+}
+
+func (w *csvWorkbook) Close() error { return nil }
+
+type csvRows struct {
+	r   *csv.Reader
+	row []string
+	err error
+}
+
+func (r *csvRows) Next() bool {
+	if r.err != nil {
+		return false
+	}
+	r.row, r.err = r.r.Read()
+	return r.err == nil
+}
+
+func (r *csvRows) Row() []string { return r.row }
+
+func (r *csvRows) Err() error {
+	if r.err == io.EOF {
+		return nil
+	}
+	return r.err
+}
+
+func (r *csvRows) Close() error { return nil }
+
+// decodingReader converts LMS exports to UTF-8 as they are read. A byte
+// order mark decides between UTF-8 and UTF-16; without one, text whose
+// first part is not valid UTF-8 is assumed to be Windows-1252, which is
+// what Excel writes on most machines.
+func decodingReader(br *bufio.Reader) (io.Reader, error) {
+	head, err := br.Peek(sniffSize)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, err
+	}
+	switch {
+	case bytes.HasPrefix(head, []byte{0xEF, 0xBB, 0xBF}):
+		br.Discard(3)
+		return br, nil
+	case bytes.HasPrefix(head, []byte{0xFF, 0xFE}):
+		return transform.NewReader(br, unicode.UTF16(unicode.LittleEndian, unicode.ExpectBOM).NewDecoder()), nil
+	case bytes.HasPrefix(head, []byte{0xFE, 0xFF}):
+		return transform.NewReader(br, unicode.UTF16(unicode.BigEndian, unicode.ExpectBOM).NewDecoder()), nil
+	case utf8.Valid(trimPartialRune(head)):
+		return br, nil
+	default:
+		return charmap.Windows1252.NewDecoder().Reader(br), nil
+	}
+}
+
+// trimPartialRune drops a multi-byte character cut off at the end of b.
+func trimPartialRune(b []byte) []byte {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return b[:i]
+			}
+			break
+		}
+	}
+	return b
+}
+
+// sniffDelimiter picks the candidate that splits the first few lines into
+// the same, largest number of fields.
+func sniffDelimiter(text string) rune {
+	var lines []string
+	sc := bufio.NewScanner(strings.NewReader(text))
+	for sc.Scan() && len(lines) < 10 {
+		if strings.TrimSpace(sc.Text()) != "" {
+			lines = append(lines, sc.Text())
+		}
+	}
+
+	best, bestFields := ',', 0
+	for _, d := range candidateDelimiters {
+		fields, consistent := -1, true
+		for _, line := range lines {
+			n := countFields(line, d)
+			if fields >= 0 && n != fields {
+				consistent = false
+				break
+			}
+			fields = n
+		}
+		if consistent && fields > bestFields {
+			best, bestFields = d, fields
+		}
+	}
+	return best
+}
+
+// countFields counts delimiters outside double quotes.
+func countFields(line string, delim rune) int {
+	n, quoted := 1, false
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+		case r == delim && !quoted:
+			n++
+		}
+	}
+	return n
+}
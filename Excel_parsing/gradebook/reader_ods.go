@@ -0,0 +1,237 @@
+package gradebook
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	odsTableNS  = "urn:oasis:names:tc:opendocument:xmlns:table:1.0"
+	odsOfficeNS = "urn:oasis:names:tc:opendocument:xmlns:office:1.0"
+	odsTextNS   = "urn:oasis:names:tc:opendocument:xmlns:text:1.0"
+)
+
+// Limits on what an ODS file may expand to. The file and its content.xml
+// are read up front, and a repeat count can make one cell of XML stand for
+// millions, so without limits a small upload could exhaust memory. Rows and
+// columns are capped at LibreOffice's own sheet size, cells at a total well
+// above any gradebook, and the text of one cell at a spreadsheet's limit.
+const (
+	odsMaxFile     = 256 << 20
+	odsMaxContent  = 512 << 20
+	odsMaxRows     = 1 << 20
+	odsMaxColumns  = 1 << 14
+	odsMaxCells    = 1 << 23
+	odsMaxCellText = 32767
+)
+
+// odsWorkbook holds every table of an OpenDocument spreadsheet. The whole
+// of content.xml is decoded up front; gradebooks are small.
+type odsWorkbook struct {
+	names  []string
+	tables map[string][][]string
+}
+
+func openODS(r io.Reader, _ string, _ ReaderOptions) (Workbook, error) {
+	data, err := io.ReadAll(io.LimitReader(r, odsMaxFile+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > odsMaxFile {
+		return nil, fmt.Errorf("ODS file larger than %d MiB", odsMaxFile>>20)
+	}
+	z, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, entry := range z.File {
+		if entry.Name != "content.xml" {
+			continue
+		}
+		rc, err := entry.Open()
+		if err != nil {
+			return nil, err
+		}
+		defer rc.Close()
+		lr := &io.LimitedReader{R: rc, N: odsMaxContent + 1}
+		w, err := parseODSContent(lr)
+		if lr.N <= 0 {
+			return nil, fmt.Errorf("content.xml larger than %d MiB", odsMaxContent>>20)
+		}
+		return w, err
+	}
+	return nil, fmt.Errorf("content.xml not found")
+}
+
+func (w *odsWorkbook) Sheets() []string { return w.names }
+
+func (w *odsWorkbook) Rows(sheet string) (RowIterator, error) {
+	rows, ok := w.tables[sheet]
+	if !ok {
+		return nil, fmt.Errorf("sheet %s does not exist", sheet)
+	}
+	return &sliceRows{rows: rows}, nil
+}
+
+func (w *odsWorkbook) Close() error { return nil }
+
+// parseODSContent walks content.xml token by token. Repeated rows and cells
+// are expanded, except that trailing empty ones are dropped: LibreOffice
+// pads every sheet out to its full 1M x 1K size with a single repeat count.
+// A sheet that would expand past the limits above is an error.
+func parseODSContent(r io.Reader) (*odsWorkbook, error) {
+	w := &odsWorkbook{tables: make(map[string][][]string)}
+	dec := xml.NewDecoder(r)
+
+	var (
+		table       string
+		rows        [][]string
+		pendingRows int
+		row         []string
+		pendingRow  int
+		rowRepeat   int
+		cell        *strings.Builder
+		cellValue   string
+		cellRepeat  int
+		cells       int
+		paragraphs  int
+		inParagraph int
+	)
+
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch t := tok.(type) {
+		case xml.StartElement:
+			switch {
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				table = odsAttr(t, odsTableNS, "name")
+				rows, pendingRows, cells = nil, 0, 0
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				row, pendingRow = nil, 0
+				rowRepeat = odsRepeat(t, "number-rows-repeated")
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				cell = &strings.Builder{}
+				cellValue = odsCellValue(t)
+				cellRepeat = odsRepeat(t, "number-columns-repeated")
+				paragraphs = 0
+			case t.Name.Space == odsTextNS && t.Name.Local == "p" && cell != nil:
+				if paragraphs > 0 {
+					cell.WriteByte('\n')
+				}
+				paragraphs++
+				inParagraph++
+			case t.Name.Space == odsTextNS && t.Name.Local == "s" && cell != nil:
+				n := odsCount(odsAttr(t, odsTextNS, "c"))
+				if room := odsMaxCellText - cell.Len(); n > room {
+					n = max(room, 0)
+				}
+				cell.WriteString(strings.Repeat(" ", n))
+			case t.Name.Space == odsTextNS && t.Name.Local == "tab" && cell != nil:
+				cell.WriteByte('\t')
+			}
+
+		case xml.CharData:
+			// Only paragraph text counts; anything else is layout.
+			if cell != nil && inParagraph > 0 {
+				cell.Write(t)
+			}
+
+		case xml.EndElement:
+			switch {
+			case t.Name.Space == odsTextNS && t.Name.Local == "p" && cell != nil:
+				inParagraph--
+			case t.Name.Space == odsTableNS && (t.Name.Local == "table-cell" || t.Name.Local == "covered-table-cell"):
+				value := cellValue
+				if value == "" {
+					value = cell.String()
+				}
+				if value == "" {
+					pendingRow += cellRepeat
+				} else {
+					if len(row)+pendingRow+cellRepeat > odsMaxColumns {
+						return nil, fmt.Errorf("sheet %s: more than %d columns", table, odsMaxColumns)
+					}
+					for ; pendingRow > 0; pendingRow-- {
+						row = append(row, "")
+					}
+					for i := 0; i < cellRepeat; i++ {
+						row = append(row, value)
+					}
+				}
+				cell = nil
+			case t.Name.Space == odsTableNS && t.Name.Local == "table-row":
+				if len(row) == 0 {
+					pendingRows += rowRepeat
+				} else {
+					if len(rows)+pendingRows+rowRepeat > odsMaxRows {
+						return nil, fmt.Errorf("sheet %s: more than %d rows", table, odsMaxRows)
+					}
+					if cells += rowRepeat * len(row); cells > odsMaxCells {
+						return nil, fmt.Errorf("sheet %s: more than %d cells", table, odsMaxCells)
+					}
+					for ; pendingRows > 0; pendingRows-- {
+						rows = append(rows, nil)
+					}
+					for i := 0; i < rowRepeat; i++ {
+						rows = append(rows, append([]string(nil), row...))
+					}
+				}
+			case t.Name.Space == odsTableNS && t.Name.Local == "table":
+				w.names = append(w.names, table)
+				w.tables[table] = rows
+			}
+		}
+	}
+	return w, nil
+}
+
+func odsAttr(t xml.StartElement, space, local string) string {
+	for _, a := range t.Attr {
+		if a.Name.Space == space && a.Name.Local == local {
+			return a.Value
+		}
+	}
+	return ""
+}
+
+// odsRepeat reads a repeat count. Counts past the largest sheet are cut
+// down to just past it, which still trips the limits but cannot overflow
+// them.
+func odsRepeat(t xml.StartElement, attr string) int {
+	return min(odsCount(odsAttr(t, odsTableNS, attr)), odsMaxRows+1)
+}
+
+// odsCount parses a repeat or space count, which is 1 when missing or
+// below 1.
+func odsCount(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		return 1
+	}
+	return n
+}
+
+// odsCellValue prefers the typed value attribute, which keeps full
+// precision, over the formatted display text.
+func odsCellValue(t xml.StartElement) string {
+	switch odsAttr(t, odsOfficeNS, "value-type") {
+	case "float", "percentage", "currency":
+		return odsAttr(t, odsOfficeNS, "value")
+	case "boolean":
+		return odsAttr(t, odsOfficeNS, "boolean-value")
+	}
+	return ""
+}
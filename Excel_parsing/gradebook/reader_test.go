@@ -0,0 +1,271 @@
+package gradebook
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestDetectFormat(t *testing.T) {
+	tests := []struct {
+		name string
+		head string
+		want string
+	}{
+		{"marks.XLSX", "", "xlsx"},
+		{"marks.tab", "", "tsv"},
+		{"marks.ods", "PK\x03\x04", "ods"},
+		{"upload", "Emplid,Total\n", "csv"},
+		{"upload", "PK\x03\x04....mimetype" + odsMimeType, "ods"},
+		{"upload", "PK\x03\x04....[Content_Types].xml", "xlsx"},
+	}
+	for _, tt := range tests {
+		if got := detectFormat(tt.name, []byte(tt.head)); got != tt.want {
+			t.Errorf("detectFormat(%q, %q) = %s, want %s", tt.name, tt.head, got, tt.want)
+		}
+	}
+}
+
+func TestDecodingReader(t *testing.T) {
+	utf16 := func(order string, s string) []byte {
+		b := []byte(order)
+		for _, r := range s {
+			if order == "\xFF\xFE" {
+				b = append(b, byte(r), byte(r>>8))
+			} else {
+				b = append(b, byte(r>>8), byte(r))
+			}
+		}
+		return b
+	}
+	tests := []struct {
+		name  string
+		input []byte
+		want  string
+	}{
+		{"utf-8", []byte("Name\nJosé\n"), "Name\nJosé\n"},
+		{"utf-8 with bom", []byte("\xEF\xBB\xBFName\nJosé\n"), "Name\nJosé\n"},
+		{"utf-16le", utf16("\xFF\xFE", "Name\nJosé\n"), "Name\nJosé\n"},
+		{"utf-16be", utf16("\xFE\xFF", "Name\nJosé\n"), "Name\nJosé\n"},
+		{"windows-1252", []byte("Name\nJos\xE9 \x96 \x80\n"), "Name\nJosé – €\n"},
+		{"empty", nil, ""},
+	}
+	for _, tt := range tests {
+		r, err := decodingReader(bufio.NewReaderSize(bytes.NewReader(tt.input), sniffSize))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		got, err := io.ReadAll(r)
+		if err != nil || string(got) != tt.want {
+			t.Errorf("%s: decoded %q, %v, want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+// TestDecodingReaderSniffBoundary checks that UTF-8 text is not taken for
+// Windows-1252 when the sniffed prefix ends inside a character.
+func TestDecodingReaderSniffBoundary(t *testing.T) {
+	text := strings.Repeat("a", sniffSize-1) + "é"
+	r, err := decodingReader(bufio.NewReaderSize(strings.NewReader(text), sniffSize))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(r)
+	if string(got) != text {
+		t.Errorf("decoded %q, want the text unchanged", got[len(got)-4:])
+	}
+}
+
+func TestTrimPartialRune(t *testing.T) {
+	for in, want := range map[string]string{
+		"abc":        "abc",
+		"ab\xC3":     "ab",
+		"ab\xC3\xA9": "ab\xC3\xA9",
+		"a\xE2\x82":  "a",
+		"":           "",
+	} {
+		if got := string(trimPartialRune([]byte(in))); got != want {
+			t.Errorf("trimPartialRune(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSniffDelimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want rune
+	}{
+		{"comma", "Emplid,Total\n1,50\n", ','},
+		{"semicolon with decimal commas", "Emplid;Total\n1;50,5\n2;40,25\n", ';'},
+		{"tab", "Emplid\tName\tTotal\n1\tDoe, J\t50\n", '\t'},
+		{"pipe", "Emplid|Total\n1|50\n", '|'},
+		{"quoted delimiters", "Emplid,Name,Total\n1,\"Doe, J\",50\n", ','},
+		{"blank lines", "\nEmplid;Total\n\n1;50\n", ';'},
+		{"single column", "Emplid\n1\n", ','},
+	}
+	for _, tt := range tests {
+		if got := sniffDelimiter(tt.text); got != tt.want {
+			t.Errorf("%s: sniffed %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestOpenCSV(t *testing.T) {
+	wb, err := OpenWorkbookReader(strings.NewReader("Emplid;Name;Total\n1;\"Doe; J\";50\n2;Roe;\n"), "sem1/marks.txt", ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wb.Sheets(); !reflect.DeepEqual(got, []string{"marks"}) {
+		t.Fatalf("sheets %v, want [marks]", got)
+	}
+	rows, err := ReadRows(wb, "marks")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"Emplid", "Name", "Total"}, {"1", "Doe; J", "50"}, {"2", "Roe", ""}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows %q, want %q", rows, want)
+	}
+	if _, err := wb.Rows("marks"); err == nil {
+		t.Error("a streamed sheet was read twice")
+	}
+}
+
+// odsContent wraps table rows in the elements of an ODS content.xml.
+func odsContent(tables ...string) string {
+	return `<?xml version="1.0" encoding="UTF-8"?>
+<office:document-content xmlns:office="` + odsOfficeNS + `" xmlns:table="` + odsTableNS + `" xmlns:text="` + odsTextNS + `">
+<office:body><office:spreadsheet>` + strings.Join(tables, "") + `</office:spreadsheet></office:body></office:document-content>`
+}
+
+func TestParseODSContent(t *testing.T) {
+	tests := []struct {
+		name  string
+		table string
+		want  [][]string
+	}{
+		{
+			name: "typed values and text",
+			table: `<table:table-row>
+				<table:table-cell office:value-type="string"><text:p>Emplid</text:p></table:table-cell>
+				<table:table-cell office:value-type="float" office:value="42.125"><text:p>42.13</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"Emplid", "42.125"}},
+		},
+		{
+			name: "repeated cells",
+			table: `<table:table-row>
+				<table:table-cell office:value-type="float" office:value="0" table:number-columns-repeated="3"/>
+				<table:table-cell table:number-columns-repeated="2"/>
+				<table:table-cell><text:p>x</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"0", "0", "0", "", "", "x"}},
+		},
+		{
+			name: "repeated rows with gaps",
+			table: `<table:table-row table:number-rows-repeated="2"><table:table-cell><text:p>a</text:p></table:table-cell></table:table-row>
+				<table:table-row table:number-rows-repeated="2"><table:table-cell/></table:table-row>
+				<table:table-row><table:table-cell><text:p>b</text:p></table:table-cell></table:table-row>`,
+			want: [][]string{{"a"}, {"a"}, nil, nil, {"b"}},
+		},
+		{
+			name: "padding dropped",
+			table: `<table:table-row><table:table-cell><text:p>a</text:p></table:table-cell><table:table-cell table:number-columns-repeated="1024"/></table:table-row>
+				<table:table-row table:number-rows-repeated="1048575"><table:table-cell table:number-columns-repeated="1024"/></table:table-row>`,
+			want: [][]string{{"a"}},
+		},
+		{
+			name: "paragraphs, spaces and tabs",
+			table: `<table:table-row><table:table-cell>
+				<text:p>Doe,<text:s text:c="2"/>J<text:tab/>x</text:p><text:p>second</text:p>
+			</table:table-cell></table:table-row>`,
+			want: [][]string{{"Doe,  J\tx\nsecond"}},
+		},
+		{
+			name: "bad and huge space counts",
+			table: `<table:table-row>
+				<table:table-cell><text:p>a<text:s text:c="-3"/>b<text:s text:c="x"/>c</text:p></table:table-cell>
+				<table:table-cell><text:p>d<text:s text:c="99999999999"/></text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"a b c", "d" + strings.Repeat(" ", odsMaxCellText-1)}},
+		},
+		{
+			name: "bad repeat counts",
+			table: `<table:table-row table:number-rows-repeated="0">
+				<table:table-cell table:number-columns-repeated="many"><text:p>a</text:p></table:table-cell>
+			</table:table-row>`,
+			want: [][]string{{"a"}},
+		},
+	}
+	for _, tt := range tests {
+		w, err := parseODSContent(strings.NewReader(odsContent(`<table:table table:name="Marks">` + tt.table + `</table:table>`)))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if !reflect.DeepEqual(w.names, []string{"Marks"}) {
+			t.Fatalf("%s: sheets %v, want [Marks]", tt.name, w.names)
+		}
+		if got := w.tables["Marks"]; !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: rows %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestParseODSContentLimits(t *testing.T) {
+	cell := `<table:table-cell><text:p>a</text:p></table:table-cell>`
+	tests := []struct {
+		name  string
+		table string
+		err   string
+	}{
+		{"rows", `<table:table-row table:number-rows-repeated="99999999999">` + cell + `</table:table-row>`, "rows"},
+		{"rows after a gap", `<table:table-row table:number-rows-repeated="1048575"><table:table-cell/></table:table-row>
+			<table:table-row table:number-rows-repeated="2">` + cell + `</table:table-row>`, "rows"},
+		{"columns", `<table:table-row><table:table-cell table:number-columns-repeated="16385"><text:p>a</text:p></table:table-cell></table:table-row>`, "columns"},
+		{"cells", `<table:table-row table:number-rows-repeated="1000000">
+			<table:table-cell table:number-columns-repeated="16"><text:p>a</text:p></table:table-cell>
+		</table:table-row>`, "cells"},
+	}
+	for _, tt := range tests {
+		_, err := parseODSContent(strings.NewReader(odsContent(`<table:table table:name="Marks">` + tt.table + `</table:table>`)))
+		if err == nil || !strings.Contains(err.Error(), "more than") || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want too many %s", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestOpenODS(t *testing.T) {
+	var buf bytes.Buffer
+	z := zip.NewWriter(&buf)
+	m, _ := z.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	m.Write([]byte(odsMimeType))
+	c, _ := z.Create("content.xml")
+	c.Write([]byte(odsContent(
+		`<table:table table:name="Notes"/>`,
+		`<table:table table:name="Sem 1"><table:table-row><table:table-cell><text:p>Emplid</text:p></table:table-cell></table:table-row></table:table>`,
+	)))
+	if err := z.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// The name says nothing, so the format comes from the content.
+	wb, err := OpenWorkbookReader(bytes.NewReader(buf.Bytes()), "upload", ReaderOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := wb.Sheets(); !reflect.DeepEqual(got, []string{"Notes", "Sem 1"}) {
+		t.Errorf("sheets %v, want [Notes Sem 1]", got)
+	}
+	rows, err := ReadRows(wb, "Sem 1")
+	if err != nil || !reflect.DeepEqual(rows, [][]string{{"Emplid"}}) {
+		t.Errorf("rows %q, %v", rows, err)
+	}
+	if _, err := wb.Rows("Sem 2"); err == nil {
+		t.Error("a missing sheet was found")
+	}
+}
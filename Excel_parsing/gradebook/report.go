@@ -0,0 +1,253 @@
+package gradebook
+
+import "fmt"
+
+// SummaryReport is everything the tool reports about a set of students.
+type SummaryReport struct {
+	GeneralAverages    map[string]float64         `json:"general_averages"`
+	BranchAverages     map[string]float64         `json:"branch_averages"`
+	BranchRankings     map[string][]RankedStudent `json:"branch_rankings"`
+	SectionRankings    map[string][]RankedStudent `json:"section_rankings"`
+	OverallTopStudents []RankedStudent            `json:"overall_top_students"`
+	RankedBy           string                     `json:"ranked_by"`
+	Where              string                     `json:"where,omitempty"`
+	Statistics         StatisticsReport           `json:"statistics"`
+	Grading            *GradingSummary            `json:"grading,omitempty"`
+	TotalCheck         *TotalCheckReport          `json:"total_check,omitempty"`
+	UnknownBranchCodes []string                   `json:"unknown_branch_codes,omitempty"`
+	Validation         *ValidationReport          `json:"validation,omitempty"`
+	Charts             *ChartData                 `json:"charts,omitempty"`
+	Anomalies          *AnomalyReport             `json:"anomalies,omitempty"`
+	Correlations       *CorrelationReport         `json:"correlations,omitempty"`
+	SpecialMarks       *SpecialMarkReport         `json:"special_marks,omitempty"`
+	Rules              *RuleAudit                 `json:"rules,omitempty"`
+	Normalization      *NormalizationReport       `json:"normalization,omitempty"`
+	CutoffSuggestions  *CutoffSuggestion          `json:"cutoff_suggestions,omitempty"`
+}
+
+// Options select the optional parts of a report; nil and false fields are
+// skipped. Grades, normalization and cutoff suggestions always cover the
+// whole class, whatever Where picks.
+type Options struct {
+	Rank          RankOptions
+	Grading       *GradingConfig
+	MaxTotal      float64           // scales the default fixed grade cutoffs; 100 when zero
+	Weights       *WeightsConfig    // recomputes and checks Total
+	UseRecomputed bool              // ranks by the recomputed total; needs Weights
+	Charts        bool              // adds the data behind the charts
+	Where         string            // filter expression (see Filter) for the students reported on
+	Anomalies     *AnomalyOptions   // lists unusual score patterns
+	Correlations  bool              // adds correlation matrices and the Compre regression
+	Rules         *RuleSet          // applied to every student before anything else
+	Normalize     *NormalizeOptions // puts every section on a common scale as NormalizedTotal
+	UseNormalized bool              // ranks and grades by NormalizedTotal; needs Normalize
+	Breaks        *BreakOptions     // suggests grade cutoffs at natural breaks
+}
+
+// Generate computes a report. The caller's students are not modified;
+// grades and recomputed totals are set on copies.
+func Generate(students []Student, opts Options) (SummaryReport, error) {
+	students = append([]Student(nil), students...)
+
+	var rules *RuleAudit
+	if opts.Rules != nil {
+		audit, err := applyRules(students, *opts.Rules, opts.Weights)
+		if err != nil {
+			return SummaryReport{}, err
+		}
+		rules = &audit
+	}
+
+	var totalCheck *TotalCheckReport
+	if opts.Weights != nil {
+		check, err := checkTotals(students, *opts.Weights)
+		if err != nil {
+			return SummaryReport{}, err
+		}
+		totalCheck = &check
+	} else if opts.UseRecomputed {
+		return SummaryReport{}, fmt.Errorf("ranking by the recomputed total needs weights")
+	}
+
+	var normalization *NormalizationReport
+	if opts.Normalize != nil {
+		norm := *opts.Normalize
+		if norm.Of == "" && opts.UseRecomputed {
+			norm.Of = FieldRecomputedTotal
+		}
+		found, err := normalize(students, norm)
+		if err != nil {
+			return SummaryReport{}, err
+		}
+		normalization = &found
+	} else if opts.UseNormalized {
+		return SummaryReport{}, fmt.Errorf("ranking by the normalized total needs a normalization method")
+	}
+
+	gradeBy := FieldTotal
+	if opts.UseNormalized {
+		gradeBy = FieldNormalizedTotal
+	}
+	var grading *GradingSummary
+	if opts.Grading != nil {
+		maxTotal := opts.MaxTotal
+		if maxTotal == 0 {
+			maxTotal = 100
+		}
+		if opts.UseNormalized && normalization.Method == NormalizeZScore && opts.Grading.Scheme == SchemeFixed && len(opts.Grading.Cutoffs) == 0 {
+			return SummaryReport{}, fmt.Errorf("the default fixed grade cutoffs do not apply to z-scores; use meansd or percentile grading or give cutoffs")
+		}
+		summary := assignGrades(students, *opts.Grading, maxTotal, gradeBy)
+		grading = &summary
+	}
+
+	var suggestions *CutoffSuggestion
+	if opts.Breaks != nil {
+		breaks := *opts.Breaks
+		if breaks.By == "" {
+			breaks.By = gradeBy
+		}
+		found, err := SuggestCutoffs(students, breaks)
+		if err != nil {
+			return SummaryReport{}, err
+		}
+		suggestions = &found
+	}
+
+	if opts.Where != "" {
+		filter, err := CompileFilter(opts.Where, students)
+		if err != nil {
+			return SummaryReport{}, fmt.Errorf("bad filter: %w", err)
+		}
+		students = filter.Apply(students)
+		if totalCheck != nil {
+			check := totalCheck.restrict(students)
+			totalCheck = &check
+		}
+	}
+
+	rankOpts := opts.Rank
+	if opts.UseRecomputed {
+		rankOpts.By = FieldRecomputedTotal
+	}
+	if opts.UseNormalized {
+		rankOpts.By = FieldNormalizedTotal
+	}
+	if err := rankOpts.compile(students); err != nil {
+		return SummaryReport{}, err
+	}
+
+	var charts *ChartData
+	if opts.Charts {
+		data := BuildCharts(students)
+		charts = &data
+	}
+
+	var anomalies *AnomalyReport
+	if opts.Anomalies != nil {
+		found := DetectAnomalies(students, *opts.Anomalies)
+		anomalies = &found
+	}
+
+	var correlations *CorrelationReport
+	if opts.Correlations {
+		found := Correlations(students)
+		correlations = &found
+	}
+
+	return SummaryReport{
+		GeneralAverages:    GeneralAverages(students),
+		BranchAverages:     BranchWiseAverages(students),
+		BranchRankings:     BranchWiseRankings(students, rankOpts),
+		SectionRankings:    SectionWiseRankings(students, rankOpts),
+		OverallTopStudents: OverallTopStudents(students, rankOpts),
+		RankedBy:           rankOpts.By,
+		Where:              opts.Where,
+		Statistics:         ComponentStatistics(students),
+		Grading:            grading,
+		TotalCheck:         totalCheck,
+		Charts:             charts,
+		Anomalies:          anomalies,
+		Correlations:       correlations,
+		SpecialMarks:       SpecialMarks(students),
+		Rules:              rules,
+		Normalization:      normalization,
+		CutoffSuggestions:  suggestions,
+	}, nil
+}
+
+func OverallTopStudents(students []Student, opts RankOptions) []RankedStudent {
+	return topStudents(students, opts)
+}
+
+// GeneralAverages averages Total over the students who have one; a Total
+// left out by the exclude policy does not count as zero.
+func GeneralAverages(students []Student) map[string]float64 {
+	averages := make(map[string]float64)
+	var sumTotal float64
+	count := 0
+
+	for _, s := range students {
+		if total, ok := s.Component(FieldTotal); ok {
+			sumTotal += total
+			count++
+		}
+	}
+	if count == 0 {
+		return averages
+	}
+	averages["Total"] = sumTotal / float64(count)
+	return averages
+}
+
+// BranchWiseAverages averages Total per branch like GeneralAverages.
+func BranchWiseAverages(students []Student) map[string]float64 {
+	branchAverages := make(map[string]float64)
+	branchCounts := make(map[string]int)
+
+	for _, s := range students {
+		total, ok := s.Component(FieldTotal)
+		if !ok {
+			continue
+		}
+		for _, branch := range studentBranches(s) {
+			branchAverages[branch] += total
+			branchCounts[branch]++
+		}
+	}
+
+	for branch, total := range branchAverages {
+		branchAverages[branch] = total / float64(branchCounts[branch])
+	}
+	return branchAverages
+}
+
+func BranchWiseRankings(students []Student, opts RankOptions) map[string][]RankedStudent {
+	branchRankings := make(map[string][]RankedStudent)
+	branchStudents := make(map[string][]Student)
+
+	for _, s := range students {
+		for _, branch := range studentBranches(s) {
+			branchStudents[branch] = append(branchStudents[branch], s)
+		}
+	}
+
+	for branch, studs := range branchStudents {
+		branchRankings[branch] = rankStudents(studs, opts)
+	}
+	return branchRankings
+}
+
+// SectionWiseRankings ranks the students of each section (ClassNo) among
+// themselves.
+func SectionWiseRankings(students []Student, opts RankOptions) map[string][]RankedStudent {
+	bySection := make(map[string][]Student)
+	for _, s := range students {
+		bySection[s.ClassNo] = append(bySection[s.ClassNo], s)
+	}
+	rankings := make(map[string][]RankedStudent, len(bySection))
+	for section, studs := range bySection {
+		rankings[section] = rankStudents(studs, opts)
+	}
+	return rankings
+}
@@ -0,0 +1,483 @@
+package gradebook
+
+import (
+	"fmt"
+	"html"
+	"html/template"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Report card formats.
+const (
+	CardHTML = "html"
+	CardPDF  = "pdf"
+)
+
+var ReportCardFormats = []string{CardHTML, CardPDF}
+
+// ReportCard is one student's standing in the class: their marks against
+// the class mean and median, their ranks, percentile and grade, and the
+// distribution of the ranking score they fall in. Unranked students have
+// no ranking score, and ClassSize counts only those ranked.
+type ReportCard struct {
+	Emplid       string           `json:"emplid"`
+	CampusID     string           `json:"campus_id"`
+	Section      string           `json:"section"`
+	RankedBy     string           `json:"ranked_by"`
+	Score        float64          `json:"score"`
+	Rank         int              `json:"rank"`
+	ClassSize    int              `json:"class_size"`
+	Percentile   float64          `json:"percentile"`
+	Unranked     bool             `json:"unranked,omitempty"`
+	Grade        string           `json:"grade,omitempty"`
+	BranchRanks  []CardBranchRank `json:"branch_ranks"`
+	Marks        []CardMark       `json:"marks"`
+	Distribution Histogram        `json:"distribution"`
+}
+
+type CardBranchRank struct {
+	Branch string `json:"branch"`
+	Rank   int    `json:"rank"`
+	Size   int    `json:"size"`
+}
+
+// CardMark is one component. Rank is the student's place in the class on
+// that component alone, counting ties as equal. Special is the marker, or
+// the state of a blank cell, shown instead of a mark that was not made up.
+type CardMark struct {
+	Component string  `json:"component"`
+	Mark      float64 `json:"mark"`
+	Special   string  `json:"special,omitempty"`
+	Mean      float64 `json:"mean"`
+	Median    float64 `json:"median"`
+	Max       float64 `json:"max"`
+	Rank      int     `json:"rank"`
+}
+
+// BuildReportCards makes a card for every student reported on with opts,
+// in Emplid order. Class figures are over the same students, so a Where
+// filter narrows the class as well as the cards.
+func BuildReportCards(students []Student, opts Options) ([]ReportCard, error) {
+	opts.Rank.Top = 0
+	opts.Charts, opts.Anomalies, opts.Correlations = false, nil, false
+	report, err := Generate(students, opts)
+	if err != nil {
+		return nil, err
+	}
+	ranked := report.OverallTopStudents
+	class := make([]Student, len(ranked))
+	var scores []float64
+	for i, r := range ranked {
+		class[i] = r.Student
+		if !r.Unranked {
+			scores = append(scores, r.Score)
+		}
+	}
+
+	// Components nobody was marked in are columns the sheet did not have.
+	type column struct {
+		name   string
+		stats  Stats
+		sorted []float64
+	}
+	var columns []column
+	for _, c := range statComponents(class) {
+		st := report.Statistics.Overall[c]
+		if st.Count == 0 || (st.Min == 0 && st.Max == 0) {
+			continue
+		}
+		sorted := componentValues(class, c)
+		sort.Float64s(sorted)
+		columns = append(columns, column{c, st, sorted})
+	}
+
+	branchRanks := make(map[string][]CardBranchRank)
+	for branch, list := range report.BranchRankings {
+		size := rankedCount(list)
+		for _, r := range list {
+			if !r.Unranked {
+				branchRanks[r.Emplid] = append(branchRanks[r.Emplid], CardBranchRank{Branch: branch, Rank: r.Rank, Size: size})
+			}
+		}
+	}
+
+	distribution := histogram(report.RankedBy, scores)
+	cards := make([]ReportCard, 0, len(ranked))
+	for _, r := range ranked {
+		card := ReportCard{
+			Emplid: r.Emplid, CampusID: r.CampusID, Section: r.ClassNo,
+			RankedBy: report.RankedBy, Score: r.Score, Rank: r.Rank, ClassSize: len(scores),
+			Percentile: r.Percentile, Unranked: r.Unranked, Grade: r.Grade,
+			BranchRanks: branchRanks[r.Emplid], Marks: []CardMark{}, Distribution: distribution,
+		}
+		sort.Slice(card.BranchRanks, func(i, j int) bool { return card.BranchRanks[i].Branch < card.BranchRanks[j].Branch })
+		for _, col := range columns {
+			v, _ := r.Component(col.name)
+			above := len(col.sorted) - sort.Search(len(col.sorted), func(i int) bool { return col.sorted[i] > v })
+			m := CardMark{
+				Component: col.name, Mark: v, Mean: col.stats.Mean, Median: col.stats.Median, Max: col.stats.Max, Rank: above + 1,
+			}
+			if sm, ok := r.Special[col.name]; ok && sm.Makeup == nil {
+				m.Special, m.Rank = sm.Marker, 0
+				if m.Special == "" {
+					m.Special = sm.State
+				}
+			}
+			card.Marks = append(card.Marks, m)
+		}
+		cards = append(cards, card)
+	}
+	sort.Slice(cards, func(i, j int) bool { return cards[i].Emplid < cards[j].Emplid })
+	return cards, nil
+}
+
+// WriteReportCards writes each card into dir in every one of formats,
+// named by Emplid, and an index.html linking to them. It returns the
+// number of cards written.
+func WriteReportCards(dir string, cards []ReportCard, formats []string) (int, error) {
+	if len(formats) == 0 {
+		formats = []string{CardHTML}
+	}
+	for _, f := range formats {
+		if !contains(ReportCardFormats, f) {
+			return 0, fmt.Errorf("unknown report card format %q (want %s)", f, strings.Join(ReportCardFormats, ", "))
+		}
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return 0, err
+	}
+
+	names := make([]string, len(cards))
+	seen := make(map[string]bool, len(cards))
+	for i, c := range cards {
+		name := fileSlug(c.Emplid)
+		if name == "" || seen[name] {
+			return i, fmt.Errorf("Emplid %q does not make a unique file name", c.Emplid)
+		}
+		seen[name] = true
+		names[i] = name
+	}
+	for i, c := range cards {
+		for _, f := range formats {
+			path := filepath.Join(dir, names[i]+"."+f)
+			var err error
+			switch f {
+			case CardHTML:
+				err = writeCardHTML(path, c)
+			case CardPDF:
+				err = writeCardPDF(path, c)
+			}
+			if err != nil {
+				return i, fmt.Errorf("%s: %w", c.Emplid, err)
+			}
+		}
+	}
+
+	file, err := os.Create(filepath.Join(dir, "index.html"))
+	if err != nil {
+		return len(cards), err
+	}
+	defer file.Close()
+	type entry struct {
+		ReportCard
+		Name string
+	}
+	entries := make([]entry, len(cards))
+	for i, c := range cards {
+		entries[i] = entry{c, names[i]}
+	}
+	return len(cards), cardIndex.Execute(file, struct {
+		Formats []string
+		Cards   []entry
+	}{formats, entries})
+}
+
+const cardStyle = `body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 1.5em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+th { background: #f0f0f0; text-align: left; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+td.above { color: #1a7f37; }
+td.below { color: #c00; }
+svg { max-width: 560px; height: auto; }`
+
+var cardFuncs = template.FuncMap{
+	"cell": FormatCell,
+	"diff": func(m CardMark) string { return fmt.Sprintf("%+.2f", m.Mark-m.Mean) },
+	"side": func(m CardMark) string {
+		switch {
+		case m.Mark > m.Mean:
+			return "above"
+		case m.Mark < m.Mean:
+			return "below"
+		}
+		return ""
+	},
+}
+
+var cardPage = template.Must(template.New("card").Funcs(cardFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Report Card {{.Emplid}}</title>
+<style>
+` + cardStyle + `
+</style>
+</head>
+<body>
+<h1>Report Card</h1>
+<table>
+<tr><th>Emplid</th><td>{{.Emplid}}</td></tr>
+<tr><th>Campus ID</th><td>{{.CampusID}}</td></tr>
+{{if .Section}}<tr><th>Section</th><td>{{.Section}}</td></tr>
+{{end}}{{if .Unranked}}<tr><th>Overall Rank</th><td>unranked, no {{.RankedBy}} score</td></tr>
+{{else}}<tr><th>{{.RankedBy}}</th><td class="num">{{cell .Score}}</td></tr>
+<tr><th>Overall Rank</th><td class="num">{{.Rank}} of {{.ClassSize}}</td></tr>
+{{range .BranchRanks}}<tr><th>Rank in {{.Branch}}</th><td class="num">{{.Rank}} of {{.Size}}</td></tr>
+{{end}}<tr><th>Percentile</th><td class="num">{{cell .Percentile}}</td></tr>
+{{end}}
+{{if .Grade}}<tr><th>Grade</th><td>{{.Grade}}</td></tr>
+{{end}}</table>
+<h2>Marks</h2>
+<table>
+<thead><tr><th>Component</th><th>Mark</th><th>Class Mean</th><th>Class Median</th><th>Class Highest</th><th>vs Mean</th><th>Rank</th></tr></thead>
+<tbody>
+{{range .Marks}}<tr><td>{{.Component}}</td>{{if .Special}}<td class="num">{{.Special}}</td>{{else}}<td class="num">{{cell .Mark}}</td>{{end}}<td class="num">{{cell .Mean}}</td><td class="num">{{cell .Median}}</td><td class="num">{{cell .Max}}</td>{{if .Special}}<td></td><td></td>{{else}}<td class="num {{side .}}">{{diff .}}</td><td class="num">{{.Rank}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>
+<h2>Where You Stand</h2>
+{{.Chart}}
+</body>
+</html>
+`))
+
+var cardIndex = template.Must(template.New("index").Funcs(cardFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Report Cards</title>
+<style>
+` + cardStyle + `
+</style>
+</head>
+<body>
+<h1>Report Cards</h1>
+<table>
+<thead><tr><th>Emplid</th><th>Campus ID</th><th>Section</th><th>Rank</th><th>Percentile</th><th>Grade</th><th>Card</th></tr></thead>
+<tbody>
+{{range .Cards}}<tr><td>{{.Emplid}}</td><td>{{.CampusID}}</td><td>{{.Section}}</td>{{if .Unranked}}<td>unranked</td><td></td>{{else}}<td class="num">{{.Rank}}</td><td class="num">{{cell .Percentile}}</td>{{end}}<td>{{.Grade}}</td><td>{{$name := .Name}}{{range $.Formats}}<a href="{{$name}}.{{.}}">{{.}}</a> {{end}}</td></tr>
+{{end}}</tbody>
+</table>
+</body>
+</html>
+`))
+
+func writeCardHTML(path string, c ReportCard) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return cardPage.Execute(file, struct {
+		ReportCard
+		Chart template.HTML
+	}{c, template.HTML(svgCardHistogram(c))})
+}
+
+// svgCardHistogram draws the class distribution with the student's bin
+// highlighted and a line at their score.
+func svgCardHistogram(c ReportCard) string {
+	h := c.Distribution
+	xlo, xhi := 0.0, 1.0
+	if len(h.Bins) > 0 {
+		xlo, xhi = h.Bins[0].Lo, h.Bins[len(h.Bins)-1].Hi
+	}
+	maxCount := 0
+	for _, bin := range h.Bins {
+		if bin.Count > maxCount {
+			maxCount = bin.Count
+		}
+	}
+	_, yhi := niceRange(0, float64(maxCount))
+	p := newPlot(fmt.Sprintf("Class distribution of %s", h.Component), svgWidth, svgBottom, xlo, xhi, 0, yhi)
+	p.yAxis("Students")
+	mine := -1
+	if !c.Unranked {
+		mine = cardBin(h, c.Score)
+	}
+	for i, bin := range h.Bins {
+		fill := "#c5d5f0"
+		if i == mine {
+			fill = svgBarFill
+		}
+		x0, x1 := p.x(bin.Lo), p.x(bin.Hi)
+		y := p.y(float64(bin.Count))
+		fmt.Fprintf(&p.b, `<rect x="%.1f" y="%.1f" width="%.1f" height="%.1f" fill="%s" stroke="white"><title>%s–%s: %d</title></rect>`+"\n",
+			x0, y, x1-x0, p.y(0)-y, fill, tickLabel(bin.Lo), tickLabel(bin.Hi), bin.Count)
+	}
+	if !c.Unranked {
+		x := p.x(c.Score)
+		fmt.Fprintf(&p.b, `<line x1="%.1f" y1="%d" x2="%.1f" y2="%.1f" stroke="#c00" stroke-width="2"/>`+"\n", x, svgTop, x, p.y(0))
+		fmt.Fprintf(&p.b, `<text x="%.1f" y="%d" text-anchor="middle" fill="#c00">You: %s</text>`+"\n", x, svgTop-4, html.EscapeString(FormatCell(c.Score)))
+	}
+	p.xAxis(h.Component)
+	return p.String()
+}
+
+// cardBin returns the index of the bin holding v, the way histogram
+// counts it.
+func cardBin(h Histogram, v float64) int {
+	if len(h.Bins) == 0 {
+		return -1
+	}
+	start, width := h.Bins[0].Lo, h.Bins[0].Hi-h.Bins[0].Lo
+	i := int(math.Floor((v - start) / width))
+	if i >= len(h.Bins) {
+		i = len(h.Bins) - 1
+	}
+	return i
+}
+
+// writeCardPDF lays the card out on one A4 page.
+func writeCardPDF(path string, c ReportCard) error {
+	const left, right = 50.0, pdfWidth - 50.0
+	p := &pdfPage{}
+	y := pdfHeight - 60.0
+	p.text(left, y, 20, true, "Report Card")
+	y -= 32
+
+	field := func(label, value string) {
+		p.text(left, y, 11, true, label)
+		p.text(left+130, y, 11, false, value)
+		y -= 16
+	}
+	field("Emplid", c.Emplid)
+	field("Campus ID", c.CampusID)
+	if c.Section != "" {
+		field("Section", c.Section)
+	}
+	if c.Unranked {
+		field("Overall Rank", "unranked, no "+c.RankedBy+" score")
+	} else {
+		field(c.RankedBy, FormatCell(c.Score))
+		field("Overall Rank", fmt.Sprintf("%d of %d", c.Rank, c.ClassSize))
+		for _, b := range c.BranchRanks {
+			field("Branch Rank", fmt.Sprintf("%d of %d in %s", b.Rank, b.Size, b.Branch))
+		}
+		field("Percentile", FormatCell(c.Percentile))
+	}
+	if c.Grade != "" {
+		field("Grade", c.Grade)
+	}
+
+	y -= 16
+	p.text(left, y, 14, true, "Marks")
+	y -= 20
+	header := []string{"Mark", "Class Mean", "Class Median", "Class Highest", "vs Mean", "Rank"}
+	columns := []float64{215, 285, 365, 445, 505, right}
+	p.text(left, y, 10, true, "Component")
+	for i, h := range header {
+		p.textRight(columns[i], y, 10, true, h)
+	}
+	p.stroke(0.6, 0.6, 0.6, 0.5)
+	p.line(left, y-4, right, y-4)
+	y -= 18
+	for _, m := range c.Marks {
+		p.fill(0, 0, 0)
+		p.text(left, y, 10, false, m.Component)
+		cells := []string{FormatCell(m.Mark), FormatCell(m.Mean), FormatCell(m.Median), FormatCell(m.Max), "", fmt.Sprint(m.Rank)}
+		if m.Special != "" {
+			cells[0], cells[5] = m.Special, ""
+		}
+		for i, v := range cells {
+			if v != "" {
+				p.textRight(columns[i], y, 10, false, v)
+			}
+		}
+		if m.Special != "" {
+			y -= 15
+			continue
+		}
+		switch {
+		case m.Mark > m.Mean:
+			p.fill(0.1, 0.5, 0.22)
+		case m.Mark < m.Mean:
+			p.fill(0.8, 0, 0)
+		}
+		p.textRight(columns[4], y, 10, false, fmt.Sprintf("%+.2f", m.Mark-m.Mean))
+		y -= 15
+	}
+	p.fill(0, 0, 0)
+
+	y -= 20
+	p.text(left, y, 14, true, "Where You Stand")
+	pdfCardHistogram(p, c, left+30, math.Max(60, y-200), right-left-30, 150)
+
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return writePDF(file, "Report Card "+c.Emplid, []*pdfPage{p})
+}
+
+// pdfCardHistogram draws the class distribution into the box at x, y
+// (bottom left) the same way svgCardHistogram does.
+func pdfCardHistogram(p *pdfPage, c ReportCard, x, y, width, height float64) {
+	h := c.Distribution
+	if len(h.Bins) == 0 {
+		return
+	}
+	xlo, xhi := h.Bins[0].Lo, h.Bins[len(h.Bins)-1].Hi
+	maxCount := 0
+	for _, bin := range h.Bins {
+		if bin.Count > maxCount {
+			maxCount = bin.Count
+		}
+	}
+	_, yhi := niceRange(0, float64(maxCount))
+	px := func(v float64) float64 { return x + (v-xlo)/(xhi-xlo)*width }
+	py := func(v float64) float64 { return y + v/yhi*height }
+
+	p.stroke(0.88, 0.88, 0.88, 0.5)
+	for _, t := range ticks(0, yhi) {
+		p.line(x, py(t), x+width, py(t))
+		p.textRight(x-4, py(t)-3, 8, false, tickLabel(t))
+	}
+	mine := -1
+	if !c.Unranked {
+		mine = cardBin(h, c.Score)
+	}
+	for i, bin := range h.Bins {
+		if i == mine {
+			p.fill(0.267, 0.447, 0.769)
+		} else {
+			p.fill(0.773, 0.835, 0.941)
+		}
+		p.rect(px(bin.Lo)+0.5, y, px(bin.Hi)-px(bin.Lo)-1, py(float64(bin.Count))-y)
+	}
+	p.fill(0, 0, 0)
+	p.stroke(0.2, 0.2, 0.2, 0.75)
+	p.line(x, y, x+width, y)
+	for _, t := range ticks(xlo, xhi) {
+		label := tickLabel(t)
+		p.line(px(t), y, px(t), y-3)
+		p.text(px(t)-pdfTextWidth(label, 8)/2, y-12, 8, false, label)
+	}
+	p.text(x+width/2-pdfTextWidth(h.Component, 9)/2, y-26, 9, false, h.Component)
+
+	if c.Unranked {
+		return
+	}
+	sx := px(c.Score)
+	p.stroke(0.8, 0, 0, 1.5)
+	p.line(sx, y, sx, y+height+4)
+	p.fill(0.8, 0, 0)
+	label := "You: " + FormatCell(c.Score)
+	p.text(sx-pdfTextWidth(label, 9)/2, y+height+8, 9, false, label)
+	p.fill(0, 0, 0)
+}
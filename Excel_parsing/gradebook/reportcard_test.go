@@ -0,0 +1,186 @@
+package gradebook
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// cardStudents is a class of four in two branches; 4 was absent for the
+// MidSem and scored zero in it.
+func cardStudents() []Student {
+	return []Student{
+		{Emplid: "1", CampusID: "2022A7PS0001P", ClassNo: "1", MidSem: 40, Compre: 50, Total: 90},
+		{Emplid: "2", CampusID: "2022A7PS0002P", ClassNo: "1", MidSem: 30, Compre: 40, Total: 70},
+		{Emplid: "3", CampusID: "2022A3PS0003P", ClassNo: "2", MidSem: 30, Compre: 20, Total: 50},
+		{Emplid: "4", CampusID: "2022A3PS0004P", ClassNo: "2", MidSem: 0, Compre: 30, Total: 30,
+			Special: map[string]SpecialMark{FieldMidSem: {Marker: "AB", State: MarkAbsent, Policy: PolicyZero}}},
+	}
+}
+
+func TestBuildReportCards(t *testing.T) {
+	type mark struct {
+		Rank    int
+		Special string
+	}
+	tests := []struct {
+		where      string
+		emplids    []string
+		classSize  int
+		ranks      []int
+		branchRank []int
+		marks      map[string]map[string]mark // by Emplid, then component
+	}{
+		{
+			where:      "",
+			emplids:    []string{"1", "2", "3", "4"},
+			classSize:  4,
+			ranks:      []int{1, 2, 3, 4},
+			branchRank: []int{1, 2, 1, 2},
+			marks: map[string]map[string]mark{
+				"2": {FieldMidSem: {2, ""}, FieldCompre: {2, ""}, FieldTotal: {2, ""}},
+				"3": {FieldMidSem: {2, ""}, FieldCompre: {4, ""}, FieldTotal: {3, ""}},
+				"4": {FieldMidSem: {0, "AB"}, FieldCompre: {3, ""}, FieldTotal: {4, ""}},
+			},
+		},
+		{
+			// The filter narrows the class the cards are ranked in.
+			where:      "Compre >= 30",
+			emplids:    []string{"1", "2", "4"},
+			classSize:  3,
+			ranks:      []int{1, 2, 3},
+			branchRank: []int{1, 2, 1},
+			marks: map[string]map[string]mark{
+				"4": {FieldMidSem: {0, "AB"}, FieldCompre: {3, ""}, FieldTotal: {3, ""}},
+			},
+		},
+	}
+	for _, tt := range tests {
+		students := cardStudents()
+		r, err := NewBranchRegistry(BranchConfig{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.assign(students)
+		cards, err := BuildReportCards(students, Options{Rank: DefaultRankOptions(), Where: tt.where})
+		if err != nil {
+			t.Fatalf("%q: %v", tt.where, err)
+		}
+		var emplids []string
+		var ranks, branchRanks []int
+		for _, c := range cards {
+			emplids = append(emplids, c.Emplid)
+			ranks = append(ranks, c.Rank)
+			if len(c.BranchRanks) != 1 {
+				t.Errorf("%q: %s has branch ranks %+v", tt.where, c.Emplid, c.BranchRanks)
+				continue
+			}
+			branchRanks = append(branchRanks, c.BranchRanks[0].Rank)
+			var binned int
+			for _, b := range c.Distribution.Bins {
+				binned += b.Count
+			}
+			if c.ClassSize != tt.classSize || binned != tt.classSize {
+				t.Errorf("%q: %s in a class of %d with %d in the distribution, want %d", tt.where, c.Emplid, c.ClassSize, binned, tt.classSize)
+			}
+			want, ok := tt.marks[c.Emplid]
+			if !ok {
+				continue
+			}
+			got := make(map[string]mark)
+			for _, m := range c.Marks {
+				got[m.Component] = mark{m.Rank, m.Special}
+			}
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("%q: %s marks %+v, want %+v", tt.where, c.Emplid, got, want)
+			}
+		}
+		if !reflect.DeepEqual(emplids, tt.emplids) || !reflect.DeepEqual(ranks, tt.ranks) || !reflect.DeepEqual(branchRanks, tt.branchRank) {
+			t.Errorf("%q: cards %v ranked %v and %v in branch, want %v, %v and %v",
+				tt.where, emplids, ranks, branchRanks, tt.emplids, tt.ranks, tt.branchRank)
+		}
+	}
+}
+
+func TestWriteReportCards(t *testing.T) {
+	cards, err := BuildReportCards(cardStudents(), Options{Rank: DefaultRankOptions()})
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name    string
+		cards   []ReportCard
+		formats []string
+		files   []string
+		err     string
+	}{
+		{"html by default", cards, nil, []string{"1.html", "4.html", "index.html"}, ""},
+		{"both formats", cards, []string{CardHTML, CardPDF}, []string{"1.html", "1.pdf", "4.pdf", "index.html"}, ""},
+		{"unknown format", cards, []string{"docx"}, nil, "unknown report card format"},
+		{"clashing names", []ReportCard{{Emplid: "a/b"}, {Emplid: "a b"}}, nil, nil, "unique file name"},
+		{"no name", []ReportCard{{Emplid: "//"}}, nil, nil, "unique file name"},
+	}
+	for _, tt := range tests {
+		dir := filepath.Join(t.TempDir(), "cards")
+		n, err := WriteReportCards(dir, tt.cards, tt.formats)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if n != len(tt.cards) {
+			t.Errorf("%s: wrote %d cards, want %d", tt.name, n, len(tt.cards))
+		}
+		for _, f := range tt.files {
+			data, err := os.ReadFile(filepath.Join(dir, f))
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if strings.HasSuffix(f, ".pdf") && (!bytes.HasPrefix(data, []byte("%PDF-1.4")) || !bytes.HasSuffix(data, []byte("%%EOF\n"))) {
+				t.Errorf("%s: %s is not a PDF", tt.name, f)
+			}
+		}
+	}
+}
+
+func TestPDFString(t *testing.T) {
+	for s, want := range map[string]string{
+		"Total":      "Total",
+		"f(x) \\ 2":  `f\(x\) \\ 2`,
+		"8–10":       "8-10",
+		"café":       `caf\351`,
+		"emoji 🙂":    "emoji ?",
+		"tab\tstops": "tab?stops",
+	} {
+		if got := pdfString(s); got != want {
+			t.Errorf("pdfString(%q) = %q, want %q", s, got, want)
+		}
+	}
+}
+
+func TestCardBin(t *testing.T) {
+	h := histogram(FieldTotal, seq(0, 4, 25))
+	tests := []struct {
+		v    float64
+		want int
+	}{
+		{0, 0}, {19.9, 0}, {20, 1}, {96, 4}, {100, 4},
+	}
+	for _, tt := range tests {
+		if got := cardBin(h, tt.v); got != tt.want {
+			t.Errorf("cardBin(%g) = %d, want %d", tt.v, got, tt.want)
+		}
+	}
+	if got := cardBin(Histogram{}, 5); got != -1 {
+		t.Errorf("cardBin on no bins = %d, want -1", got)
+	}
+}
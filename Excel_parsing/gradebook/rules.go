@@ -0,0 +1,483 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// Rule types.
+const (
+	RuleReplace    = "replace"
+	RuleBest       = "best"
+	RuleDropLowest = "drop_lowest"
+)
+
+var RuleTypes = []string{RuleReplace, RuleBest, RuleDropLowest}
+
+// When a replace rule applies: always, only when the target is a special
+// mark, only when it is marked absent, or only when the source is higher
+// (an improvement exam).
+const (
+	WhenAlways  = "always"
+	WhenSpecial = "special"
+	WhenAbsent  = "absent"
+	WhenHigher  = "higher"
+)
+
+var RuleConditions = []string{WhenAlways, WhenSpecial, WhenAbsent, WhenHigher}
+
+// How totals follow the rules: Total (and PreCompre for the components
+// marked before Compre) changes by the change in each component, is set
+// to the weighted total, or is left as recorded.
+const (
+	TotalSum     = "sum"
+	TotalWeights = "weights"
+	TotalNone    = "none"
+)
+
+// Rule is one step of a RuleSet. Replace sets Target to Source when
+// When holds. Best sets Target to the highest of Sources. DropLowest sets
+// Target to the sum of Sources without the Drop lowest (one by default),
+// scaled back up to the full count when Scale is set. Sources name
+// columns of the scores files or the student's own components.
+type Rule struct {
+	Name    string   `json:"name" yaml:"name"`
+	Type    string   `json:"type" yaml:"type"`
+	Target  string   `json:"target" yaml:"target"`
+	Source  string   `json:"source" yaml:"source"`
+	Sources []string `json:"sources" yaml:"sources"`
+	When    string   `json:"when" yaml:"when"`
+	Drop    int      `json:"drop" yaml:"drop"`
+	Scale   bool     `json:"scale" yaml:"scale"`
+}
+
+// RuleSet is the makeup and improvement rule file, applied in order before
+// ranking and grading, with the scores read from the secondary files.
+type RuleSet struct {
+	Rules []Rule `json:"rules" yaml:"rules"`
+	Total string `json:"total" yaml:"total"`
+
+	scores  map[string]map[string]float64
+	columns map[string]bool
+}
+
+// RuleChange is one substitution made by a rule, or by recomputing the
+// totals, for the audit trail.
+type RuleChange struct {
+	Rule      string  `json:"rule"`
+	Emplid    string  `json:"emplid"`
+	CampusID  string  `json:"campus_id"`
+	Component string  `json:"component"`
+	Old       float64 `json:"old"`
+	New       float64 `json:"new"`
+	Detail    string  `json:"detail"`
+}
+
+// RuleAudit lists every change the rules made, in the order made.
+type RuleAudit struct {
+	Total    string       `json:"total"`
+	Students int          `json:"students"`
+	Changes  []RuleChange `json:"changes"`
+}
+
+func LoadRuleSet(path string) (RuleSet, error) {
+	var rs RuleSet
+	if err := LoadConfigFile(path, &rs); err != nil {
+		return rs, err
+	}
+	if rs.Total == "" {
+		rs.Total = TotalSum
+	}
+	if !contains([]string{TotalSum, TotalWeights, TotalNone}, rs.Total) {
+		return rs, fmt.Errorf("%s: unknown total %q (want %s, %s or %s)", path, rs.Total, TotalSum, TotalWeights, TotalNone)
+	}
+	if len(rs.Rules) == 0 {
+		return rs, fmt.Errorf("%s: no rules", path)
+	}
+	for i := range rs.Rules {
+		if err := rs.Rules[i].check(); err != nil {
+			return rs, fmt.Errorf("%s: rule %d: %w", path, i+1, err)
+		}
+	}
+	return rs, nil
+}
+
+func (r *Rule) check() error {
+	if r.Target == "" {
+		return fmt.Errorf("no target")
+	}
+	switch r.Type {
+	case RuleReplace:
+		if r.Source == "" {
+			return fmt.Errorf("replace needs a source")
+		}
+		if r.When == "" {
+			r.When = WhenAlways
+		}
+		if !contains(RuleConditions, r.When) {
+			return fmt.Errorf("unknown condition %q (want %s)", r.When, strings.Join(RuleConditions, ", "))
+		}
+	case RuleBest:
+		if len(r.Sources) < 2 {
+			return fmt.Errorf("best needs at least two sources")
+		}
+	case RuleDropLowest:
+		if r.Drop == 0 {
+			r.Drop = 1
+		}
+		if r.Drop < 0 || r.Drop >= len(r.Sources) {
+			return fmt.Errorf("cannot drop %d of %d sources", r.Drop, len(r.Sources))
+		}
+	default:
+		return fmt.Errorf("unknown type %q (want %s)", r.Type, strings.Join(RuleTypes, ", "))
+	}
+	if r.Name == "" {
+		r.Name = r.describe()
+	}
+	return nil
+}
+
+func (r Rule) describe() string {
+	switch r.Type {
+	case RuleReplace:
+		if r.When == WhenAlways {
+			return fmt.Sprintf("%s replaces %s", r.Source, r.Target)
+		}
+		return fmt.Sprintf("%s replaces %s when %s", r.Source, r.Target, r.When)
+	case RuleBest:
+		return fmt.Sprintf("%s is the best of %s", r.Target, strings.Join(r.Sources, ", "))
+	default:
+		return fmt.Sprintf("%s drops the lowest %d of %s", r.Target, r.Drop, strings.Join(r.Sources, ", "))
+	}
+}
+
+// LoadScores reads a makeup or improvement score file: a sheet with an
+// Emplid column and one column per score. Blank cells and special marks
+// mean no score. Several files may be loaded; a later score replaces an
+// earlier one of the same name.
+func (rs *RuleSet) LoadScores(path string, opts ReaderOptions) error {
+	wb, err := OpenWorkbook(path, opts)
+	if err != nil {
+		return err
+	}
+	defer wb.Close()
+	sheets, err := selectSheets(wb.Sheets(), SheetSelection{})
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	rows, err := ReadRows(wb, sheets[0])
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+
+	lookup := ColumnConfig{}.lookup()
+	header, emplid := -1, -1
+	for i := 0; i < len(rows) && i < headerSearchRows && header < 0; i++ {
+		for j, h := range rows[i] {
+			if lookup[normalizeHeader(h)] == FieldEmplid {
+				header, emplid = i, j
+				break
+			}
+		}
+	}
+	if header < 0 {
+		return fmt.Errorf("%s: no Emplid column", path)
+	}
+	names := make(map[int]string)
+	for j, h := range rows[header] {
+		h = strings.TrimSpace(h)
+		switch lookup[normalizeHeader(h)] {
+		case FieldEmplid, FieldCampusID, FieldClassNo:
+			continue
+		}
+		if h != "" {
+			names[j] = h
+		}
+	}
+
+	if rs.scores == nil {
+		rs.scores = make(map[string]map[string]float64)
+		rs.columns = make(map[string]bool)
+	}
+	for _, name := range names {
+		rs.columns[name] = true
+	}
+	var markers SpecialMarkConfig
+	for i := header + 1; i < len(rows); i++ {
+		row := rows[i]
+		if emplid >= len(row) || strings.TrimSpace(row[emplid]) == "" {
+			continue
+		}
+		id := strings.TrimSpace(row[emplid])
+		for j, name := range names {
+			if j >= len(row) {
+				continue
+			}
+			value := strings.TrimSpace(row[j])
+			if _, special := markers.state(value); special {
+				continue
+			}
+			v, err := parseFloat(value)
+			if err != nil {
+				return fmt.Errorf("%s: row %d, %s: malformed number %q", path, i+1, name, value)
+			}
+			if rs.scores[id] == nil {
+				rs.scores[id] = make(map[string]float64)
+			}
+			rs.scores[id][name] = v
+		}
+	}
+	return nil
+}
+
+// source looks a rule source up in the score files, then on the student.
+func (rs RuleSet) source(s Student, name string) (float64, bool) {
+	if v, ok := rs.scores[s.Emplid][name]; ok {
+		return v, true
+	}
+	return s.Component(name)
+}
+
+// knownSource reports whether name is a score column or a component.
+func (rs RuleSet) knownSource(name string, components []string) bool {
+	return rs.columns[name] || contains(components, name)
+}
+
+// applyRules runs rs over students in place and recomputes their totals.
+// Weights are needed for the weights total.
+func applyRules(students []Student, rs RuleSet, weights *WeightsConfig) (RuleAudit, error) {
+	audit := RuleAudit{Total: rs.Total, Changes: []RuleChange{}}
+	if rs.Total == TotalWeights && weights == nil {
+		return audit, fmt.Errorf("rules recomputing the total from weights need weights")
+	}
+	components := statComponents(students)
+	for _, r := range rs.Rules {
+		if !contains(components, r.Target) {
+			return audit, fmt.Errorf("rule %q: unknown target %q", r.Name, r.Target)
+		}
+		for _, name := range append([]string{r.Source}, r.Sources...) {
+			if name != "" && !rs.knownSource(name, components) {
+				return audit, fmt.Errorf("rule %q: unknown source %q", r.Name, name)
+			}
+		}
+	}
+	hasPreCompre := false
+	for _, s := range students {
+		hasPreCompre = hasPreCompre || s.PreCompre != 0
+	}
+
+	changed := 0
+	for i := range students {
+		s := &students[i]
+		before := *s
+		n := len(audit.Changes)
+		for _, r := range rs.Rules {
+			old, _ := s.component(r.Target)
+			v, detail, ok := r.apply(*s, rs)
+			if !ok || math.Abs(v-old) <= markEpsilon {
+				continue
+			}
+			s.setComponent(r.Target, v)
+			audit.Changes = append(audit.Changes, RuleChange{
+				Rule: r.Name, Emplid: s.Emplid, CampusID: s.CampusID,
+				Component: r.Target, Old: old, New: v, Detail: detail,
+			})
+		}
+		if len(audit.Changes) == n {
+			continue
+		}
+		changed++
+
+		total, preCompre, detail := s.Total, s.PreCompre, ""
+		switch rs.Total {
+		case TotalSum:
+			for _, c := range audit.Changes[n:] {
+				if c.Component == FieldTotal || c.Component == FieldPreCompre {
+					continue
+				}
+				total += c.New - c.Old
+				if c.Component != FieldCompre && hasPreCompre {
+					preCompre += c.New - c.Old
+				}
+			}
+			detail = "recorded total plus the changes"
+		case TotalWeights:
+			total = weights.recompute(*s)
+			if weights.TotalMax > 0 {
+				total = total / weights.Scale * weights.TotalMax
+			}
+			detail = "weighted total"
+		}
+		for _, t := range []struct {
+			field    string
+			old, new float64
+		}{{FieldPreCompre, before.PreCompre, preCompre}, {FieldTotal, before.Total, total}} {
+			if math.Abs(t.new-t.old) > markEpsilon && !changedBy(audit.Changes[n:], t.field) {
+				s.setComponent(t.field, t.new)
+				audit.Changes = append(audit.Changes, RuleChange{
+					Rule: "recompute " + t.field, Emplid: s.Emplid, CampusID: s.CampusID,
+					Component: t.field, Old: t.old, New: t.new, Detail: detail,
+				})
+			}
+		}
+	}
+	audit.Students = changed
+	return audit, nil
+}
+
+func changedBy(changes []RuleChange, component string) bool {
+	for _, c := range changes {
+		if c.Component == component {
+			return true
+		}
+	}
+	return false
+}
+
+// apply works out the rule's value for s, with ok false when the rule
+// does not apply to this student.
+func (r Rule) apply(s Student, rs RuleSet) (float64, string, bool) {
+	current, _ := s.component(r.Target)
+	switch r.Type {
+	case RuleReplace:
+		v, ok := rs.source(s, r.Source)
+		if !ok {
+			return 0, "", false
+		}
+		m, special := s.Special[r.Target]
+		switch r.When {
+		case WhenSpecial:
+			ok = special
+		case WhenAbsent:
+			ok = special && m.State == MarkAbsent
+		case WhenHigher:
+			ok = v > current
+		}
+		if !ok {
+			return 0, "", false
+		}
+		detail := fmt.Sprintf("%s %s", r.Source, FormatCell(v))
+		if special {
+			detail = fmt.Sprintf("%s was %s; %s", r.Target, markLabel(m), detail)
+		}
+		return v, detail, true
+
+	case RuleBest:
+		best, name, parts := math.Inf(-1), "", []string{}
+		for _, src := range r.Sources {
+			if v, ok := rs.source(s, src); ok {
+				parts = append(parts, fmt.Sprintf("%s %s", src, FormatCell(v)))
+				if v > best {
+					best, name = v, src
+				}
+			}
+		}
+		if name == "" {
+			return 0, "", false
+		}
+		return best, fmt.Sprintf("best of %s: %s", strings.Join(parts, ", "), name), true
+
+	default:
+		type value struct {
+			name string
+			v    float64
+		}
+		var values []value
+		for _, src := range r.Sources {
+			if v, ok := rs.source(s, src); ok {
+				values = append(values, value{src, v})
+			}
+		}
+		if len(values) == 0 {
+			return 0, "", false
+		}
+		// A missing score counts as the lowest, so it is dropped first.
+		missing := len(r.Sources) - len(values)
+		sort.SliceStable(values, func(i, j int) bool { return values[i].v < values[j].v })
+		drop := r.Drop - missing
+		if drop < 0 {
+			drop = 0
+		}
+		if drop > len(values) {
+			drop = len(values)
+		}
+		var sum float64
+		var dropped []string
+		for i, v := range values {
+			if i < drop {
+				dropped = append(dropped, fmt.Sprintf("%s %s", v.name, FormatCell(v.v)))
+				continue
+			}
+			sum += v.v
+		}
+		for i := 0; i < missing && i < r.Drop; i++ {
+			dropped = append(dropped, "a missing score")
+		}
+		detail := "dropped " + strings.Join(dropped, ", ")
+		if r.Scale {
+			sum = sum * float64(len(r.Sources)) / float64(len(r.Sources)-r.Drop)
+			detail += fmt.Sprintf(", scaled to %d scores", len(r.Sources))
+		}
+		return sum, detail, true
+	}
+}
+
+func markLabel(m SpecialMark) string {
+	if m.Marker == "" {
+		return m.State
+	}
+	return fmt.Sprintf("%s (%s)", m.Marker, m.State)
+}
+
+// setComponent stores a score by canonical field or extra component name.
+// The Extra and Special maps are copied first, since Generate's copies of
+// students still share them with the caller's. A special mark that gets a
+// score is recorded as made up.
+func (s *Student) setComponent(name string, v float64) {
+	switch name {
+	case FieldQuiz:
+		s.Quiz = v
+	case FieldMidSem:
+		s.MidSem = v
+	case FieldLabTest:
+		s.LabTest = v
+	case FieldWeeklyLabs:
+		s.WeeklyLabs = v
+	case FieldPreCompre:
+		s.PreCompre = v
+	case FieldCompre:
+		s.Compre = v
+	case FieldTotal:
+		s.Total = v
+	default:
+		extra := make(map[string]float64, len(s.Extra)+1)
+		for k, x := range s.Extra {
+			extra[k] = x
+		}
+		extra[name] = v
+		s.Extra = extra
+	}
+	if m, ok := s.Special[name]; ok {
+		special := make(map[string]SpecialMark, len(s.Special))
+		for k, x := range s.Special {
+			special[k] = x
+		}
+		m.Makeup = &v
+		special[name] = m
+		s.Special = special
+	}
+}
+
+func ruleAuditTable(r *RuleAudit) Table {
+	t := Table{
+		Name:   "rule_changes",
+		Title:  fmt.Sprintf("Rule Changes (%d changes to %d students, totals by %s)", len(r.Changes), r.Students, r.Total),
+		Header: []string{"Emplid", "Campus ID", "Rule", "Component", "Old", "New", "Detail"},
+	}
+	for _, c := range r.Changes {
+		t.Rows = append(t.Rows, []interface{}{c.Emplid, c.CampusID, c.Rule, c.Component, c.Old, c.New, c.Detail})
+	}
+	return t
+}
@@ -0,0 +1,283 @@
+package gradebook
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// ruleStudent was absent for the MidSem and has three quizzes as extra
+// components; Quiz holds their recorded sum.
+func ruleStudent() Student {
+	return Student{
+		Emplid: "1", CampusID: "2022A7PS0001P",
+		Quiz: 10, MidSem: 0, LabTest: 20, WeeklyLabs: 15, PreCompre: 45, Compre: 40, Total: 85,
+		Extra:   map[string]float64{"Q1": 5, "Q2": 8, "Q3": 2},
+		Special: map[string]SpecialMark{FieldMidSem: {Marker: "AB", State: MarkAbsent, Policy: PolicyZero}},
+	}
+}
+
+// ruleScores are the makeup and improvement scores for ruleStudent;
+// nobody has a Quiz 4.
+func ruleScores(rules []Rule, total string) RuleSet {
+	return RuleSet{
+		Rules: rules, Total: total,
+		scores:  map[string]map[string]float64{"1": {"Makeup Mid": 50, "Improvement Compre": 35}},
+		columns: map[string]bool{"Makeup Mid": true, "Improvement Compre": true, "Quiz 4": true},
+	}
+}
+
+func TestApplyRules(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		total   string
+		want    map[string]float64
+		changes int
+		detail  string
+	}{
+		{
+			name:    "makeup for an absentee",
+			rule:    Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup Mid", When: WhenAbsent},
+			want:    map[string]float64{FieldMidSem: 50, FieldPreCompre: 95, FieldTotal: 135},
+			changes: 3,
+			detail:  "MidSem was AB (absent); Makeup Mid 50.00",
+		},
+		{
+			name: "improvement lower than the mark",
+			rule: Rule{Type: RuleReplace, Target: FieldCompre, Source: "Improvement Compre", When: WhenHigher},
+			want: map[string]float64{FieldCompre: 40, FieldTotal: 85},
+		},
+		{
+			name: "target not special",
+			rule: Rule{Type: RuleReplace, Target: FieldQuiz, Source: "Makeup Mid", When: WhenSpecial},
+			want: map[string]float64{FieldQuiz: 10, FieldTotal: 85},
+		},
+		{
+			name:    "Compre leaves PreCompre alone",
+			rule:    Rule{Type: RuleReplace, Target: FieldCompre, Source: "Improvement Compre"},
+			want:    map[string]float64{FieldCompre: 35, FieldPreCompre: 45, FieldTotal: 80},
+			changes: 2,
+			detail:  "Improvement Compre 35.00",
+		},
+		{
+			name:    "best of",
+			rule:    Rule{Type: RuleBest, Target: FieldQuiz, Sources: []string{"Q1", "Q2", "Q3"}},
+			want:    map[string]float64{FieldQuiz: 8, FieldPreCompre: 43, FieldTotal: 83},
+			changes: 3,
+			detail:  "best of Q1 5.00, Q2 8.00, Q3 2.00: Q2",
+		},
+		{
+			name:    "drop the lowest",
+			rule:    Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q2", "Q3"}},
+			want:    map[string]float64{FieldQuiz: 13, FieldPreCompre: 48, FieldTotal: 88},
+			changes: 3,
+			detail:  "dropped Q3 2.00",
+		},
+		{
+			name:    "drop the lowest and scale",
+			rule:    Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q2", "Q3"}, Scale: true},
+			want:    map[string]float64{FieldQuiz: 19.5, FieldPreCompre: 54.5, FieldTotal: 94.5},
+			changes: 3,
+			detail:  "dropped Q3 2.00, scaled to 3 scores",
+		},
+		{
+			name:    "a missing score is dropped first",
+			rule:    Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q3", "Quiz 4"}},
+			want:    map[string]float64{FieldQuiz: 7, FieldPreCompre: 42, FieldTotal: 82},
+			changes: 3,
+			detail:  "dropped a missing score",
+		},
+		{
+			name:    "totals left as recorded",
+			rule:    Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup Mid", When: WhenSpecial},
+			total:   TotalNone,
+			want:    map[string]float64{FieldMidSem: 50, FieldPreCompre: 45, FieldTotal: 85},
+			changes: 1,
+		},
+	}
+	for _, tt := range tests {
+		if err := tt.rule.check(); err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if tt.total == "" {
+			tt.total = TotalSum
+		}
+		student := ruleStudent()
+		special := student.Special
+		students := []Student{student}
+		audit, err := applyRules(students, ruleScores([]Rule{tt.rule}, tt.total), nil)
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		for c, want := range tt.want {
+			if got, _ := students[0].component(c); math.Abs(got-want) > 1e-9 {
+				t.Errorf("%s: %s = %g, want %g", tt.name, c, got, want)
+			}
+		}
+		if len(audit.Changes) != tt.changes {
+			t.Errorf("%s: %d changes, want %d: %+v", tt.name, len(audit.Changes), tt.changes, audit.Changes)
+			continue
+		}
+		if tt.changes > 0 && !strings.Contains(audit.Changes[0].Detail, tt.detail) {
+			t.Errorf("%s: detail %q, want %q", tt.name, audit.Changes[0].Detail, tt.detail)
+		}
+		if special[FieldMidSem].Makeup != nil {
+			t.Errorf("%s: the caller's special marks were changed", tt.name)
+		}
+	}
+}
+
+func TestApplyRulesMakeup(t *testing.T) {
+	students := []Student{ruleStudent()}
+	rule := Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup Mid", When: WhenAbsent}
+	if _, err := applyRules(students, ruleScores([]Rule{rule}, TotalSum), nil); err != nil {
+		t.Fatal(err)
+	}
+	if m := students[0].Special[FieldMidSem]; m.Makeup == nil || *m.Makeup != 50 {
+		t.Errorf("MidSem mark %+v, want made up to 50", m)
+	}
+}
+
+func TestApplyRulesWeights(t *testing.T) {
+	weights := &WeightsConfig{Scale: 100, TotalMax: 200, Components: map[string]ComponentWeight{
+		FieldMidSem: {Weight: 40, Max: 50}, FieldCompre: {Weight: 60, Max: 60},
+	}}
+	students := []Student{ruleStudent()}
+	rule := Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup Mid", When: WhenAbsent}
+	audit, err := applyRules(students, ruleScores([]Rule{rule}, TotalWeights), weights)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 40 for the MidSem and 40 of 60 for Compre, doubled to a total of 200.
+	if students[0].Total != 160 || audit.Students != 1 {
+		t.Errorf("total %g for %d students, want 160 for 1", students[0].Total, audit.Students)
+	}
+}
+
+func TestApplyRulesErrors(t *testing.T) {
+	tests := []struct {
+		name  string
+		rules []Rule
+		total string
+		err   string
+	}{
+		{"unknown target", []Rule{{Name: "r", Type: RuleReplace, Target: "Attendance", Source: "Makeup Mid"}}, TotalSum, "unknown target"},
+		{"unknown source", []Rule{{Name: "r", Type: RuleBest, Target: FieldQuiz, Sources: []string{"Q1", "Q9"}}}, TotalSum, `unknown source "Q9"`},
+		{"no weights", []Rule{{Name: "r", Type: RuleReplace, Target: FieldMidSem, Source: "Makeup Mid"}}, TotalWeights, "need weights"},
+	}
+	for _, tt := range tests {
+		_, err := applyRules([]Student{ruleStudent()}, ruleScores(tt.rules, tt.total), nil)
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+		}
+	}
+}
+
+func TestRuleCheck(t *testing.T) {
+	tests := []struct {
+		rule Rule
+		name string
+		err  bool
+	}{
+		{Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup"}, "Makeup replaces MidSem", false},
+		{Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup", When: WhenAbsent}, "Makeup replaces MidSem when absent", false},
+		{Rule{Name: "makeups", Type: RuleReplace, Target: FieldMidSem, Source: "Makeup"}, "makeups", false},
+		{Rule{Type: RuleBest, Target: FieldQuiz, Sources: []string{"Q1", "Q2"}}, "Quiz is the best of Q1, Q2", false},
+		{Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q2"}}, "Quiz drops the lowest 1 of Q1, Q2", false},
+		{Rule{Type: RuleReplace, Source: "Makeup"}, "", true},
+		{Rule{Type: RuleReplace, Target: FieldMidSem}, "", true},
+		{Rule{Type: RuleReplace, Target: FieldMidSem, Source: "Makeup", When: "lower"}, "", true},
+		{Rule{Type: RuleBest, Target: FieldQuiz, Sources: []string{"Q1"}}, "", true},
+		{Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q2"}, Drop: 2}, "", true},
+		{Rule{Type: RuleDropLowest, Target: FieldQuiz, Sources: []string{"Q1", "Q2"}, Drop: -1}, "", true},
+		{Rule{Type: "average", Target: FieldQuiz, Sources: []string{"Q1", "Q2"}}, "", true},
+	}
+	for _, tt := range tests {
+		r := tt.rule
+		err := r.check()
+		if (err != nil) != tt.err {
+			t.Errorf("%+v: error %v", tt.rule, err)
+			continue
+		}
+		if err == nil && r.Name != tt.name {
+			t.Errorf("%+v: named %q, want %q", tt.rule, r.Name, tt.name)
+		}
+	}
+}
+
+func TestLoadRuleSet(t *testing.T) {
+	tests := []struct {
+		name  string
+		yaml  string
+		total string
+		err   string
+	}{
+		{"sum by default", "rules:\n  - {type: best, target: Quiz, sources: [Q1, Q2]}\n", TotalSum, ""},
+		{"weights", "total: weights\nrules:\n  - {type: replace, target: MidSem, source: Makeup}\n", TotalWeights, ""},
+		{"unknown total", "total: mean\nrules:\n  - {type: replace, target: MidSem, source: Makeup}\n", "", "unknown total"},
+		{"no rules", "total: none\n", "", "no rules"},
+		{"bad rule", "rules:\n  - {type: replace, target: MidSem, source: Makeup}\n  - {type: best, target: Quiz}\n", "", "rule 2"},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(t.TempDir(), "rules.yaml")
+		if err := os.WriteFile(path, []byte(tt.yaml), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		rs, err := LoadRuleSet(path)
+		if tt.err != "" {
+			if err == nil || !strings.Contains(err.Error(), tt.err) {
+				t.Errorf("%s: error %v, want %q", tt.name, err, tt.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: %v", tt.name, err)
+			continue
+		}
+		if rs.Total != tt.total || rs.Rules[0].Name == "" {
+			t.Errorf("%s: total %q, first rule %+v", tt.name, rs.Total, rs.Rules[0])
+		}
+	}
+}
+
+func TestLoadScores(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name, content string) string {
+		path := filepath.Join(dir, name)
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		return path
+	}
+	first := write("makeup.csv", "Makeup scores,,,\nEmplid,Campus ID,Makeup Mid,Improvement Compre\n1,2022A7PS0001P,50,AB\n2,2022A7PS0002P,,30\n,,5,5\n")
+	second := write("improvement.csv", "Emplid,Improvement Compre\n2,45\n")
+
+	var rs RuleSet
+	for _, path := range []string{first, second} {
+		if err := rs.LoadScores(path, ReaderOptions{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want := map[string]map[string]float64{"1": {"Makeup Mid": 50}, "2": {"Improvement Compre": 45}}
+	if !reflect.DeepEqual(rs.scores, want) {
+		t.Errorf("scores %v, want %v", rs.scores, want)
+	}
+	if !rs.columns["Makeup Mid"] || !rs.columns["Improvement Compre"] || rs.columns["Campus ID"] {
+		t.Errorf("columns %v", rs.columns)
+	}
+
+	for name, content := range map[string]string{
+		"no_emplid.csv": "Name,Makeup Mid\nA,50\n",
+		"malformed.csv": "Emplid,Makeup Mid\n1,fifty\n",
+	} {
+		var rs RuleSet
+		if err := rs.LoadScores(write(name, content), ReaderOptions{}); err == nil {
+			t.Errorf("%s loaded", name)
+		}
+	}
+}
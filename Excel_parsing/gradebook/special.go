@@ -0,0 +1,243 @@
+package gradebook
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// States a mark can be in when the cell holds a marker instead of a
+// number.
+const (
+	MarkAbsent        = "absent"
+	MarkNotApplicable = "not_applicable"
+	MarkIncomplete    = "incomplete"
+	MarkBlank         = "blank"
+)
+
+var MarkStates = []string{MarkAbsent, MarkNotApplicable, MarkIncomplete, MarkBlank}
+
+// What to do with a special mark: count it as zero, leave it out of the
+// component's statistics, or use the student's makeup exam score.
+const (
+	PolicyZero    = "zero"
+	PolicyExclude = "exclude"
+	PolicyMakeup  = "makeup"
+)
+
+var MarkPolicies = []string{PolicyZero, PolicyExclude, PolicyMakeup}
+
+var defaultMarkers = map[string]string{
+	"AB": MarkAbsent, "ABS": MarkAbsent, "ABSENT": MarkAbsent,
+	"NA": MarkNotApplicable, "N/A": MarkNotApplicable, "-": MarkNotApplicable, "--": MarkNotApplicable,
+	"I": MarkIncomplete, "INC": MarkIncomplete, "INCOMPLETE": MarkIncomplete,
+}
+
+var defaultMarkPolicy = map[string]string{
+	MarkAbsent:        PolicyZero,
+	MarkNotApplicable: PolicyExclude,
+	MarkIncomplete:    PolicyExclude,
+	MarkBlank:         PolicyZero,
+}
+
+// SpecialMarkConfig is the special_marks section of the column config.
+// Markers adds cell texts (matched ignoring case) to the built-in AB, NA,
+// -, I and friends, Policy overrides the policy per state, and Makeup
+// names the makeup exam column for each component. A makeup policy falls
+// back to zero for students with no makeup score.
+type SpecialMarkConfig struct {
+	Markers map[string]string `json:"markers" yaml:"markers"`
+	Policy  map[string]string `json:"policy" yaml:"policy"`
+	Makeup  map[string]string `json:"makeup" yaml:"makeup"`
+}
+
+// SpecialMark records a cell that held a marker, or nothing, instead of
+// a number. Policy is what was done about it; Makeup is set when the
+// makeup score was used.
+type SpecialMark struct {
+	Marker string   `json:"marker,omitempty"`
+	State  string   `json:"state"`
+	Policy string   `json:"policy"`
+	Makeup *float64 `json:"makeup,omitempty"`
+}
+
+func (c SpecialMarkConfig) check() error {
+	for marker, state := range c.Markers {
+		if !contains(MarkStates, state) || state == MarkBlank {
+			return fmt.Errorf("marker %q: unknown state %q (want %s)", marker, state,
+				strings.Join(MarkStates[:len(MarkStates)-1], ", "))
+		}
+	}
+	for state, policy := range c.Policy {
+		if err := c.checkPolicy(state, policy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c SpecialMarkConfig) checkPolicy(state, policy string) error {
+	if !contains(MarkStates, state) {
+		return fmt.Errorf("unknown mark state %q (want %s)", state, strings.Join(MarkStates, ", "))
+	}
+	if !contains(MarkPolicies, policy) {
+		return fmt.Errorf("unknown policy %q for %s marks (want %s)", policy, state, strings.Join(MarkPolicies, ", "))
+	}
+	if policy == PolicyMakeup && len(c.Makeup) == 0 {
+		return fmt.Errorf("the makeup policy for %s marks needs makeup columns", state)
+	}
+	return nil
+}
+
+// SetPolicy applies a "state=policy" setting such as "absent=makeup".
+func (c *SpecialMarkConfig) SetPolicy(spec string) error {
+	state, policy, ok := strings.Cut(spec, "=")
+	if !ok {
+		return fmt.Errorf("mark policy %q is not state=policy", spec)
+	}
+	state, policy = strings.TrimSpace(state), strings.ToLower(strings.TrimSpace(policy))
+	if err := c.checkPolicy(state, policy); err != nil {
+		return err
+	}
+	if c.Policy == nil {
+		c.Policy = make(map[string]string)
+	}
+	c.Policy[state] = policy
+	return nil
+}
+
+// state returns the state of a marker cell, with ok false for anything
+// that should be read as a number.
+func (c SpecialMarkConfig) state(value string) (string, bool) {
+	if value == "" {
+		return MarkBlank, true
+	}
+	key := strings.ToUpper(value)
+	for marker, state := range c.Markers {
+		if strings.ToUpper(strings.TrimSpace(marker)) == key {
+			return state, true
+		}
+	}
+	state, ok := defaultMarkers[key]
+	return state, ok
+}
+
+func (c SpecialMarkConfig) policy(state string) string {
+	if p, ok := c.Policy[state]; ok {
+		return p
+	}
+	return defaultMarkPolicy[state]
+}
+
+// SpecialMarkReport counts special marks per component and, in the
+// Students row, the students with at least one of each. Absentees lists
+// the Emplids absent from at least one component.
+type SpecialMarkReport struct {
+	Components []SpecialMarkCount `json:"components"`
+	Students   SpecialMarkCount   `json:"students"`
+	Absentees  []string           `json:"absentees"`
+}
+
+type SpecialMarkCount struct {
+	Component     string `json:"component,omitempty"`
+	Absent        int    `json:"absent"`
+	NotApplicable int    `json:"not_applicable"`
+	Incomplete    int    `json:"incomplete"`
+	Blank         int    `json:"blank"`
+	Zeroed        int    `json:"zeroed"`
+	Excluded      int    `json:"excluded"`
+	Makeup        int    `json:"makeup"`
+}
+
+func (c *SpecialMarkCount) add(m SpecialMark) {
+	switch m.State {
+	case MarkAbsent:
+		c.Absent++
+	case MarkNotApplicable:
+		c.NotApplicable++
+	case MarkIncomplete:
+		c.Incomplete++
+	case MarkBlank:
+		c.Blank++
+	}
+	switch {
+	case m.Makeup != nil:
+		c.Makeup++
+	case m.Policy == PolicyExclude:
+		c.Excluded++
+	default:
+		c.Zeroed++
+	}
+}
+
+// SpecialMarks counts the special marks on students, or returns nil when
+// there are none.
+func SpecialMarks(students []Student) *SpecialMarkReport {
+	byComponent := make(map[string]*SpecialMarkCount)
+	report := &SpecialMarkReport{Components: []SpecialMarkCount{}, Absentees: []string{}}
+	for _, s := range students {
+		if len(s.Special) == 0 {
+			continue
+		}
+		// Each state and policy counts once per student in the Students row.
+		var seen SpecialMarkCount
+		for name, m := range s.Special {
+			c, ok := byComponent[name]
+			if !ok {
+				c = &SpecialMarkCount{Component: name}
+				byComponent[name] = c
+			}
+			c.add(m)
+			seen.add(m)
+		}
+		once := func(n int) int {
+			if n > 0 {
+				return 1
+			}
+			return 0
+		}
+		st := &report.Students
+		st.Absent += once(seen.Absent)
+		st.NotApplicable += once(seen.NotApplicable)
+		st.Incomplete += once(seen.Incomplete)
+		st.Blank += once(seen.Blank)
+		st.Zeroed += once(seen.Zeroed)
+		st.Excluded += once(seen.Excluded)
+		st.Makeup += once(seen.Makeup)
+		if seen.Absent > 0 {
+			report.Absentees = append(report.Absentees, s.Emplid)
+		}
+	}
+	if len(byComponent) == 0 {
+		return nil
+	}
+	order := statComponents(students)
+	for name := range byComponent {
+		if !contains(order, name) {
+			order = append(order, name)
+		}
+	}
+	for _, name := range order {
+		if c, ok := byComponent[name]; ok {
+			report.Components = append(report.Components, *c)
+		}
+	}
+	sort.Strings(report.Absentees)
+	return report
+}
+
+func specialMarkTable(r *SpecialMarkReport) Table {
+	t := Table{
+		Name:   "special_marks",
+		Title:  fmt.Sprintf("Special Marks (%d absentees)", len(r.Absentees)),
+		Header: []string{"Component", "Absent", "Not Applicable", "Incomplete", "Blank", "Zeroed", "Excluded", "Makeup"},
+	}
+	row := func(label string, c SpecialMarkCount) []interface{} {
+		return []interface{}{label, c.Absent, c.NotApplicable, c.Incomplete, c.Blank, c.Zeroed, c.Excluded, c.Makeup}
+	}
+	for _, c := range r.Components {
+		t.Rows = append(t.Rows, row(c.Component, c))
+	}
+	t.Rows = append(t.Rows, row("Students", r.Students))
+	return t
+}
@@ -0,0 +1,171 @@
+package gradebook
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// specialMarks has student 2 with NA for Mid-Sem and a makeup of 45, and
+// student 5 with an incomplete Total.
+const specialMarks = `Class No.,Emplid,Campus ID,Mid-Sem,Compre,Total,Makeup Mid
+1,1,2022A7PS0001P,60,50,110,
+1,2,2022A7PS0002P,NA,40,40,45
+1,3,2022A7PS0003P,30,30,60,
+2,4,2022A7PS0004P,50,20,70,
+2,5,2022A7PS0005P,20,10,I,
+`
+
+func loadSpecialMarks(t *testing.T, policies ...string) []Student {
+	t.Helper()
+	cfg := ColumnConfig{SpecialMarks: SpecialMarkConfig{Makeup: map[string]string{FieldMidSem: "Makeup Mid"}}}
+	for _, p := range policies {
+		if err := cfg.SpecialMarks.SetPolicy(p); err != nil {
+			t.Fatal(err)
+		}
+	}
+	students, v, err := Load(strings.NewReader(specialMarks), "marks.csv", LoadOptions{Columns: cfg})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(students) != 5 {
+		t.Fatalf("loaded %d students (quarantined %+v), want 5", len(students), v.Quarantined)
+	}
+	return students
+}
+
+func TestRankBySpecialMarks(t *testing.T) {
+	tests := []struct {
+		policy   string
+		order    []string
+		unranked string
+	}{
+		{"not_applicable=zero", []string{"1", "4", "3", "5", "2"}, ""},
+		{"not_applicable=exclude", []string{"1", "4", "3", "5", "2"}, "2"},
+		{"not_applicable=makeup", []string{"1", "4", "2", "3", "5"}, ""},
+	}
+	for _, tt := range tests {
+		students := loadSpecialMarks(t, tt.policy)
+		opts := DefaultRankOptions()
+		opts.By = FieldMidSem
+		if err := opts.compile(students); err != nil {
+			t.Fatal(err)
+		}
+		ranked := rankStudents(students, opts)
+		var order []string
+		for i, r := range ranked {
+			order = append(order, r.Emplid)
+			if r.Unranked != (r.Emplid == tt.unranked) {
+				t.Errorf("%s: student %s unranked %v", tt.policy, r.Emplid, r.Unranked)
+			}
+			if r.Unranked {
+				if r.Rank != 0 || i != len(ranked)-1 {
+					t.Errorf("%s: unranked student has rank %d at %d, want 0 and last", tt.policy, r.Rank, i)
+				}
+			} else if r.Rank != i+1 {
+				t.Errorf("%s: student %s ranked %d, want %d", tt.policy, r.Emplid, r.Rank, i+1)
+			}
+		}
+		if !reflect.DeepEqual(order, tt.order) {
+			t.Errorf("%s: ranked %v, want %v", tt.policy, order, tt.order)
+		}
+		// Percentiles run over the students ranked.
+		if tt.unranked != "" && ranked[len(ranked)-2].Percentile != 50.0/4 {
+			t.Errorf("%s: last ranked at percentile %g, want 12.5", tt.policy, ranked[len(ranked)-2].Percentile)
+		}
+
+		opts.Top = 2
+		top := topStudents(students, opts)
+		if len(top) != 2 || top[0].Emplid != "1" || top[1].Emplid != "4" {
+			t.Errorf("%s: top 2 %+v, want 1 and 4", tt.policy, top)
+		}
+	}
+}
+
+func TestGradeSpecialMarks(t *testing.T) {
+	tests := []struct {
+		policy   string
+		ungraded int
+		average  float64
+		cutoffs  int
+	}{
+		{"incomplete=zero", 0, 280.0 / 5, 5},
+		{"incomplete=exclude", 1, 280.0 / 4, 4},
+	}
+	for _, tt := range tests {
+		students := loadSpecialMarks(t, tt.policy)
+		summary := assignGrades(students, GradingConfig{Scheme: SchemePercentile}, 0, FieldTotal)
+		if summary.Ungraded != tt.ungraded {
+			t.Errorf("%s: %d ungraded, want %d", tt.policy, summary.Ungraded, tt.ungraded)
+		}
+		if graded := students[4].Grade != ""; graded != (tt.ungraded == 0) {
+			t.Errorf("%s: student 5 graded %q", tt.policy, students[4].Grade)
+		}
+		var count int
+		for _, n := range summary.Distribution {
+			count += n
+		}
+		if count != 5-tt.ungraded {
+			t.Errorf("%s: %d students in the distribution, want %d", tt.policy, count, 5-tt.ungraded)
+		}
+
+		report, err := normalize(students, NormalizeOptions{Method: NormalizeMeanSD})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if (students[4].NormalizedTotal == nil) != (tt.ungraded == 1) {
+			t.Errorf("%s: student 5 NormalizedTotal %v", tt.policy, students[4].NormalizedTotal)
+		}
+		if section := report.Sections[1]; section.Count != 2-tt.ungraded {
+			t.Errorf("%s: section 2 normalized over %d students, want %d", tt.policy, section.Count, 2-tt.ungraded)
+		}
+
+		suggestion, err := SuggestCutoffs(students, BreakOptions{Method: BreaksGap, Grades: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		var suggested int
+		for _, c := range suggestion.Cutoffs {
+			suggested += c.Count
+		}
+		if suggested != tt.cutoffs {
+			t.Errorf("%s: cutoffs split %d students, want %d", tt.policy, suggested, tt.cutoffs)
+		}
+
+		if got := GeneralAverages(students)["Total"]; got != tt.average {
+			t.Errorf("%s: average Total %g, want %g", tt.policy, got, tt.average)
+		}
+		if got := BranchWiseAverages(students)["Computer Science"]; got != tt.average {
+			t.Errorf("%s: Computer Science average %g, want %g", tt.policy, got, tt.average)
+		}
+	}
+}
+
+func TestScoreMissing(t *testing.T) {
+	students := loadSpecialMarks(t, "not_applicable=exclude")
+	tests := []struct {
+		expr    string
+		missing []bool
+	}{
+		{"Compre", []bool{false, false, false, false, false}},
+		{"MidSem + Compre", []bool{false, true, false, false, false}},
+		{"-MidSem", []bool{false, true, false, false, false}},
+		{"Total / 2", []bool{false, false, false, false, true}},
+		{"(MidSem + Total) * 2", []bool{false, true, false, false, true}},
+		{"Compre / (Compre - Compre)", []bool{true, true, true, true, true}},
+		{"(MidSem + Total) / 0", []bool{true, true, true, true, true}},
+	}
+	for _, tt := range tests {
+		e, err := compileScoreExpr(tt.expr, statComponents(students))
+		if err != nil {
+			t.Fatalf("%s: %v", tt.expr, err)
+		}
+		var missing []bool
+		for _, s := range students {
+			missing = append(missing, scoreMissing(e, s))
+		}
+		if !reflect.DeepEqual(missing, tt.missing) {
+			t.Errorf("%s: missing %v, want %v", tt.expr, missing, tt.missing)
+		}
+	}
+}
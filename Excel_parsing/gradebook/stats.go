@@ -0,0 +1,309 @@
+package gradebook
+
+import (
+	"math"
+	"sort"
+)
+
+// Stats are the descriptive statistics of one component over a group of
+// students. StdDev is the sample standard deviation and Skewness the
+// adjusted Fisher-Pearson coefficient; both are zero when there are too
+// few values to compute them. Mode is empty when no value repeats.
+type Stats struct {
+	Count    int       `json:"count"`
+	Mean     float64   `json:"mean"`
+	Median   float64   `json:"median"`
+	Mode     []float64 `json:"mode"`
+	StdDev   float64   `json:"std_dev"`
+	Min      float64   `json:"min"`
+	Max      float64   `json:"max"`
+	Q1       float64   `json:"q1"`
+	Q3       float64   `json:"q3"`
+	IQR      float64   `json:"iqr"`
+	Skewness float64   `json:"skewness"`
+}
+
+// StatisticsReport holds Stats per component, overall and per group.
+type StatisticsReport struct {
+	Overall   map[string]Stats            `json:"overall"`
+	ByBranch  map[string]map[string]Stats `json:"by_branch"`
+	BySection map[string]map[string]Stats `json:"by_section"`
+}
+
+// describe computes Stats from the values of one group.
+func describe(values []float64) Stats {
+	var a statsAccumulator
+	for _, v := range values {
+		a.add(v)
+	}
+	return a.stats()
+}
+
+// statsAccumulator gathers one group's Stats in a single pass: the count,
+// min, max and moments are kept online by running, and values are only
+// buffered because the median, quartiles and modes need all of them.
+type statsAccumulator struct {
+	r        running
+	min, max float64
+	values   []float64
+}
+
+func (a *statsAccumulator) add(v float64) {
+	if a.r.n == 0 || v < a.min {
+		a.min = v
+	}
+	if a.r.n == 0 || v > a.max {
+		a.max = v
+	}
+	a.r.add(v)
+	a.values = append(a.values, v)
+}
+
+// stats sorts the buffered values in place for the order statistics.
+func (a *statsAccumulator) stats() Stats {
+	s := Stats{Count: a.r.n, Mode: []float64{}}
+	if a.r.n == 0 {
+		return s
+	}
+	s.Mean = a.r.mean
+	s.StdDev = a.r.sampleSD()
+	s.Skewness = a.r.skewness()
+	s.Min, s.Max = a.min, a.max
+
+	sort.Float64s(a.values)
+	s.Median = quantile(a.values, 0.5)
+	s.Q1 = quantile(a.values, 0.25)
+	s.Q3 = quantile(a.values, 0.75)
+	s.IQR = s.Q3 - s.Q1
+	s.Mode = modes(a.values)
+	return s
+}
+
+// running accumulates the count, mean and second and third central moments
+// of a stream of values in one pass (Welford's method, extended to the
+// third moment), without keeping the values.
+type running struct {
+	n      int
+	mean   float64
+	m2, m3 float64
+}
+
+func (r *running) add(x float64) {
+	n1 := float64(r.n)
+	r.n++
+	n := float64(r.n)
+	delta := x - r.mean
+	deltaN := delta / n
+	term := delta * deltaN * n1
+	r.mean += deltaN
+	r.m3 += term*deltaN*(n-2) - 3*deltaN*r.m2
+	r.m2 += term
+}
+
+// populationSD divides by n, sampleSD by n-1.
+func (r *running) populationSD() float64 {
+	if r.n == 0 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.n))
+}
+
+func (r *running) sampleSD() float64 {
+	if r.n < 2 {
+		return 0
+	}
+	return math.Sqrt(r.m2 / float64(r.n-1))
+}
+
+// skewness is the adjusted Fisher-Pearson coefficient.
+func (r *running) skewness() float64 {
+	if r.n < 3 || r.m2 <= 0 {
+		return 0
+	}
+	n := float64(r.n)
+	g1 := (r.m3 / n) / math.Pow(r.m2/n, 1.5)
+	return g1 * math.Sqrt(n*(n-1)) / (n - 2)
+}
+
+// quantile interpolates linearly between the closest ranks of sorted.
+func quantile(sorted []float64, q float64) float64 {
+	if len(sorted) == 0 {
+		return 0
+	}
+	pos := q * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (pos-float64(lo))*(sorted[hi]-sorted[lo])
+}
+
+func modes(sorted []float64) []float64 {
+	best, run := 1, 1
+	var out []float64
+	for i := 1; i <= len(sorted); i++ {
+		if i < len(sorted) && sorted[i] == sorted[i-1] {
+			run++
+			continue
+		}
+		switch {
+		case run > best:
+			best, out = run, []float64{sorted[i-1]}
+		case run == best && best > 1:
+			out = append(out, sorted[i-1])
+		}
+		run = 1
+	}
+	if out == nil {
+		return []float64{}
+	}
+	return out
+}
+
+func meanSD(values []float64) (mean, sd float64) {
+	var r running
+	for _, v := range values {
+		r.add(v)
+	}
+	return r.mean, r.populationSD()
+}
+
+// statComponents lists the canonical numeric fields, the recomputed and
+// normalized totals when there are any, and any extra components present.
+func statComponents(students []Student) []string {
+	names := append([]string(nil), numericFields...)
+	recomputed, normalized := false, false
+	for _, s := range students {
+		recomputed = recomputed || s.RecomputedTotal != nil
+		normalized = normalized || s.NormalizedTotal != nil
+	}
+	if recomputed {
+		names = append(names, FieldRecomputedTotal)
+	}
+	if normalized {
+		names = append(names, FieldNormalizedTotal)
+	}
+	return append(names, extraNames(students)...)
+}
+
+// ComponentStatistics describes every component overall, per branch and
+// per section. Students are visited once, adding each value to all the
+// groups it belongs to; the count, mean, SD, skewness, min and max are
+// accumulated as it goes. The median, quartiles and modes need every
+// value, so each group still buffers one float64 per student and
+// component for them: overall, the student's section and each of their
+// branches.
+func ComponentStatistics(students []Student) StatisticsReport {
+	components := statComponents(students)
+	type groupStats map[string]*statsAccumulator
+	newGroup := func() groupStats {
+		g := make(groupStats, len(components))
+		for _, c := range components {
+			g[c] = &statsAccumulator{}
+		}
+		return g
+	}
+	overall := newGroup()
+	byBranch := make(map[string]groupStats)
+	bySection := make(map[string]groupStats)
+	group := func(groups map[string]groupStats, key string) groupStats {
+		g, ok := groups[key]
+		if !ok {
+			g = newGroup()
+			groups[key] = g
+		}
+		return g
+	}
+	for _, s := range students {
+		branches := make([]groupStats, 0, 2)
+		for _, b := range studentBranches(s) {
+			branches = append(branches, group(byBranch, b))
+		}
+		section := group(bySection, s.ClassNo)
+		for _, c := range components {
+			v, ok := s.Component(c)
+			if !ok {
+				continue
+			}
+			overall[c].add(v)
+			section[c].add(v)
+			for _, g := range branches {
+				g[c].add(v)
+			}
+		}
+	}
+
+	describeAll := func(g groupStats) map[string]Stats {
+		out := make(map[string]Stats, len(components))
+		for _, c := range components {
+			out[c] = g[c].stats()
+		}
+		return out
+	}
+	report := StatisticsReport{
+		Overall:   describeAll(overall),
+		ByBranch:  make(map[string]map[string]Stats),
+		BySection: make(map[string]map[string]Stats),
+	}
+	for b, g := range byBranch {
+		report.ByBranch[b] = describeAll(g)
+	}
+	for c, g := range bySection {
+		report.BySection[c] = describeAll(g)
+	}
+	return report
+}
+
+var statsHeader = []string{"Component", "N", "Mean", "Median", "Mode", "SD", "Min", "Q1", "Q3", "Max", "IQR", "Skew"}
+
+func statisticsTables(r *StatisticsReport) []Table {
+	row := func(label string, s Stats) []interface{} {
+		mode := "-"
+		if len(s.Mode) > 0 {
+			mode = FormatCell(s.Mode[0])
+			if len(s.Mode) > 1 {
+				mode += "+"
+			}
+		}
+		return []interface{}{label, s.Count, s.Mean, s.Median, mode, s.StdDev, s.Min, s.Q1, s.Q3, s.Max, s.IQR, s.Skewness}
+	}
+	componentOrder := func(m map[string]Stats) []string {
+		var names []string
+		for _, f := range append(append([]string(nil), numericFields...), FieldRecomputedTotal, FieldNormalizedTotal) {
+			if _, ok := m[f]; ok {
+				names = append(names, f)
+			}
+		}
+		var extras []string
+		for name := range m {
+			if !contains(numericFields, name) && name != FieldRecomputedTotal && name != FieldNormalizedTotal {
+				extras = append(extras, name)
+			}
+		}
+		sort.Strings(extras)
+		return append(names, extras...)
+	}
+
+	overall := Table{Name: "statistics_overall", Title: "Statistics: Overall", Header: statsHeader}
+	for _, c := range componentOrder(r.Overall) {
+		overall.Rows = append(overall.Rows, row(c, r.Overall[c]))
+	}
+	tables := []Table{overall}
+
+	grouped := func(name, title string, groups map[string]map[string]Stats) Table {
+		t := Table{Name: name, Title: title, Header: append([]string{"Group"}, statsHeader...)}
+		var keys []string
+		for k := range groups {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, c := range componentOrder(groups[k]) {
+				t.Rows = append(t.Rows, append([]interface{}{k}, row(c, groups[k][c])...))
+			}
+		}
+		return t
+	}
+	tables = append(tables,
+		grouped("statistics_by_branch", "Statistics by Branch", r.ByBranch),
+		grouped("statistics_by_section", "Statistics by Section", r.BySection))
+	return tables
+}
@@ -0,0 +1,153 @@
+package gradebook
+
+import (
+	"math"
+	"reflect"
+	"testing"
+)
+
+// twoPass computes the mean, sample SD and adjusted skewness the textbook
+// way, for checking the one-pass accumulator.
+func twoPass(values []float64) (mean, sd, skew float64) {
+	n := float64(len(values))
+	for _, v := range values {
+		mean += v
+	}
+	mean /= n
+	var m2, m3 float64
+	for _, v := range values {
+		d := v - mean
+		m2 += d * d
+		m3 += d * d * d
+	}
+	if n > 1 {
+		sd = math.Sqrt(m2 / (n - 1))
+	}
+	if n > 2 && m2 > 0 {
+		g1 := (m3 / n) / math.Pow(m2/n, 1.5)
+		skew = g1 * math.Sqrt(n*(n-1)) / (n - 2)
+	}
+	return mean, sd, skew
+}
+
+func TestRunningMoments(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+	}{
+		{"single", []float64{42}},
+		{"pair", []float64{1, 3}},
+		{"symmetric", []float64{1, 2, 3, 4, 5}},
+		{"right skewed", []float64{1, 1, 1, 2, 2, 3, 10}},
+		{"left skewed", []float64{-10, 1, 2, 2, 3, 3, 3}},
+		{"constant", []float64{7, 7, 7, 7}},
+		{"large offset", []float64{1e6 + 1, 1e6 + 2, 1e6 + 4, 1e6 + 8}},
+		{"marks", componentValues(syntheticStudents(500), FieldTotal)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var r running
+			for _, v := range tt.values {
+				r.add(v)
+			}
+			mean, sd, skew := twoPass(tt.values)
+			for _, c := range []struct {
+				what      string
+				got, want float64
+			}{
+				{"mean", r.mean, mean},
+				{"sample SD", r.sampleSD(), sd},
+				{"skewness", r.skewness(), skew},
+			} {
+				if math.Abs(c.got-c.want) > 1e-9*math.Max(1, math.Abs(c.want)) {
+					t.Errorf("%s = %.12g, want %.12g", c.what, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDescribe(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []float64
+		want   Stats
+	}{
+		{"empty", nil, Stats{Mode: []float64{}}},
+		{"one", []float64{5}, Stats{Count: 1, Mean: 5, Median: 5, Mode: []float64{}, Min: 5, Max: 5, Q1: 5, Q3: 5}},
+		{"unsorted with a mode", []float64{4, 1, 3, 3, 9}, Stats{
+			Count: 5, Mean: 4, Median: 3, Mode: []float64{3}, StdDev: math.Sqrt(9),
+			Min: 1, Max: 9, Q1: 3, Q3: 4, IQR: 1,
+		}},
+		{"two modes", []float64{2, 2, 5, 5, 8}, Stats{
+			Count: 5, Mean: 4.4, Median: 5, Mode: []float64{2, 5}, StdDev: math.Sqrt(6.3),
+			Min: 2, Max: 8, Q1: 2, Q3: 5, IQR: 3,
+		}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := describe(tt.values)
+			_, _, tt.want.Skewness = twoPass(tt.values)
+			if len(tt.values) == 0 {
+				tt.want.Skewness = 0
+			}
+			if math.Abs(got.StdDev-tt.want.StdDev) < 1e-12 {
+				got.StdDev = tt.want.StdDev
+			}
+			if math.Abs(got.Skewness-tt.want.Skewness) < 1e-12 {
+				got.Skewness = tt.want.Skewness
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("describe(%v) = %+v, want %+v", tt.values, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestQuantile(t *testing.T) {
+	sorted := []float64{10, 20, 30, 40}
+	for _, tt := range []struct{ q, want float64 }{
+		{0, 10}, {1, 40}, {0.5, 25}, {0.25, 17.5}, {1.0 / 3, 20},
+	} {
+		if got := quantile(sorted, tt.q); math.Abs(got-tt.want) > 1e-12 {
+			t.Errorf("quantile(%g) = %g, want %g", tt.q, got, tt.want)
+		}
+	}
+	if got := quantile(nil, 0.5); got != 0 {
+		t.Errorf("quantile of nothing = %g, want 0", got)
+	}
+}
+
+func TestComponentStatisticsGroups(t *testing.T) {
+	students := []Student{
+		{Emplid: "1", ClassNo: "1", Branches: []string{"A7"}, Total: 10},
+		{Emplid: "2", ClassNo: "1", Branches: []string{"A7", "B3"}, Total: 20},
+		{Emplid: "3", ClassNo: "2", Branches: []string{"B3"}, Total: 60},
+	}
+	r := ComponentStatistics(students)
+	for _, tt := range []struct {
+		name  string
+		stats Stats
+		count int
+		mean  float64
+	}{
+		{"overall", r.Overall[FieldTotal], 3, 30},
+		{"A7", r.ByBranch["A7"][FieldTotal], 2, 15},
+		{"B3 counts the dual-degree student", r.ByBranch["B3"][FieldTotal], 2, 40},
+		{"section 1", r.BySection["1"][FieldTotal], 2, 15},
+		{"section 2", r.BySection["2"][FieldTotal], 1, 60},
+	} {
+		if tt.stats.Count != tt.count || tt.stats.Mean != tt.mean {
+			t.Errorf("%s: %d students averaging %g, want %d averaging %g", tt.name, tt.stats.Count, tt.stats.Mean, tt.count, tt.mean)
+		}
+	}
+}
+
+func BenchmarkComponentStatistics(b *testing.B) {
+	students := syntheticStudents(50000)
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		ComponentStatistics(students)
+	}
+}
@@ -0,0 +1,176 @@
+package gradebook
+
+import (
+	"strconv"
+	"strings"
+)
+
+// Student is one gradebook row. Source (the cleaned path of the workbook),
+// Sheet and Row record where it was read from; Grade, RecomputedTotal,
+// NormalizedTotal, Program and Branches are filled in by the loader and by
+// Generate. Special holds the components whose cell was a marker such as
+// AB or was blank.
+type Student struct {
+	Source     string `json:",omitempty"`
+	Sheet      string `json:",omitempty"`
+	Row        int    `json:",omitempty"`
+	ClassNo    string
+	Emplid     string
+	CampusID   string
+	Quiz       float64
+	MidSem     float64
+	LabTest    float64
+	WeeklyLabs float64
+	PreCompre  float64
+	Compre     float64
+	Total      float64
+	Extra      map[string]float64     `json:",omitempty"`
+	Grade      string                 `json:",omitempty"`
+	Special    map[string]SpecialMark `json:",omitempty"`
+
+	RecomputedTotal *float64     `json:",omitempty"`
+	NormalizedTotal *float64     `json:",omitempty"`
+	Program         *ProgramInfo `json:",omitempty"`
+	Branches        []string     `json:",omitempty"`
+}
+
+// parseStudent reads one data row. Problems with individual cells are
+// returned as issues rather than silently turned into zeroes.
+func parseStudent(sheet string, rowNum int, row []string, cols columnIndex) (Student, []Issue) {
+	var issues []Issue
+	issue := func(field, value, severity, msg string) {
+		issues = append(issues, Issue{
+			Sheet: sheet, Row: rowNum,
+			Column: cols.header(field), Cell: cols.cellName(field, rowNum),
+			Value: value, Severity: severity, Message: msg,
+		})
+	}
+	text := func(field string) string {
+		value := cols.cell(row, field)
+		if value == "" {
+			issue(field, "", SeverityError, "missing value")
+		}
+		return value
+	}
+	var special map[string]SpecialMark
+	number := func(field, value string) float64 {
+		if state, ok := cols.special.state(value); ok {
+			m := SpecialMark{Marker: value, State: state, Policy: cols.special.policy(state)}
+			score := 0.0
+			if m.Policy == PolicyMakeup {
+				v, raw, ok := cols.makeup(row, field)
+				if ok {
+					m.Makeup, score = &v, v
+				} else if _, marker := cols.special.state(raw); !marker {
+					issue(field, raw, SeverityWarning, "malformed makeup score, treated as 0")
+				}
+			}
+			if value == "" {
+				switch {
+				case m.Makeup != nil:
+					issue(field, "", SeverityWarning, "missing value, makeup score used")
+				case m.Policy == PolicyExclude:
+					issue(field, "", SeverityWarning, "missing value, left out of averages")
+				default:
+					issue(field, "", SeverityWarning, "missing value, treated as 0")
+				}
+			}
+			if special == nil {
+				special = make(map[string]SpecialMark)
+			}
+			special[field] = m
+			return score
+		}
+		f, err := parseFloat(value)
+		if err != nil {
+			issue(field, value, SeverityError, "malformed number")
+		}
+		return f
+	}
+	component := func(field string) float64 {
+		if _, ok := cols.Fields[field]; !ok {
+			return 0
+		}
+		return number(field, cols.cell(row, field))
+	}
+
+	student := Student{
+		ClassNo:    cols.cell(row, FieldClassNo),
+		Emplid:     text(FieldEmplid),
+		CampusID:   strings.ToUpper(text(FieldCampusID)),
+		Quiz:       component(FieldQuiz),
+		MidSem:     component(FieldMidSem),
+		LabTest:    component(FieldLabTest),
+		WeeklyLabs: component(FieldWeeklyLabs),
+		PreCompre:  component(FieldPreCompre),
+		Compre:     component(FieldCompre),
+		Total:      component(FieldTotal),
+	}
+	if len(cols.Extra) > 0 {
+		student.Extra = make(map[string]float64, len(cols.Extra))
+		for name, i := range cols.Extra {
+			value := ""
+			if i < len(row) {
+				value = strings.TrimSpace(row[i])
+			}
+			student.Extra[name] = number(name, value)
+		}
+	}
+	student.Special = special
+	return student, issues
+}
+
+// Component returns a score by canonical field name or extra component
+// name. A special mark excluded by policy reports ok false, leaving it out
+// of the component's statistics.
+func (s Student) Component(name string) (float64, bool) {
+	v, ok := s.component(name)
+	if m, special := s.Special[name]; special && m.Policy == PolicyExclude && m.Makeup == nil {
+		return v, false
+	}
+	return v, ok
+}
+
+func (s Student) component(name string) (float64, bool) {
+	switch name {
+	case FieldQuiz:
+		return s.Quiz, true
+	case FieldMidSem:
+		return s.MidSem, true
+	case FieldLabTest:
+		return s.LabTest, true
+	case FieldWeeklyLabs:
+		return s.WeeklyLabs, true
+	case FieldPreCompre:
+		return s.PreCompre, true
+	case FieldCompre:
+		return s.Compre, true
+	case FieldTotal:
+		return s.Total, true
+	case FieldRecomputedTotal:
+		if s.RecomputedTotal == nil {
+			return 0, false
+		}
+		return *s.RecomputedTotal, true
+	case FieldNormalizedTotal:
+		if s.NormalizedTotal == nil {
+			return 0, false
+		}
+		return *s.NormalizedTotal, true
+	}
+	v, ok := s.Extra[name]
+	return v, ok
+}
+
+func parseFloat(s string) (float64, error) {
+	return strconv.ParseFloat(strings.TrimSpace(s), 64)
+}
+
+// branchOf returns the branch code embedded in a campus ID, e.g. "A7PS" for
+// 2023A7PS0001P.
+func branchOf(campusID string) string {
+	if len(campusID) < 8 {
+		return ""
+	}
+	return campusID[4:8]
+}
@@ -0,0 +1,365 @@
+package gradebook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Offering is one course in one term, as loaded from its gradebook.
+type Offering struct {
+	Course   string    `json:"course" yaml:"course"`
+	Term     string    `json:"term" yaml:"term"`
+	File     string    `json:"file" yaml:"file"`
+	Students []Student `json:"-" yaml:"-"`
+}
+
+// offeringName matches gradebook file names such as
+// CSF111_202425_01_GradeBook.xlsx: course, academic year and semester.
+var offeringName = regexp.MustCompile(`^([A-Za-z]+\d+[A-Za-z]?)_(\d{6})_(\d{1,2})`)
+
+// OfferingFromName derives course and term from a gradebook file name.
+// Terms are written as 202425_01 so that they sort chronologically.
+func OfferingFromName(path string) (Offering, error) {
+	m := offeringName.FindStringSubmatch(filepath.Base(path))
+	if m == nil {
+		return Offering{}, fmt.Errorf("%s: cannot tell course and term from the file name (want e.g. CSF111_202425_01_...)", path)
+	}
+	semester, _ := strconv.Atoi(m[3])
+	return Offering{Course: strings.ToUpper(m[1]), Term: fmt.Sprintf("%s_%02d", m[2], semester), File: path}, nil
+}
+
+// LoadOfferings reads a JSON or YAML manifest listing each gradebook's
+// file, course and term, for files not named by the usual convention.
+// Relative paths are taken from the manifest's directory.
+func LoadOfferings(path string) ([]Offering, error) {
+	var offerings []Offering
+	if err := LoadConfigFile(path, &offerings); err != nil {
+		return nil, err
+	}
+	for i, o := range offerings {
+		if o.File == "" || o.Course == "" || o.Term == "" {
+			return nil, fmt.Errorf("%s: entry %d needs file, course and term", path, i+1)
+		}
+		if !filepath.IsAbs(o.File) {
+			offerings[i].File = filepath.Join(filepath.Dir(path), o.File)
+		}
+	}
+	return offerings, nil
+}
+
+// TrackOptions control the longitudinal analysis. Report ranks and grades
+// each offering as for Generate. A student is declining when their
+// percentile falls by at least Decline points per term (10 by default)
+// over at least MinTerms terms (2 by default).
+type TrackOptions struct {
+	Report   Options
+	Decline  float64
+	MinTerms int
+}
+
+// TrackingReport follows students across offerings. Percentiles and
+// z-scores are within each offering, so courses marked on different
+// scales compare.
+type TrackingReport struct {
+	Offerings []OfferingSummary `json:"offerings"`
+	Students  []StudentRecord   `json:"students"`
+	Cohorts   []CohortTerm      `json:"cohorts"`
+	Declining []StudentTrend    `json:"declining"`
+	Decline   float64           `json:"decline"`
+	MinTerms  int               `json:"min_terms"`
+}
+
+type OfferingSummary struct {
+	Course    string  `json:"course"`
+	Term      string  `json:"term"`
+	File      string  `json:"file"`
+	Students  int     `json:"students"`
+	MeanTotal float64 `json:"mean_total"`
+	SDTotal   float64 `json:"sd_total"`
+}
+
+// CourseResult is one student's result in one offering.
+type CourseResult struct {
+	Course     string  `json:"course"`
+	Term       string  `json:"term"`
+	Total      float64 `json:"total"`
+	Rank       int     `json:"rank"`
+	Percentile float64 `json:"percentile"`
+	ZScore     float64 `json:"z_score"`
+	Grade      string  `json:"grade,omitempty"`
+}
+
+// StudentRecord is everything tracked for one Emplid, in term order.
+type StudentRecord struct {
+	Emplid   string         `json:"emplid"`
+	CampusID string         `json:"campus_id"`
+	Cohort   string         `json:"cohort"`
+	Results  []CourseResult `json:"results"`
+	Trend    StudentTrend   `json:"trend"`
+}
+
+// StudentTrend summarises a student's mean percentile per term. Slope is
+// the least-squares change in percentile per term.
+type StudentTrend struct {
+	Emplid          string  `json:"emplid"`
+	CampusID        string  `json:"campus_id"`
+	Cohort          string  `json:"cohort"`
+	Terms           int     `json:"terms"`
+	FirstTerm       string  `json:"first_term"`
+	LastTerm        string  `json:"last_term"`
+	FirstPercentile float64 `json:"first_percentile"`
+	LastPercentile  float64 `json:"last_percentile"`
+	Change          float64 `json:"change"`
+	Slope           float64 `json:"slope"`
+}
+
+// CohortTerm compares an admission-year cohort in one term.
+type CohortTerm struct {
+	Cohort         string  `json:"cohort"`
+	Term           string  `json:"term"`
+	Students       int     `json:"students"`
+	Results        int     `json:"results"`
+	MeanPercentile float64 `json:"mean_percentile"`
+	MeanZScore     float64 `json:"mean_z_score"`
+}
+
+// Track ranks each offering and joins the results by Emplid.
+func Track(offerings []Offering, opts TrackOptions) (TrackingReport, error) {
+	if opts.Decline <= 0 {
+		opts.Decline = 10
+	}
+	if opts.MinTerms <= 0 {
+		opts.MinTerms = 2
+	}
+	reportOpts := opts.Report
+	reportOpts.Rank.Top = 0
+	reportOpts.Charts, reportOpts.Anomalies, reportOpts.Correlations = false, nil, false
+
+	report := TrackingReport{
+		Offerings: []OfferingSummary{}, Students: []StudentRecord{}, Cohorts: []CohortTerm{}, Declining: []StudentTrend{},
+		Decline: opts.Decline, MinTerms: opts.MinTerms,
+	}
+	records := make(map[string]*StudentRecord)
+	for _, o := range offerings {
+		r, err := Generate(o.Students, reportOpts)
+		if err != nil {
+			return report, fmt.Errorf("%s %s: %w", o.Course, o.Term, err)
+		}
+		mean, sd := meanSD(componentValues(o.Students, FieldTotal))
+		report.Offerings = append(report.Offerings, OfferingSummary{
+			Course: o.Course, Term: o.Term, File: filepath.Base(o.File),
+			Students: rankedCount(r.OverallTopStudents), MeanTotal: mean, SDTotal: sd,
+		})
+		for _, rs := range r.OverallTopStudents {
+			if rs.Unranked {
+				continue
+			}
+			rec := records[rs.Emplid]
+			if rec == nil {
+				rec = &StudentRecord{Emplid: rs.Emplid, CampusID: rs.CampusID, Cohort: cohortOf(rs.Student)}
+				records[rs.Emplid] = rec
+			}
+			result := CourseResult{Course: o.Course, Term: o.Term, Total: rs.Total, Rank: rs.Rank, Percentile: rs.Percentile, Grade: rs.Grade}
+			if sd > 0 {
+				result.ZScore = (rs.Total - mean) / sd
+			}
+			rec.Results = append(rec.Results, result)
+		}
+	}
+	sort.SliceStable(report.Offerings, func(i, j int) bool {
+		a, b := report.Offerings[i], report.Offerings[j]
+		if a.Term != b.Term {
+			return a.Term < b.Term
+		}
+		return a.Course < b.Course
+	})
+
+	type cohortKey struct{ cohort, term string }
+	type cohortAcc struct {
+		students      map[string]bool
+		n             int
+		percentile, z float64
+	}
+	cohorts := make(map[cohortKey]*cohortAcc)
+	for _, rec := range records {
+		sort.SliceStable(rec.Results, func(i, j int) bool {
+			a, b := rec.Results[i], rec.Results[j]
+			if a.Term != b.Term {
+				return a.Term < b.Term
+			}
+			return a.Course < b.Course
+		})
+		rec.Trend = trendOf(rec)
+		for _, res := range rec.Results {
+			k := cohortKey{rec.Cohort, res.Term}
+			acc := cohorts[k]
+			if acc == nil {
+				acc = &cohortAcc{students: make(map[string]bool)}
+				cohorts[k] = acc
+			}
+			acc.students[rec.Emplid] = true
+			acc.n++
+			acc.percentile += res.Percentile
+			acc.z += res.ZScore
+		}
+		report.Students = append(report.Students, *rec)
+	}
+	sort.Slice(report.Students, func(i, j int) bool { return report.Students[i].Emplid < report.Students[j].Emplid })
+	for _, rec := range report.Students {
+		if rec.Trend.Terms >= opts.MinTerms && rec.Trend.Slope <= -opts.Decline {
+			report.Declining = append(report.Declining, rec.Trend)
+		}
+	}
+	sort.SliceStable(report.Declining, func(i, j int) bool { return report.Declining[i].Slope < report.Declining[j].Slope })
+	for k, acc := range cohorts {
+		report.Cohorts = append(report.Cohorts, CohortTerm{
+			Cohort: k.cohort, Term: k.term, Students: len(acc.students), Results: acc.n,
+			MeanPercentile: acc.percentile / float64(acc.n), MeanZScore: acc.z / float64(acc.n),
+		})
+	}
+	sort.Slice(report.Cohorts, func(i, j int) bool {
+		a, b := report.Cohorts[i], report.Cohorts[j]
+		if a.Cohort != b.Cohort {
+			return a.Cohort < b.Cohort
+		}
+		return a.Term < b.Term
+	})
+	return report, nil
+}
+
+// cohortOf is the admission year from the campus ID.
+func cohortOf(s Student) string {
+	if s.Program != nil && s.Program.Year != "" {
+		return s.Program.Year
+	}
+	if len(s.CampusID) >= 4 {
+		return s.CampusID[:4]
+	}
+	return ""
+}
+
+// trendOf averages the student's percentiles per term and fits a line
+// through them against the term's position.
+func trendOf(rec *StudentRecord) StudentTrend {
+	t := StudentTrend{Emplid: rec.Emplid, CampusID: rec.CampusID, Cohort: rec.Cohort}
+	var terms []string
+	var points []float64
+	for i := 0; i < len(rec.Results); {
+		j, sum := i, 0.0
+		for ; j < len(rec.Results) && rec.Results[j].Term == rec.Results[i].Term; j++ {
+			sum += rec.Results[j].Percentile
+		}
+		terms = append(terms, rec.Results[i].Term)
+		points = append(points, sum/float64(j-i))
+		i = j
+	}
+	t.Terms = len(terms)
+	if t.Terms == 0 {
+		return t
+	}
+	t.FirstTerm, t.LastTerm = terms[0], terms[len(terms)-1]
+	t.FirstPercentile, t.LastPercentile = points[0], points[len(points)-1]
+	t.Change = t.LastPercentile - t.FirstPercentile
+	if t.Terms > 1 {
+		xs := make([]float64, len(points))
+		for i := range xs {
+			xs[i] = float64(i)
+		}
+		mx, sx := meanSD(xs)
+		my, _ := meanSD(points)
+		var cov float64
+		for i := range xs {
+			cov += (xs[i] - mx) * (points[i] - my)
+		}
+		t.Slope = cov / float64(len(xs)) / (sx * sx)
+	}
+	return t
+}
+
+func trackingTables(r TrackingReport) []Table {
+	offerings := Table{Name: "offerings", Title: "Offerings", Header: []string{"Course", "Term", "File", "Students", "Mean Total", "SD Total"}}
+	for _, o := range r.Offerings {
+		offerings.Rows = append(offerings.Rows, []interface{}{o.Course, o.Term, o.File, o.Students, o.MeanTotal, o.SDTotal})
+	}
+
+	results := Table{Name: "student_results", Title: "Student Results",
+		Header: []string{"Emplid", "Campus ID", "Cohort", "Course", "Term", "Total", "Rank", "Percentile", "Z-Score", "Grade"}}
+	for _, s := range r.Students {
+		for _, res := range s.Results {
+			results.Rows = append(results.Rows, []interface{}{s.Emplid, s.CampusID, s.Cohort, res.Course, res.Term, res.Total, res.Rank, res.Percentile, res.ZScore, res.Grade})
+		}
+	}
+
+	trendHeader := []string{"Emplid", "Campus ID", "Cohort", "Terms", "First Term", "Last Term", "First Percentile", "Last Percentile", "Change", "Slope"}
+	trendRow := func(t StudentTrend) []interface{} {
+		return []interface{}{t.Emplid, t.CampusID, t.Cohort, t.Terms, t.FirstTerm, t.LastTerm, t.FirstPercentile, t.LastPercentile, t.Change, t.Slope}
+	}
+	trends := Table{Name: "student_trends", Title: "Student Trends", Header: trendHeader}
+	for _, s := range r.Students {
+		trends.Rows = append(trends.Rows, trendRow(s.Trend))
+	}
+
+	cohorts := Table{Name: "cohorts", Title: "Cohorts by Admission Year",
+		Header: []string{"Cohort", "Term", "Students", "Results", "Mean Percentile", "Mean Z-Score"}}
+	for _, c := range r.Cohorts {
+		cohorts.Rows = append(cohorts.Rows, []interface{}{c.Cohort, c.Term, c.Students, c.Results, c.MeanPercentile, c.MeanZScore})
+	}
+
+	declining := Table{Name: "declining",
+		Title:  fmt.Sprintf("Declining Students (percentile falling %g+ points per term over %d+ terms)", r.Decline, r.MinTerms),
+		Header: trendHeader}
+	for _, t := range r.Declining {
+		declining.Rows = append(declining.Rows, trendRow(t))
+	}
+	return []Table{offerings, results, trends, cohorts, declining}
+}
+
+// PrintTracking renders the report as plain-text tables on w.
+func PrintTracking(w io.Writer, r TrackingReport) {
+	printTables(w, trackingTables(r))
+}
+
+// ExportTracking writes the report as JSON to output or as CSV, one file
+// per table, into the output directory, and returns where it went. An
+// empty output means standard output for JSON and a tracking_report
+// directory for CSV; "-" means standard output and is refused for CSV.
+func ExportTracking(r TrackingReport, format, output string) (string, error) {
+	switch format {
+	case "json":
+		if output == "" {
+			output = "-"
+		}
+		file, err := createOutput(output)
+		if err != nil {
+			return "", err
+		}
+		defer file.Close()
+		encoder := json.NewEncoder(file)
+		encoder.SetIndent("", "  ")
+		return output, encoder.Encode(r)
+	case "csv":
+		switch output {
+		case "":
+			output = "tracking_report"
+		case "-":
+			return "", fmt.Errorf("csv writes one file per table and cannot go to standard output; give a directory")
+		}
+		if err := os.MkdirAll(output, 0o755); err != nil {
+			return "", err
+		}
+		for _, t := range trackingTables(r) {
+			if err := writeCSVTable(filepath.Join(output, t.Name+".csv"), t); err != nil {
+				return "", err
+			}
+		}
+		return output, nil
+	}
+	return "", fmt.Errorf("unknown tracking format %q (want json or csv)", format)
+}
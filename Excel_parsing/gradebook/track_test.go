@@ -0,0 +1,145 @@
+package gradebook
+
+import (
+	"encoding/json"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestOfferingFromName(t *testing.T) {
+	tests := []struct {
+		path, course, term string
+		err                bool
+	}{
+		{"CSF111_202425_01_GradeBook.xlsx", "CSF111", "202425_01", false},
+		{"sem/bits_f110_202324_2.csv", "", "", true},
+		{"sem/bitsf110_202324_2_marks.csv", "BITSF110", "202324_02", false},
+		{"MATHF112A_202223_1.xlsx", "MATHF112A", "202223_01", false},
+		{"marks.xlsx", "", "", true},
+	}
+	for _, tt := range tests {
+		o, err := OfferingFromName(tt.path)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.path, err, tt.err)
+			continue
+		}
+		if o.Course != tt.course || o.Term != tt.term {
+			t.Errorf("%s: %s %s, want %s %s", tt.path, o.Course, o.Term, tt.course, tt.term)
+		}
+	}
+}
+
+// trackingOfferings has student 3 falling from the top of the class in
+// the first term to the bottom of it in the last.
+func trackingOfferings() []Offering {
+	offering := func(course, term string, totals ...float64) Offering {
+		o := Offering{Course: course, Term: term, File: course + "_" + term + ".xlsx"}
+		for i, total := range totals {
+			o.Students = append(o.Students, Student{
+				Emplid: string(rune('1' + i)), CampusID: []string{"2021A7PS0001P", "2022A7PS0002P", "2021A7PS0003P"}[i], Total: total,
+			})
+		}
+		return o
+	}
+	return []Offering{
+		offering("CSF222", "202324_02", 60, 50, 40),
+		offering("CSF111", "202324_01", 50, 40, 90),
+		offering("CSF211", "202324_02", 50, 60, 40),
+		offering("CSF301", "202425_01", 50, 60, 10),
+	}
+}
+
+func TestTrack(t *testing.T) {
+	r, err := Track(trackingOfferings(), TrackOptions{Report: Options{Rank: DefaultRankOptions()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var order []string
+	for _, o := range r.Offerings {
+		order = append(order, o.Course)
+	}
+	if want := []string{"CSF111", "CSF211", "CSF222", "CSF301"}; !reflect.DeepEqual(order, want) {
+		t.Errorf("offerings in order %v, want %v", order, want)
+	}
+
+	if len(r.Students) != 3 {
+		t.Fatalf("%d students tracked, want 3", len(r.Students))
+	}
+	falling := r.Students[2]
+	if falling.Emplid != "3" || len(falling.Results) != 4 || falling.Results[0].Course != "CSF111" {
+		t.Fatalf("student 3 tracked as %+v", falling)
+	}
+	// Percentiles per term average to 83.33, 16.67 and 16.67.
+	tr := falling.Trend
+	if tr.Terms != 3 || tr.FirstTerm != "202324_01" || tr.LastTerm != "202425_01" {
+		t.Errorf("trend over %d terms %s to %s, want 3 terms 202324_01 to 202425_01", tr.Terms, tr.FirstTerm, tr.LastTerm)
+	}
+	if math.Abs(tr.Slope+100.0/3) > 1e-9 || math.Abs(tr.Change+200.0/3) > 1e-9 {
+		t.Errorf("slope %g change %g, want -33.33 and -66.67", tr.Slope, tr.Change)
+	}
+	if len(r.Declining) != 1 || r.Declining[0].Emplid != "3" {
+		t.Errorf("declining %+v, want only student 3", r.Declining)
+	}
+
+	for _, c := range r.Cohorts {
+		if c.Cohort == "2021" && c.Term == "202324_02" && (c.Students != 2 || c.Results != 4) {
+			t.Errorf("2021 cohort in 202324_02: %d students, %d results, want 2 and 4", c.Students, c.Results)
+		}
+	}
+}
+
+func TestExportTracking(t *testing.T) {
+	r, err := Track(trackingOfferings(), TrackOptions{Report: Options{Rank: DefaultRankOptions()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	defer os.Chdir(wd)
+
+	if _, err := ExportTracking(r, "csv", "-"); err == nil {
+		t.Error("csv to standard output accepted")
+	}
+	if _, err := os.Stat("-"); !os.IsNotExist(err) {
+		t.Errorf("a directory named - was created")
+	}
+
+	path, err := ExportTracking(r, "csv", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if path != "tracking_report" {
+		t.Errorf("csv went to %q, want tracking_report", path)
+	}
+	for _, name := range []string{"offerings", "student_results", "student_trends", "cohorts", "declining"} {
+		if _, err := os.Stat(filepath.Join(path, name+".csv")); err != nil {
+			t.Errorf("missing table: %v", err)
+		}
+	}
+
+	path, err = ExportTracking(r, "json", "tracking.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var decoded TrackingReport
+	if err := json.Unmarshal(data, &decoded); err != nil || len(decoded.Students) != 3 {
+		t.Errorf("json holds %d students (%v), want 3", len(decoded.Students), err)
+	}
+
+	if _, err := ExportTracking(r, "xml", ""); err == nil {
+		t.Error("unknown format accepted")
+	}
+}
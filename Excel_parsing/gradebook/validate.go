@@ -0,0 +1,250 @@
+package gradebook
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/xuri/excelize/v2"
+)
+
+// Issue severities. Rows with at least one error are quarantined; warnings
+// are reported but the row is still used.
+const (
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue is a single problem found while reading a gradebook row.
+type Issue struct {
+	File     string `json:"file"`
+	Sheet    string `json:"sheet"`
+	Row      int    `json:"row"`
+	Column   string `json:"column,omitempty"`
+	Cell     string `json:"cell,omitempty"`
+	Value    string `json:"value,omitempty"`
+	Severity string `json:"severity"`
+	Message  string `json:"message"`
+}
+
+// QuarantinedRow identifies a row that was left out of the report.
+type QuarantinedRow struct {
+	File   string `json:"file"`
+	Sheet  string `json:"sheet"`
+	Row    int    `json:"row"`
+	Emplid string `json:"emplid,omitempty"`
+}
+
+// SkippedSheet is a sheet without a recognisable gradebook header that
+// was passed over while reading every sheet of a workbook.
+type SkippedSheet struct {
+	File   string `json:"file"`
+	Sheet  string `json:"sheet"`
+	Reason string `json:"reason"`
+}
+
+type ValidationReport struct {
+	RowsRead      int              `json:"rows_read"`
+	RowsUsed      int              `json:"rows_used"`
+	Issues        []Issue          `json:"issues"`
+	Quarantined   []QuarantinedRow `json:"quarantined"`
+	SkippedSheets []SkippedSheet   `json:"skipped_sheets,omitempty"`
+}
+
+var numericFields = []string{
+	FieldQuiz, FieldMidSem, FieldLabTest, FieldWeeklyLabs,
+	FieldPreCompre, FieldCompre, FieldTotal,
+}
+
+// validator accumulates issues across every sheet it is handed so that
+// duplicate Emplids are caught even when they sit on different sheets or
+// workbooks.
+type validator struct {
+	maxMarks map[string]float64
+	campusID *regexp.Regexp
+	seen     map[string]string
+	report   ValidationReport
+}
+
+func newValidator(cfg ColumnConfig, branches *BranchRegistry) *validator {
+	return &validator{
+		maxMarks: cfg.MaxMarks,
+		campusID: branches.pattern,
+		seen:     make(map[string]string),
+		report:   ValidationReport{Issues: []Issue{}, Quarantined: []QuarantinedRow{}},
+	}
+}
+
+// parsedRow is one data row after parsing and the checks that need no
+// other rows. Rows are parsed independently, possibly concurrently, and
+// then added in input order so that duplicate Emplids are reported the
+// same way however the work was split.
+type parsedRow struct {
+	file, sheet string
+	row         int
+	student     Student
+	issues      []Issue
+	emplidCol   string
+	emplidCell  string
+}
+
+// parseRow parses and checks one data row of a sheet.
+func (v *validator) parseRow(file, sheet string, rowNum int, row []string, cols *columnIndex) parsedRow {
+	student, issues := parseStudent(sheet, rowNum, row, *cols)
+	issues = append(issues, v.checkStudent(sheet, rowNum, student, *cols)...)
+	for i := range issues {
+		issues[i].File = file
+	}
+	return parsedRow{
+		file: file, sheet: sheet, row: rowNum, student: student, issues: issues,
+		emplidCol: cols.header(FieldEmplid), emplidCell: cols.cellName(FieldEmplid, rowNum),
+	}
+}
+
+// add records a parsed row in the report, checking for a duplicate
+// Emplid, and returns its student unless the row has errors.
+func (v *validator) add(r parsedRow) (Student, bool) {
+	v.report.RowsRead++
+	issues := r.issues
+	s := r.student
+	if s.Emplid != "" {
+		if first, dup := v.seen[s.Emplid]; dup {
+			issues = append(issues, Issue{
+				File: r.file, Sheet: r.sheet, Row: r.row, Column: r.emplidCol, Cell: r.emplidCell,
+				Value: s.Emplid, Severity: SeverityError, Message: "duplicate Emplid, first seen at " + first,
+			})
+		}
+	}
+	v.report.Issues = append(v.report.Issues, issues...)
+
+	if hasErrors(issues) {
+		v.report.Quarantined = append(v.report.Quarantined,
+			QuarantinedRow{File: r.file, Sheet: r.sheet, Row: r.row, Emplid: s.Emplid})
+		return Student{}, false
+	}
+	if s.Emplid != "" {
+		v.seen[s.Emplid] = fmt.Sprintf("%s/%s row %d", r.file, r.sheet, r.row)
+	}
+	v.report.RowsUsed++
+	return s, true
+}
+
+func (v *validator) checkStudent(sheet string, rowNum int, s Student, cols columnIndex) []Issue {
+	var issues []Issue
+	issue := func(field, value, msg string) {
+		issues = append(issues, Issue{
+			Sheet: sheet, Row: rowNum,
+			Column: cols.header(field), Cell: cols.cellName(field, rowNum),
+			Value: value, Severity: SeverityError, Message: msg,
+		})
+	}
+
+	if s.CampusID != "" && !v.campusID.MatchString(s.CampusID) {
+		issue(FieldCampusID, s.CampusID, "unparseable campus ID")
+	}
+	for _, field := range numericFields {
+		if _, ok := cols.Fields[field]; !ok {
+			continue
+		}
+		score, _ := s.Component(field)
+		if score < 0 {
+			issue(field, fmt.Sprint(score), "negative marks")
+		} else if max, ok := v.maxMarks[field]; ok && score > max {
+			issue(field, fmt.Sprint(score), fmt.Sprintf("marks exceed maximum of %g", max))
+		}
+	}
+	return issues
+}
+
+func isBlankRow(row []string) bool {
+	for _, c := range row {
+		if strings.TrimSpace(c) != "" {
+			return false
+		}
+	}
+	return true
+}
+
+func hasErrors(issues []Issue) bool {
+	for _, is := range issues {
+		if is.Severity == SeverityError {
+			return true
+		}
+	}
+	return false
+}
+
+// header returns the sheet's own header text for a field, falling back to
+// the field name.
+func (idx columnIndex) header(field string) string {
+	i, ok := idx.Fields[field]
+	if !ok {
+		i, ok = idx.Extra[field]
+	}
+	if ok && i < len(idx.Header) {
+		return strings.TrimSpace(idx.Header[i])
+	}
+	return field
+}
+
+func (idx columnIndex) cellName(field string, rowNum int) string {
+	i, ok := idx.Fields[field]
+	if !ok {
+		i, ok = idx.Extra[field]
+	}
+	if !ok {
+		return ""
+	}
+	name, err := excelize.CoordinatesToCellName(i+1, rowNum)
+	if err != nil {
+		return ""
+	}
+	return name
+}
+
+func WriteValidationReport(report ValidationReport, path string) error {
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	encoder := json.NewEncoder(file)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(report)
+}
+
+func PrintValidationReport(w io.Writer, report ValidationReport) {
+	fmt.Fprintf(w, "Validation: %d rows read, %d used, %d quarantined, %d issues\n",
+		report.RowsRead, report.RowsUsed, len(report.Quarantined), len(report.Issues))
+	for _, sk := range report.SkippedSheets {
+		fmt.Fprintf(w, "Skipped %s sheet %q: %s\n", sk.File, sk.Sheet, sk.Reason)
+	}
+	if len(report.Issues) == 0 {
+		return
+	}
+
+	issues := append([]Issue(nil), report.Issues...)
+	sort.SliceStable(issues, func(i, j int) bool {
+		if issues[i].File != issues[j].File {
+			return issues[i].File < issues[j].File
+		}
+		if issues[i].Sheet != issues[j].Sheet {
+			return issues[i].Sheet < issues[j].Sheet
+		}
+		return issues[i].Row < issues[j].Row
+	})
+
+	tw := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "FILE\tSHEET\tROW\tCELL\tCOLUMN\tVALUE\tSEVERITY\tMESSAGE")
+	for _, is := range issues {
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%q\t%s\t%s\n",
+			is.File, is.Sheet, is.Row, is.Cell, is.Column, is.Value, is.Severity, is.Message)
+	}
+	tw.Flush()
+}
@@ -0,0 +1,79 @@
+package gradebook
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateRows(t *testing.T) {
+	const header = "Emplid,Campus ID,Mid Sem,Total\n"
+	tests := []struct {
+		name        string
+		rows        string
+		used        int
+		quarantined []int
+		messages    []string
+	}{
+		{"clean", "1,2022A7PS0001P,30,60\n2,2022A7PS0002P,20,50\n", 2, nil, nil},
+		{"malformed number", "1,2022A7PS0001P,thirty,60\n", 0, []int{2}, []string{"C2 malformed number"}},
+		{"missing Emplid", ",2022A7PS0001P,30,60\n", 0, []int{2}, []string{"A2 missing value"}},
+		{"bad campus ID", "1,22A7PS0001P,30,60\n", 0, []int{2}, []string{"B2 unparseable campus ID"}},
+		{"negative marks", "1,2022A7PS0001P,-3,60\n", 0, []int{2}, []string{"C2 negative marks"}},
+		{"over the maximum", "1,2022A7PS0001P,30,210\n", 0, []int{2}, []string{"D2 marks exceed maximum of 200"}},
+		{
+			"duplicate Emplid", "1,2022A7PS0001P,30,60\n1,2022A7PS0002P,20,50\n", 1, []int{3},
+			[]string{"A3 duplicate Emplid, first seen at marks.csv/marks row 2"},
+		},
+		{"blank mark is a warning", "1,2022A7PS0001P,,60\n", 1, nil, []string{"C2 missing value, treated as 0"}},
+		{"blank rows are skipped", "1,2022A7PS0001P,30,60\n,,,\n2,2022A7PS0002P,20,50\n", 2, nil, nil},
+	}
+	for _, tt := range tests {
+		cfg := ColumnConfig{MaxMarks: map[string]float64{FieldTotal: 200}}
+		students, v, err := Load(strings.NewReader(header+tt.rows), "marks.csv", LoadOptions{Columns: cfg})
+		if err != nil {
+			t.Fatalf("%s: %v", tt.name, err)
+		}
+		if len(students) != tt.used || v.RowsUsed != tt.used {
+			t.Errorf("%s: %d students, %d rows used, want %d", tt.name, len(students), v.RowsUsed, tt.used)
+		}
+		var rows []int
+		for _, q := range v.Quarantined {
+			rows = append(rows, q.Row)
+		}
+		if !reflect.DeepEqual(rows, tt.quarantined) {
+			t.Errorf("%s: quarantined rows %v, want %v", tt.name, rows, tt.quarantined)
+		}
+		var messages []string
+		for _, is := range v.Issues {
+			messages = append(messages, is.Cell+" "+is.Message)
+		}
+		if strings.Join(messages, "; ") != strings.Join(tt.messages, "; ") {
+			t.Errorf("%s: issues %q, want %q", tt.name, messages, tt.messages)
+		}
+	}
+}
+
+func TestPrintValidationReport(t *testing.T) {
+	report := ValidationReport{
+		RowsRead: 3, RowsUsed: 1,
+		Issues: []Issue{
+			{File: "b.csv", Sheet: "b", Row: 2, Cell: "C2", Severity: SeverityError, Message: "malformed number"},
+			{File: "a.csv", Sheet: "a", Row: 4, Cell: "A4", Severity: SeverityError, Message: "missing value"},
+		},
+		Quarantined:   []QuarantinedRow{{File: "a.csv", Row: 4}, {File: "b.csv", Row: 2}},
+		SkippedSheets: []SkippedSheet{{File: "a.csv", Sheet: "notes", Reason: "no header row found"}},
+	}
+	var out bytes.Buffer
+	PrintValidationReport(&out, report)
+	got := out.String()
+	for _, s := range []string{"3 rows read, 1 used, 2 quarantined, 2 issues", `Skipped a.csv sheet "notes"`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("report lacks %q:\n%s", s, got)
+		}
+	}
+	if strings.Index(got, "a.csv  ") > strings.Index(got, "b.csv  ") {
+		t.Errorf("issues not sorted by file:\n%s", got)
+	}
+}
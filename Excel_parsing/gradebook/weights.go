@@ -0,0 +1,165 @@
+package gradebook
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+const FieldRecomputedTotal = "RecomputedTotal"
+
+// ComponentWeight is one component's share of the total and the maximum
+// marks it is graded out of.
+type ComponentWeight struct {
+	Weight float64 `json:"weight" yaml:"weight"`
+	Max    float64 `json:"max" yaml:"max"`
+}
+
+// WeightsConfig describes how the course total is built. The recomputed
+// total is sum(score/max*weight) scaled so that full marks give Scale.
+// When TotalMax is set the recorded Total is rescaled the same way before
+// the two are compared.
+type WeightsConfig struct {
+	Components map[string]ComponentWeight `json:"components" yaml:"components"`
+	Scale      float64                    `json:"scale" yaml:"scale"`
+	TotalMax   float64                    `json:"total_max" yaml:"total_max"`
+	Tolerance  float64                    `json:"tolerance" yaml:"tolerance"`
+}
+
+// TotalMismatch is a student whose recorded Total disagrees with the
+// recomputed one by more than the tolerance.
+type TotalMismatch struct {
+	Emplid     string  `json:"emplid"`
+	CampusID   string  `json:"campus_id"`
+	Source     string  `json:"source,omitempty"`
+	Sheet      string  `json:"sheet,omitempty"`
+	Recorded   float64 `json:"recorded"`
+	Recomputed float64 `json:"recomputed"`
+	Difference float64 `json:"difference"`
+}
+
+type TotalCheckReport struct {
+	Scale      float64         `json:"scale"`
+	Tolerance  float64         `json:"tolerance"`
+	Checked    int             `json:"checked"`
+	Mismatches []TotalMismatch `json:"mismatches"`
+}
+
+func LoadWeightsConfig(path string) (WeightsConfig, error) {
+	var cfg WeightsConfig
+	if err := LoadConfigFile(path, &cfg); err != nil {
+		return cfg, err
+	}
+	if len(cfg.Components) == 0 {
+		return cfg, fmt.Errorf("%s: no components", path)
+	}
+	for name, w := range cfg.Components {
+		if w.Max <= 0 {
+			return cfg, fmt.Errorf("%s: component %q needs a positive max", path, name)
+		}
+		if w.Weight < 0 {
+			return cfg, fmt.Errorf("%s: component %q has a negative weight", path, name)
+		}
+	}
+	if cfg.Scale == 0 {
+		cfg.Scale = 100
+	}
+	if cfg.Tolerance == 0 {
+		cfg.Tolerance = 0.5
+	}
+	return cfg, nil
+}
+
+func (cfg WeightsConfig) totalWeight() float64 {
+	var sum float64
+	for _, w := range cfg.Components {
+		sum += w.Weight
+	}
+	return sum
+}
+
+// recompute returns the weighted total of s on cfg's scale.
+func (cfg WeightsConfig) recompute(s Student) float64 {
+	totalWeight := cfg.totalWeight()
+	if totalWeight == 0 {
+		return 0
+	}
+	var sum float64
+	for name, w := range cfg.Components {
+		v, _ := s.Component(name)
+		sum += v / w.Max * w.Weight
+	}
+	return sum / totalWeight * cfg.Scale
+}
+
+// recorded returns the sheet's Total on cfg's scale.
+func (cfg WeightsConfig) recorded(s Student) float64 {
+	if cfg.TotalMax > 0 {
+		return s.Total / cfg.TotalMax * cfg.Scale
+	}
+	return s.Total
+}
+
+// checkTotals stores the recomputed total on every student and lists those
+// whose recorded Total is out by more than the tolerance.
+func checkTotals(students []Student, cfg WeightsConfig) (TotalCheckReport, error) {
+	report := TotalCheckReport{Scale: cfg.Scale, Tolerance: cfg.Tolerance, Mismatches: []TotalMismatch{}}
+	if len(students) > 0 {
+		known := statComponents(students)
+		for name := range cfg.Components {
+			if !contains(known, name) {
+				return report, fmt.Errorf("weights refer to unknown component %q", name)
+			}
+		}
+	}
+
+	for i := range students {
+		s := &students[i]
+		recomputed := cfg.recompute(*s)
+		s.RecomputedTotal = &recomputed
+		report.Checked++
+
+		recorded := cfg.recorded(*s)
+		if diff := recorded - recomputed; math.Abs(diff) > cfg.Tolerance {
+			report.Mismatches = append(report.Mismatches, TotalMismatch{
+				Emplid: s.Emplid, CampusID: s.CampusID, Source: s.Source, Sheet: s.Sheet,
+				Recorded: recorded, Recomputed: recomputed, Difference: diff,
+			})
+		}
+	}
+	sort.SliceStable(report.Mismatches, func(i, j int) bool {
+		return math.Abs(report.Mismatches[i].Difference) > math.Abs(report.Mismatches[j].Difference)
+	})
+	return report, nil
+}
+
+// restrict narrows a check made on the whole class to students, such as
+// those a filter selected, keeping the recomputed totals it stored.
+func (r TotalCheckReport) restrict(students []Student) TotalCheckReport {
+	type key struct{ emplid, source, sheet string }
+	kept := make(map[key]bool, len(students))
+	for _, s := range students {
+		kept[key{s.Emplid, s.Source, s.Sheet}] = true
+	}
+	out := r
+	out.Checked = len(students)
+	out.Mismatches = []TotalMismatch{}
+	for _, m := range r.Mismatches {
+		if kept[key{m.Emplid, m.Source, m.Sheet}] {
+			out.Mismatches = append(out.Mismatches, m)
+		}
+	}
+	return out
+}
+
+func totalCheckTable(r *TotalCheckReport) Table {
+	t := Table{
+		Name:   "total_mismatches",
+		Title:  fmt.Sprintf("Total Mismatches (%d of %d beyond ±%g on a %g scale)", len(r.Mismatches), r.Checked, r.Tolerance, r.Scale),
+		Header: []string{"Emplid", "Campus ID", "Source", "Sheet", "Recorded", "Recomputed", "Difference"},
+	}
+	for _, m := range r.Mismatches {
+		t.Rows = append(t.Rows, []interface{}{m.Emplid, m.CampusID, m.Source, m.Sheet, m.Recorded, m.Recomputed, m.Difference})
+	}
+	return t
+}
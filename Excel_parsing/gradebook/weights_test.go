@@ -0,0 +1,120 @@
+package gradebook
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestRecompute(t *testing.T) {
+	s := Student{MidSem: 30, Compre: 80, Total: 140}
+	tests := []struct {
+		name string
+		cfg  WeightsConfig
+		want float64
+	}{
+		{
+			name: "weights out of 100",
+			cfg: WeightsConfig{Scale: 100, Components: map[string]ComponentWeight{
+				FieldMidSem: {Weight: 40, Max: 60}, FieldCompre: {Weight: 60, Max: 100},
+			}},
+			want: 20 + 48,
+		},
+		{
+			name: "weights not adding up to the scale",
+			cfg: WeightsConfig{Scale: 200, Components: map[string]ComponentWeight{
+				FieldMidSem: {Weight: 1, Max: 60}, FieldCompre: {Weight: 1, Max: 100},
+			}},
+			want: (0.5 + 0.8) / 2 * 200,
+		},
+		{
+			name: "no weight",
+			cfg:  WeightsConfig{Scale: 100, Components: map[string]ComponentWeight{FieldMidSem: {Max: 60}}},
+			want: 0,
+		},
+	}
+	for _, tt := range tests {
+		if got := tt.cfg.recompute(s); math.Abs(got-tt.want) > 1e-9 {
+			t.Errorf("%s: recomputed %g, want %g", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCheckTotals(t *testing.T) {
+	cfg := WeightsConfig{
+		Scale: 100, TotalMax: 200, Tolerance: 0.5,
+		Components: map[string]ComponentWeight{FieldMidSem: {Weight: 40, Max: 60}, FieldCompre: {Weight: 60, Max: 100}},
+	}
+	students := []Student{
+		{Emplid: "1", MidSem: 30, Compre: 80, Total: 136},   // 68 of 100, as recorded
+		{Emplid: "2", MidSem: 30, Compre: 80, Total: 136.8}, // 68.4, within tolerance
+		{Emplid: "3", MidSem: 60, Compre: 50, Total: 150},   // 75 recorded, 70 recomputed
+		{Emplid: "4", MidSem: 0, Compre: 50, Total: 50},     // 25 recorded, 30 recomputed
+		{Emplid: "5", MidSem: 60, Compre: 100, Total: 180},  // 90 recorded, 100 recomputed
+	}
+	report, err := checkTotals(students, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Checked != 5 {
+		t.Errorf("checked %d, want 5", report.Checked)
+	}
+	var got []string
+	for _, m := range report.Mismatches {
+		got = append(got, m.Emplid)
+	}
+	// Largest difference first; 3 and 4 are both 5 out and keep their order.
+	if want := []string{"5", "3", "4"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("mismatches %v, want %v", got, want)
+	}
+	if m := report.Mismatches[0]; m.Recorded != 90 || m.Recomputed != 100 || m.Difference != -10 {
+		t.Errorf("student 5: %+v", m)
+	}
+	for _, s := range students {
+		if s.RecomputedTotal == nil {
+			t.Errorf("student %s has no recomputed total", s.Emplid)
+		}
+	}
+
+	// A filter keeping 1 and 3 keeps only 3's mismatch.
+	restricted := report.restrict([]Student{students[0], students[2]})
+	if restricted.Checked != 2 || len(restricted.Mismatches) != 1 || restricted.Mismatches[0].Emplid != "3" {
+		t.Errorf("restricted to 1 and 3: %+v", restricted)
+	}
+
+	cfg.Components["Project"] = ComponentWeight{Weight: 10, Max: 20}
+	if _, err := checkTotals(students, cfg); err == nil {
+		t.Error("weights for an unknown component accepted")
+	}
+}
+
+func TestLoadWeightsConfig(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		name, content string
+		scale, tol    float64
+		err           bool
+	}{
+		{"defaults", `{"components": {"Compre": {"weight": 60, "max": 100}}}`, 100, 0.5, false},
+		{"set", `{"components": {"Compre": {"weight": 60, "max": 100}}, "scale": 200, "tolerance": 1}`, 200, 1, false},
+		{"no components", `{"scale": 100}`, 0, 0, true},
+		{"no max", `{"components": {"Compre": {"weight": 60}}}`, 0, 0, true},
+		{"negative weight", `{"components": {"Compre": {"weight": -1, "max": 100}}}`, 0, 0, true},
+	}
+	for _, tt := range tests {
+		path := filepath.Join(dir, "weights.json")
+		if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+		cfg, err := LoadWeightsConfig(path)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: error %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		if !tt.err && (cfg.Scale != tt.scale || cfg.Tolerance != tt.tol) {
+			t.Errorf("%s: scale %g tolerance %g, want %g and %g", tt.name, cfg.Scale, cfg.Tolerance, tt.scale, tt.tol)
+		}
+	}
+}
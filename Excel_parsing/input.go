@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const defaultInput = "CSF111_202425_01_GradeBook_stripped.xlsx"
+
+// stringList is a repeatable flag; each occurrence may also hold a
+// comma-separated list.
+type stringList []string
+
+func (l *stringList) String() string { return strings.Join(*l, ",") }
+
+func (l *stringList) Set(value string) error {
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			*l = append(*l, v)
+		}
+	}
+	return nil
+}
+
+// expandInputs resolves glob patterns into a sorted, de-duplicated list of
+// files. Patterns without glob characters are passed through so that a
+// missing file is reported when it is opened.
+func expandInputs(patterns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+	for _, pattern := range patterns {
+		matches := []string{pattern}
+		if strings.ContainsAny(pattern, "*?[") {
+			var err error
+			matches, err = filepath.Glob(pattern)
+			if err != nil {
+				return nil, fmt.Errorf("bad pattern %q: %w", pattern, err)
+			}
+			if len(matches) == 0 {
+				return nil, fmt.Errorf("no files match %q", pattern)
+			}
+			sort.Strings(matches)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				files = append(files, m)
+			}
+		}
+	}
+	return files, nil
+}
+
+// annotationPath is where the annotated copy of input goes: input itself
+// in place, otherwise dest, which must be a directory when there are
+// several inputs.
+func annotationPath(input, dest string, inPlace, several bool) (string, error) {
+	if inPlace {
+		return input, nil
+	}
+	if info, err := os.Stat(dest); err == nil && info.IsDir() {
+		return filepath.Join(dest, filepath.Base(input)), nil
+	}
+	if several {
+		return "", fmt.Errorf("--annotate must be a directory when there are several inputs")
+	}
+	return dest, nil
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestStringList(t *testing.T) {
+	var l stringList
+	for _, v := range []string{"a.xlsx", " b.csv, ,c.ods ", "a.xlsx"} {
+		l.Set(v)
+	}
+	if want := (stringList{"a.xlsx", "b.csv", "c.ods", "a.xlsx"}); !reflect.DeepEqual(l, want) {
+		t.Errorf("got %v, want %v", l, want)
+	}
+}
+
+func TestExpandInputs(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"b.xlsx", "a.xlsx", "c.csv"} {
+		if err := os.WriteFile(filepath.Join(dir, name), nil, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	path := func(name string) string { return filepath.Join(dir, name) }
+	tests := []struct {
+		patterns []string
+		want     []string
+		err      bool
+	}{
+		{[]string{path("*.xlsx")}, []string{path("a.xlsx"), path("b.xlsx")}, false},
+		{[]string{path("c.csv"), path("*")}, []string{path("c.csv"), path("a.xlsx"), path("b.xlsx")}, false},
+		{[]string{path("missing.xlsx")}, []string{path("missing.xlsx")}, false},
+		{[]string{path("*.ods")}, nil, true},
+		{[]string{path("[")}, nil, true},
+	}
+	for _, tt := range tests {
+		got, err := expandInputs(tt.patterns)
+		if (err != nil) != tt.err || !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v: got %v, %v, want %v", tt.patterns, got, err, tt.want)
+		}
+	}
+}
+
+func TestAnnotationPath(t *testing.T) {
+	dir := t.TempDir()
+	tests := []struct {
+		input, dest      string
+		inPlace, several bool
+		want             string
+		err              bool
+	}{
+		{"in/marks.xlsx", "", true, true, "in/marks.xlsx", false},
+		{"in/marks.xlsx", dir, false, true, filepath.Join(dir, "marks.xlsx"), false},
+		{"in/marks.xlsx", "out.xlsx", false, false, "out.xlsx", false},
+		{"in/marks.xlsx", "out.xlsx", false, true, "", true},
+	}
+	for _, tt := range tests {
+		got, err := annotationPath(tt.input, tt.dest, tt.inPlace, tt.several)
+		if (err != nil) != tt.err || got != tt.want {
+			t.Errorf("%+v: got %q, %v, want %q", tt, got, err, tt.want)
+		}
+	}
+}
@@ -1,190 +1,273 @@
 package main
 
 import (
-	"encoding/json"
 	"flag"
 	"fmt"
 	"os"
-	"sort"
-	"strconv"
+	"strings"
 
-	"github.com/xuri/excelize/v2"
+	"github.com/FrancoisDuvet/friendly-chainsaw/Excel_parsing/gradebook"
 )
 
-type Student struct {
-	ClassNo    string
-	Emplid     string
-	CampusID   string
-	Quiz       float64
-	MidSem     float64
-	LabTest    float64
-	WeeklyLabs float64
-	PreCompre  float64
-	Compre     float64
-	Total      float64
-}
-
-type SummaryReport struct {
-	GeneralAverages   map[string]float64 `json:"general_averages"`
-	BranchAverages    map[string]float64 `json:"branch_averages"`
-	BranchRankings    map[string][]Student `json:"branch_rankings"`
-	OverallTopStudents []Student `json:"overall_top_students"`
-}
-
 func main() {
-	exportFormat := flag.String("export", "", "Export final report to json format")
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "serve":
+			serve(os.Args[2:])
+			return
+		case "diff":
+			diff(os.Args[2:])
+			return
+		case "track":
+			track(os.Args[2:])
+			return
+		}
+	}
+
+	exportFormat := flag.String("export", "", "Export final report as "+strings.Join(gradebook.ExporterNames(), ", ")+" (prints to the console when empty)")
+	output := flag.String("output", "", "Export destination: a file or - for stdout; for csv a directory, as csv cannot go to stdout")
 	classFilter := flag.String("class", "", "Filter by input Class No.")
+	where := flag.String("where", "", "Only report students matching an expression, e.g. 'Compre < 20 && MidSem > 40 || branch == \"A7\"'")
+	columnsFile := flag.String("columns", "", "JSON or YAML file with column header synonyms and extra components")
+	strict := flag.Bool("strict", false, "Fail the run if any row has validation issues")
+	validationFile := flag.String("validation-report", "", "Write the row validation report to this JSON file")
+	sheetName := flag.String("sheet", "", "Read the named sheet instead of the first one")
+	allSheets := flag.Bool("all-sheets", false, "Read every sheet of each workbook")
+	inputFormat := flag.String("format", "", "Input format: "+strings.Join(gradebook.ReaderFormats(), ", ")+" (detected when empty)")
+	delimiter := flag.String("delimiter", "", "Field delimiter for CSV input (detected when empty)")
+	gradingScheme := flag.String("grading", "", "Assign letter grades using "+strings.Join(gradebook.GradingSchemes, ", "))
+	gradingFile := flag.String("grading-config", "", "JSON or YAML file with grade cutoffs, bands or quotas")
+	rankBy := flag.String("rank-by", gradebook.FieldTotal, "Component or arithmetic expression to rank by, e.g. \"MidSem + Compre\"; put names with spaces in backquotes")
+	rankMethod := flag.String("rank-method", gradebook.RankCompetition, "Rank numbering: "+strings.Join(gradebook.RankMethods, ", "))
+	top := flag.Int("top", 3, "Number of places in the overall top list (0 for everyone)")
+	var tieBreakers stringList
+	flag.Var(&tieBreakers, "tie-break", "Tie-breakers in order, e.g. Compre,MidSem,Emplid")
+	weightsFile := flag.String("weights", "", "JSON or YAML file with component weights and maximum marks; recomputes and checks Total")
+	useRecomputed := flag.Bool("use-recomputed", false, "Rank by the recomputed total instead of the sheet's Total (needs --weights)")
+	branchFile := flag.String("branches", "", "JSON or YAML file with the campus ID pattern and branch/campus names")
+	dualDegree := flag.String("dual-degree", "", "Group dual-degree students under their "+strings.Join(gradebook.DualDegreeModes, ", ")+" degree")
+	chartsDir := flag.String("charts", "", "Write SVG histograms, box plots and a scatter plot to this directory; also adds native charts to XLSX exports")
+	anomalies := flag.Bool("anomalies", false, "Rank unusual students and sections (Compre jumps, full/zero splits, section means, identical marks) for re-checking")
+	anomalyThreshold := flag.Float64("anomaly-threshold", 0, "Smallest anomaly score, in standard deviations, to report (2 when zero)")
+	correlations := flag.Bool("correlations", false, "Add Pearson and Spearman correlations between components and a regression of Compre on the pre-compre components")
+	annotate := flag.String("annotate", "", "Write a copy of each XLSX input with Rank, Branch Rank, Percentile, Grade, Recomputed Total and Validation Flags columns appended and a copy of each sheet sorted by Total (a file, or a directory for several inputs)")
+	inPlace := flag.Bool("in-place", false, "Annotate the XLSX inputs themselves instead of copies")
+	cardsDir := flag.String("report-cards", "", "Write a report card per student, named by Emplid, and an index.html to this directory")
+	var cardFormats stringList
+	flag.Var(&cardFormats, "card-format", "Report card formats: "+strings.Join(gradebook.ReportCardFormats, ", ")+" (html when empty)")
+	var markPolicies stringList
+	flag.Var(&markPolicies, "mark-policy", "Policy for special marks as state=policy, e.g. absent=exclude (states: "+strings.Join(gradebook.MarkStates, ", ")+"; policies: "+strings.Join(gradebook.MarkPolicies, ", ")+")")
+	normalizeMethod := flag.String("normalize", "", "Normalize totals across sections: "+strings.Join(gradebook.NormalizeMethods, ", "))
+	useNormalized := flag.Bool("use-normalized", false, "Rank and grade by the normalized total instead of the sheet's Total (needs --normalize)")
+	suggestCutoffs := flag.String("suggest-cutoffs", "", "Suggest grade cutoffs at natural breaks in the totals: "+strings.Join(gradebook.BreakMethods, ", "))
+	suggestGrades := flag.Int("suggest-grades", 0, "Number of grades for --suggest-cutoffs, from A down (all letter grades when zero)")
+	minQuota := flag.Float64("min-quota", 0, "Smallest percentage of the class in any suggested grade")
+	maxQuota := flag.Float64("max-quota", 0, "Largest percentage of the class in any suggested grade (no limit when zero)")
+	rulesFile := flag.String("rules", "", "JSON or YAML file of makeup and improvement rules applied before ranking and grading")
+	var scoreFiles stringList
+	flag.Var(&scoreFiles, "scores", "Makeup or improvement score file keyed by Emplid, for --rules (repeatable)")
+	var inputs stringList
+	flag.Var(&inputs, "input", "Gradebook file or glob pattern (repeatable, comma-separated)")
 	flag.Parse()
 
-	f, err := excelize.OpenFile("CSF111_202425_01_GradeBook_stripped.xlsx")
+	inputs = append(inputs, flag.Args()...)
+	if len(inputs) == 0 {
+		inputs = append(inputs, defaultInput)
+	}
+	files, err := expandInputs(inputs)
 	if err != nil {
-		fmt.Println("Error opening file:", err)
+		fmt.Println("Error resolving input files:", err)
 		return
 	}
-	defer f.Close()
 
-	sheetName := f.GetSheetName(0)
-	if sheetName == "" {
-		fmt.Println("No sheets found in the file")
+	columnCfg, err := gradebook.LoadColumnConfig(*columnsFile)
+	if err != nil {
+		fmt.Println("Error reading column config:", err)
 		return
 	}
+	for _, spec := range markPolicies {
+		if err := columnCfg.SpecialMarks.SetPolicy(spec); err != nil {
+			fmt.Println("Error in --mark-policy:", err)
+			return
+		}
+	}
 
-	rows, err := f.GetRows(sheetName)
+	branches, err := gradebook.LoadBranchRegistry(*branchFile, *dualDegree)
 	if err != nil {
-		fmt.Println("Error reading sheet:", err)
+		fmt.Println("Error reading branch registry:", err)
 		return
 	}
 
-	var students []Student
-	for i, row := range rows {
-		if i == 0 {
-			continue // Skipped 1st line
+	readerOpts := gradebook.ReaderOptions{Format: strings.ToLower(*inputFormat)}
+	if *delimiter != "" {
+		if *delimiter == `\t` {
+			*delimiter = "\t"
 		}
-		student := parseStudent(row)
+		readerOpts.Delimiter = []rune(*delimiter)[0]
+	}
+	loader, err := gradebook.NewLoader(gradebook.LoadOptions{
+		Columns:  columnCfg,
+		Reader:   readerOpts,
+		Sheets:   gradebook.SheetSelection{Name: *sheetName, All: *allSheets},
+		Branches: branches,
+	})
+	if err != nil {
+		fmt.Println("Error preparing loader:", err)
+		return
+	}
+	loaded, err := loader.LoadFiles(files)
+	if err != nil {
+		fmt.Println("Error opening file:", err)
+		return
+	}
+	var students []gradebook.Student
+	for _, student := range loaded {
 		if *classFilter == "" || student.ClassNo == *classFilter {
 			students = append(students, student)
 		}
 	}
 
-	report := generateReport(students)
-
-	if *exportFormat == "json" {
-		exportToJson(report)
-	} else {
-		printReport(report)
+	unknownBranches := loader.UnknownBranchCodes()
+	if len(unknownBranches) > 0 {
+		fmt.Fprintln(os.Stderr, "Unknown branch codes:", strings.Join(unknownBranches, ", "))
 	}
-}
 
-func parseStudent(row []string) Student {
-	return Student{
-		ClassNo:    row[1],
-		Emplid:     row[2],
-		CampusID:   row[3],
-		Quiz:       parseFloat(row[4]),
-		MidSem:     parseFloat(row[5]),
-		LabTest:    parseFloat(row[6]),
-		WeeklyLabs: parseFloat(row[7]),
-		PreCompre:  parseFloat(row[8]),
-		Compre:     parseFloat(row[9]),
-		Total:      parseFloat(row[10]),
+	validation := loader.Validation()
+	gradebook.PrintValidationReport(os.Stderr, validation)
+	if *validationFile != "" {
+		if err := gradebook.WriteValidationReport(validation, *validationFile); err != nil {
+			fmt.Println("Error writing validation report:", err)
+		}
 	}
-}
-
-func parseFloat(s string) float64 {
-	f, _ := strconv.ParseFloat(s, 64)
-	return f
-}
-
-func generateReport(students []Student) SummaryReport {
-	generalAverages := GeneralAverages(students)
-	branchAverages := BranchWiseAverages(students)
-	branchRankings := BranchWiseRankings(students)
-	overallTopStudents := OverallTopStudents(students)
-
-	return SummaryReport{
-		GeneralAverages:    generalAverages,
-		BranchAverages:     branchAverages,
-		BranchRankings:     branchRankings,
-		OverallTopStudents: overallTopStudents,
+	if *strict && len(validation.Issues) > 0 {
+		fmt.Println("Validation failed in strict mode")
+		os.Exit(1)
 	}
-}
 
-func OverallTopStudents(students []Student) []Student {
-	sort.Slice(students, func(i, j int) bool {
-		return students[i].Total > students[j].Total
-	})
-	if len(students) > 3 {
-		return students[:3]
+	opts := gradebook.Options{
+		Rank:          gradebook.RankOptions{By: *rankBy, Method: *rankMethod, TieBreakers: tieBreakers, Top: *top},
+		MaxTotal:      columnCfg.MaxMarks[gradebook.FieldTotal],
+		UseRecomputed: *useRecomputed,
+		Charts:        *chartsDir != "",
+		Where:         *where,
+		Correlations:  *correlations,
+		UseNormalized: *useNormalized,
 	}
-	return students
-}
-
-func GeneralAverages(students []Student) map[string]float64 {
-	averages := make(map[string]float64)
-	var sumTotal float64
-	count := float64(len(students))
-
-	for _, s := range students {
-		sumTotal += s.Total
+	if *normalizeMethod != "" {
+		opts.Normalize = &gradebook.NormalizeOptions{Method: strings.ToLower(*normalizeMethod)}
+	} else if *useNormalized {
+		fmt.Println("--use-normalized needs --normalize")
+		return
 	}
-	averages["Total"] = sumTotal / count
-	return averages
-}
-
-func BranchWiseAverages(students []Student) map[string]float64 {
-	branchAverages := make(map[string]float64)
-	branchCounts := make(map[string]int)
-
-	for _, s := range students {
-		branch := s.CampusID[4:8]
-		branchAverages[branch] += s.Total
-		branchCounts[branch]++
+	if *suggestCutoffs != "" {
+		opts.Breaks = &gradebook.BreakOptions{
+			Method: strings.ToLower(*suggestCutoffs), Grades: *suggestGrades, MinQuota: *minQuota, MaxQuota: *maxQuota,
+		}
+	} else if *suggestGrades != 0 || *minQuota != 0 || *maxQuota != 0 {
+		fmt.Println("--suggest-grades, --min-quota and --max-quota need --suggest-cutoffs")
+		return
+	}
+	if *anomalies {
+		opts.Anomalies = &gradebook.AnomalyOptions{Threshold: *anomalyThreshold, MaxMarks: columnCfg.MaxMarks}
+	}
+	if *gradingScheme != "" || *gradingFile != "" {
+		gradingCfg, err := gradebook.LoadGradingConfig(*gradingFile, *gradingScheme)
+		if err != nil {
+			fmt.Println("Error reading grading config:", err)
+			return
+		}
+		opts.Grading = &gradingCfg
+	}
+	if *rulesFile != "" {
+		rules, err := gradebook.LoadRuleSet(*rulesFile)
+		if err != nil {
+			fmt.Println("Error reading rules:", err)
+			return
+		}
+		for _, file := range scoreFiles {
+			if err := rules.LoadScores(file, readerOpts); err != nil {
+				fmt.Println("Error reading scores:", err)
+				return
+			}
+		}
+		opts.Rules = &rules
+	} else if len(scoreFiles) > 0 {
+		fmt.Println("--scores needs --rules")
+		return
+	}
+	if *weightsFile != "" {
+		weights, err := gradebook.LoadWeightsConfig(*weightsFile)
+		if err != nil {
+			fmt.Println("Error reading weights:", err)
+			return
+		}
+		opts.Weights = &weights
+	} else if *useRecomputed {
+		fmt.Println("--use-recomputed needs --weights")
+		return
 	}
 
-	for branch, total := range branchAverages {
-		branchAverages[branch] = total / float64(branchCounts[branch])
+	report, err := gradebook.Generate(students, opts)
+	if err != nil {
+		fmt.Println("Error generating report:", err)
+		return
 	}
-	return branchAverages
-}
+	if check := report.TotalCheck; check != nil && len(check.Mismatches) > 0 {
+		fmt.Fprintf(os.Stderr, "Total check: %d of %d recorded totals differ from the weighted total by more than %g\n",
+			len(check.Mismatches), check.Checked, check.Tolerance)
+	}
+	report.UnknownBranchCodes = unknownBranches
+	report.Validation = &validation
 
-func BranchWiseRankings(students []Student) map[string][]Student {
-	branchRankings := make(map[string][]Student)
-	branchStudents := make(map[string][]Student)
+	if *annotate != "" || *inPlace {
+		annotateOpts := gradebook.AnnotateOptions{Report: opts, Columns: columnCfg, Validation: validation}
+		for _, file := range files {
+			dest, err := annotationPath(file, *annotate, *inPlace, len(files) > 1)
+			if err != nil {
+				fmt.Println("Error annotating workbook:", err)
+				return
+			}
+			if err := gradebook.Annotate(file, dest, students, annotateOpts); err != nil {
+				fmt.Println("Error annotating workbook:", err)
+				return
+			}
+			fmt.Fprintln(os.Stderr, "Annotated", file, "as", dest)
+		}
+	}
 
-	for _, s := range students {
-		branch := s.CampusID[4:8]
-		branchStudents[branch] = append(branchStudents[branch], s)
+	if *chartsDir != "" {
+		paths, err := gradebook.WriteSVGCharts(*chartsDir, *report.Charts)
+		if err != nil {
+			fmt.Println("Error writing charts:", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d charts to %s\n", len(paths), *chartsDir)
 	}
 
-	for branch, studs := range branchStudents {
-		sort.Slice(studs, func(i, j int) bool {
-			return studs[i].Total > studs[j].Total
-		})
-		branchRankings[branch] = studs
+	if *cardsDir != "" {
+		cards, err := gradebook.BuildReportCards(students, opts)
+		if err != nil {
+			fmt.Println("Error building report cards:", err)
+			return
+		}
+		n, err := gradebook.WriteReportCards(*cardsDir, cards, cardFormats)
+		if err != nil {
+			fmt.Println("Error writing report cards:", err)
+			return
+		}
+		fmt.Fprintf(os.Stderr, "Wrote %d report cards to %s\n", n, *cardsDir)
 	}
-	return branchRankings
-}
 
-func exportToJson(report SummaryReport) {
-	file, err := os.Create("summary_report.json")
+	if *exportFormat == "" {
+		gradebook.PrintReport(os.Stdout, report)
+		return
+	}
+	path, err := gradebook.Export(report, strings.ToLower(*exportFormat), *output)
 	if err != nil {
-		fmt.Println("Error creating JSON file:", err)
+		fmt.Println("Error exporting report:", err)
 		return
 	}
-	defer file.Close()
-
-	encoder := json.NewEncoder(file)
-	encoder.SetIndent("", "  ")
-	if err := encoder.Encode(report); err != nil {
-		fmt.Println("Error writing to JSON file:", err)
-	} else {
-		fmt.Println("Summary report successfully exported to summary_report.json")
+	if path != "-" {
+		fmt.Println("Summary report successfully exported to", path)
 	}
 }
-
-func printReport(report SummaryReport) {
-	fmt.Println("General Averages:", report.GeneralAverages)
-	fmt.Println("Branch Averages:", report.BranchAverages)
-	fmt.Println("Branch Rankings:", report.BranchRankings)
-	fmt.Println("Overall Top Students:", report.OverallTopStudents)
-}
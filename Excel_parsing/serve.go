@@ -0,0 +1,530 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"html/template"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/FrancoisDuvet/friendly-chainsaw/Excel_parsing/gradebook"
+)
+
+// serve runs the "serve" subcommand: a small web server where gradebooks
+// can be uploaded and their reports browsed or fetched as JSON.
+func serve(args []string) {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	addr := fs.String("addr", "localhost:8080", "Address to listen on")
+	maxUpload := fs.Int64("max-upload", 32, "Largest accepted upload in MiB")
+	keep := fs.Int("keep", 50, "Number of uploaded reports kept in memory")
+	columnsFile := fs.String("columns", "", "JSON or YAML file with column header synonyms and extra components")
+	branchFile := fs.String("branches", "", "JSON or YAML file with the campus ID pattern and branch/campus names")
+	dualDegree := fs.String("dual-degree", "", "Group dual-degree students under their "+strings.Join(gradebook.DualDegreeModes, ", ")+" degree")
+	gradingFile := fs.String("grading-config", "", "JSON or YAML file with grade cutoffs, bands or quotas")
+	weightsFile := fs.String("weights", "", "JSON or YAML file with component weights and maximum marks")
+	fs.Parse(args)
+
+	columnCfg, err := gradebook.LoadColumnConfig(*columnsFile)
+	if err != nil {
+		fmt.Println("Error reading column config:", err)
+		return
+	}
+	branches, err := gradebook.LoadBranchRegistry(*branchFile, *dualDegree)
+	if err != nil {
+		fmt.Println("Error reading branch registry:", err)
+		return
+	}
+	s := &server{
+		load:      gradebook.LoadOptions{Columns: columnCfg, Branches: branches},
+		maxTotal:  columnCfg.MaxMarks[gradebook.FieldTotal],
+		maxUpload: *maxUpload << 20,
+		keep:      *keep,
+		reports:   make(map[string]*upload),
+	}
+	if *gradingFile != "" {
+		cfg, err := gradebook.LoadGradingConfig(*gradingFile, "")
+		if err != nil {
+			fmt.Println("Error reading grading config:", err)
+			return
+		}
+		s.grading = &cfg
+	}
+	if *weightsFile != "" {
+		weights, err := gradebook.LoadWeightsConfig(*weightsFile)
+		if err != nil {
+			fmt.Println("Error reading weights:", err)
+			return
+		}
+		s.weights = &weights
+	}
+
+	fmt.Printf("Serving gradebook reports on http://%s/\n", *addr)
+	if err := http.ListenAndServe(*addr, s.routes()); err != nil {
+		fmt.Println("Error running server:", err)
+		os.Exit(1)
+	}
+}
+
+// upload is one processed gradebook kept in memory.
+type upload struct {
+	ID       string                  `json:"id"`
+	Name     string                  `json:"name"`
+	Uploaded time.Time               `json:"uploaded"`
+	Report   gradebook.SummaryReport `json:"report"`
+}
+
+type server struct {
+	load      gradebook.LoadOptions
+	grading   *gradebook.GradingConfig
+	weights   *gradebook.WeightsConfig
+	maxTotal  float64
+	maxUpload int64
+	keep      int
+
+	mu      sync.Mutex
+	reports map[string]*upload
+	order   []string
+}
+
+func (s *server) routes() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /{$}", s.handleIndex)
+	mux.HandleFunc("POST /upload", s.handleUpload)
+	mux.HandleFunc("GET /reports/{id}", s.handleReport)
+	mux.HandleFunc("GET /api/reports", s.handleAPIList)
+	mux.HandleFunc("POST /api/reports", s.handleAPIUpload)
+	mux.HandleFunc("GET /api/reports/{id}", s.handleAPIReport)
+	mux.HandleFunc("GET /api/reports/{id}/students", s.handleAPIStudents)
+	return mux
+}
+
+// process runs the upload in r through the same pipeline as the CLI. Form
+// fields: file (one or more), class, sheet, all_sheets, rank_by, grading,
+// where, anomalies, correlations.
+func (s *server) process(w http.ResponseWriter, r *http.Request) (*upload, error) {
+	r.Body = http.MaxBytesReader(w, r.Body, s.maxUpload)
+	if err := r.ParseMultipartForm(s.maxUpload); err != nil {
+		return nil, fmt.Errorf("reading upload: %w", err)
+	}
+	files := r.MultipartForm.File["file"]
+	if len(files) == 0 {
+		return nil, errors.New("no gradebook uploaded (form field \"file\")")
+	}
+
+	opts := s.load
+	opts.Sheets = gradebook.SheetSelection{Name: r.FormValue("sheet"), All: r.FormValue("all_sheets") != ""}
+	loader, err := gradebook.NewLoader(opts)
+	if err != nil {
+		return nil, err
+	}
+	class := r.FormValue("class")
+	var students []gradebook.Student
+	var names []string
+	for _, fh := range files {
+		f, err := fh.Open()
+		if err != nil {
+			return nil, err
+		}
+		loaded, err := loader.Load(f, fh.Filename)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", fh.Filename, err)
+		}
+		for _, student := range loaded {
+			if class == "" || student.ClassNo == class {
+				students = append(students, student)
+			}
+		}
+		names = append(names, fh.Filename)
+	}
+
+	rank := gradebook.DefaultRankOptions()
+	if by := r.FormValue("rank_by"); by != "" {
+		rank.By = by
+	}
+	// Keep the full overall ranking; the pages and the API filter it.
+	rank.Top = 0
+	reportOpts := gradebook.Options{Rank: rank, MaxTotal: s.maxTotal, Weights: s.weights, Grading: s.grading, Charts: true,
+		Where: r.FormValue("where"), Correlations: r.FormValue("correlations") != ""}
+	if scheme := r.FormValue("grading"); scheme != "" {
+		cfg, err := gradebook.LoadGradingConfig("", scheme)
+		if err != nil {
+			return nil, err
+		}
+		reportOpts.Grading = &cfg
+	}
+	if r.FormValue("anomalies") != "" {
+		reportOpts.Anomalies = &gradebook.AnomalyOptions{MaxMarks: s.load.Columns.MaxMarks}
+	}
+	report, err := gradebook.Generate(students, reportOpts)
+	if err != nil {
+		return nil, err
+	}
+	validation := loader.Validation()
+	report.Validation = &validation
+	report.UnknownBranchCodes = loader.UnknownBranchCodes()
+
+	id, err := newID()
+	if err != nil {
+		return nil, internalError{fmt.Errorf("naming the report: %w", err)}
+	}
+	u := &upload{ID: id, Name: strings.Join(names, ", "), Uploaded: time.Now(), Report: report}
+	s.store(u)
+	return u, nil
+}
+
+// store keeps u, dropping the oldest reports beyond the configured limit.
+func (s *server) store(u *upload) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[u.ID] = u
+	s.order = append(s.order, u.ID)
+	for s.keep > 0 && len(s.order) > s.keep {
+		delete(s.reports, s.order[0])
+		s.order = s.order[1:]
+	}
+}
+
+func (s *server) lookup(id string) *upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reports[id]
+}
+
+// list returns the kept reports, newest first.
+func (s *server) list() []*upload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	out := make([]*upload, 0, len(s.order))
+	for i := len(s.order) - 1; i >= 0; i-- {
+		out = append(out, s.reports[s.order[i]])
+	}
+	return out
+}
+
+func newID() (string, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// internalError marks a failure of process that is the server's fault
+// rather than the upload's.
+type internalError struct{ error }
+
+func (e internalError) Unwrap() error { return e.error }
+
+// uploadStatus is the HTTP status for an error from process.
+func uploadStatus(err error) int {
+	var internal internalError
+	if errors.As(err, &internal) {
+		return http.StatusInternalServerError
+	}
+	return http.StatusBadRequest
+}
+
+// studentQuery is the student filter shared by the report page and the
+// API: branch selects a branch ranking, emplid a substring of the Emplid or
+// campus ID and where a filter expression.
+type studentQuery struct {
+	Branch, Emplid, Where string
+}
+
+func parseStudentQuery(r *http.Request) studentQuery {
+	return studentQuery{r.FormValue("branch"), r.FormValue("emplid"), r.FormValue("where")}
+}
+
+// filterStudents returns the ranking for q.Branch (the overall one when
+// empty) narrowed down by the rest of q.
+func filterStudents(report gradebook.SummaryReport, q studentQuery) ([]gradebook.RankedStudent, error) {
+	ranked := report.OverallTopStudents
+	if q.Branch != "" {
+		ranked = report.BranchRankings[q.Branch]
+	}
+	var where *gradebook.Filter
+	if q.Where != "" {
+		students := make([]gradebook.Student, len(ranked))
+		for i, rs := range ranked {
+			students[i] = rs.Student
+		}
+		var err error
+		if where, err = gradebook.CompileFilter(q.Where, students); err != nil {
+			return nil, err
+		}
+	}
+	emplid := strings.ToUpper(strings.TrimSpace(q.Emplid))
+	out := []gradebook.RankedStudent{}
+	for _, rs := range ranked {
+		if emplid != "" && !strings.Contains(strings.ToUpper(rs.Emplid), emplid) && !strings.Contains(rs.CampusID, emplid) {
+			continue
+		}
+		if where.Match(rs.Student) {
+			out = append(out, rs)
+		}
+	}
+	return out, nil
+}
+
+func (s *server) handleIndex(w http.ResponseWriter, r *http.Request) {
+	render(w, indexPage, struct {
+		Reports []*upload
+		Schemes []string
+	}{s.list(), gradebook.GradingSchemes})
+}
+
+func (s *server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	u, err := s.process(w, r)
+	if err != nil {
+		http.Error(w, err.Error(), uploadStatus(err))
+		return
+	}
+	http.Redirect(w, r, "/reports/"+u.ID, http.StatusSeeOther)
+}
+
+func (s *server) handleReport(w http.ResponseWriter, r *http.Request) {
+	u := s.lookup(r.PathValue("id"))
+	if u == nil {
+		http.NotFound(w, r)
+		return
+	}
+	q := parseStudentQuery(r)
+	students, err := filterStudents(u.Report, q)
+	var filterErr string
+	if err != nil {
+		filterErr = err.Error()
+	}
+
+	var summary []gradebook.Table
+	for _, t := range gradebook.Tables(u.Report) {
+		// Rankings are shown once, filtered, below the summary.
+		if t.Name == "overall_top_students" || strings.HasPrefix(t.Name, "branch_ranking_") {
+			continue
+		}
+		summary = append(summary, t)
+	}
+	var branches []string
+	for b := range u.Report.BranchRankings {
+		branches = append(branches, b)
+	}
+	sort.Strings(branches)
+
+	var charts []template.HTML
+	if u.Report.Charts != nil {
+		for _, c := range gradebook.RenderSVG(*u.Report.Charts) {
+			// The SVG is generated by us with every label escaped.
+			charts = append(charts, template.HTML(c.SVG))
+		}
+	}
+
+	render(w, reportPage, struct {
+		Upload      *upload
+		Summary     []gradebook.Table
+		Students    gradebook.Table
+		Charts      []template.HTML
+		Branches    []string
+		Query       studentQuery
+		FilterError string
+	}{u, summary, gradebook.StudentTable(students, u.Report.RankedBy), charts, branches, q, filterErr})
+}
+
+func (s *server) handleAPIList(w http.ResponseWriter, r *http.Request) {
+	type entry struct {
+		ID       string    `json:"id"`
+		Name     string    `json:"name"`
+		Uploaded time.Time `json:"uploaded"`
+	}
+	entries := []entry{}
+	for _, u := range s.list() {
+		entries = append(entries, entry{u.ID, u.Name, u.Uploaded})
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func (s *server) handleAPIUpload(w http.ResponseWriter, r *http.Request) {
+	u, err := s.process(w, r)
+	if err != nil {
+		writeJSON(w, uploadStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusCreated, u)
+}
+
+func (s *server) handleAPIReport(w http.ResponseWriter, r *http.Request) {
+	u := s.lookup(r.PathValue("id"))
+	if u == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such report"})
+		return
+	}
+	writeJSON(w, http.StatusOK, u)
+}
+
+func (s *server) handleAPIStudents(w http.ResponseWriter, r *http.Request) {
+	u := s.lookup(r.PathValue("id"))
+	if u == nil {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "no such report"})
+		return
+	}
+	students, err := filterStudents(u.Report, parseStudentQuery(r))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, students)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func render(w http.ResponseWriter, t *template.Template, data interface{}) {
+	var buf strings.Builder
+	if err := t.Execute(&buf, data); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	io.WriteString(w, buf.String())
+}
+
+var pageFuncs = template.FuncMap{
+	"cell": gradebook.FormatCell,
+	"numeric": func(v interface{}) bool {
+		switch v.(type) {
+		case int, float64:
+			return true
+		}
+		return false
+	},
+}
+
+const pageStyle = `<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 4px 10px; }
+th { background: #f0f0f0; text-align: left; }
+th.sortable { cursor: pointer; }
+th.asc::after { content: " \25B2"; }
+th.desc::after { content: " \25BC"; }
+td.num { text-align: right; font-variant-numeric: tabular-nums; }
+tr:nth-child(even) td { background: #fafafa; }
+form { margin-bottom: 1.5em; }
+label { margin-right: 1em; }
+.error { color: #c00; }
+.charts svg { margin: 0 1em 1em 0; border: 1px solid #eee; }
+</style>`
+
+var indexPage = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Gradebook Reports</title>
+` + pageStyle + `
+</head>
+<body>
+<h1>Gradebook Reports</h1>
+<form method="post" action="/upload" enctype="multipart/form-data">
+<p><input type="file" name="file" multiple required accept=".xlsx,.xlsm,.ods,.csv,.tsv"></p>
+<p>
+<label>Class No. <input name="class" size="6"></label>
+<label>Sheet <input name="sheet" size="10"></label>
+<label><input type="checkbox" name="all_sheets" value="1"> All sheets</label>
+</p>
+<p>
+<label>Rank by <input name="rank_by" placeholder="Total"></label>
+<label>Where <input name="where" size="30"></label>
+<label>Grading <select name="grading"><option value="">none</option>{{range .Schemes}}<option>{{.}}</option>{{end}}</select></label>
+<label><input type="checkbox" name="anomalies" value="1"> Anomalies</label>
+<label><input type="checkbox" name="correlations" value="1"> Correlations</label>
+<button type="submit">Upload</button>
+</p>
+</form>
+{{if .Reports}}
+<h2>Uploaded</h2>
+<ul>
+{{range .Reports}}<li><a href="/reports/{{.ID}}">{{.Name}}</a> ({{.Uploaded.Format "2006-01-02 15:04"}})</li>
+{{end}}</ul>
+{{end}}
+</body>
+</html>
+`))
+
+var reportPage = template.Must(template.New("report").Funcs(pageFuncs).Parse(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>{{.Upload.Name}}</title>
+` + pageStyle + `
+</head>
+<body>
+<p><a href="/">&larr; All reports</a> &middot; <a href="/api/reports/{{.Upload.ID}}">JSON</a></p>
+<h1>{{.Upload.Name}}</h1>
+{{with .Upload.Report.Where}}<p>Students matching <code>{{.}}</code>.</p>{{end}}
+{{with .Upload.Report.Validation}}<p>{{.RowsRead}} rows read, {{.RowsUsed}} used, {{len .Quarantined}} quarantined, {{len .Issues}} issues.</p>{{end}}
+
+<h2 id="students">Students</h2>
+<form method="get" action="#students">
+<label>Branch <select name="branch"><option value="">All branches</option>
+{{range .Branches}}<option{{if eq . $.Query.Branch}} selected{{end}}>{{.}}</option>{{end}}
+</select></label>
+<label>Emplid <input name="emplid" value="{{.Query.Emplid}}"></label>
+<label>Where <input name="where" size="40" value="{{.Query.Where}}" placeholder="Compre &lt; 20 &amp;&amp; MidSem &gt; 40"></label>
+<button type="submit">Filter</button>
+</form>
+{{with .FilterError}}<p class="error">{{.}}</p>{{end}}
+{{template "table" .Students}}
+
+{{if .Charts}}
+<h2 id="charts">Charts</h2>
+<div class="charts">{{range .Charts}}{{.}}{{end}}</div>
+{{end}}
+
+{{range .Summary}}
+<h2 id="{{.Name}}">{{.Title}}</h2>
+{{template "table" .}}
+{{end}}
+
+<script>
+document.querySelectorAll("table.sortable").forEach(function (table) {
+  table.querySelectorAll("th").forEach(function (th, col) {
+    th.classList.add("sortable");
+    th.addEventListener("click", function () {
+      var desc = th.classList.contains("asc");
+      table.querySelectorAll("th").forEach(function (h) { h.classList.remove("asc", "desc"); });
+      th.classList.add(desc ? "desc" : "asc");
+      var body = table.tBodies[0];
+      var rows = Array.prototype.slice.call(body.rows);
+      rows.sort(function (a, b) {
+        var x = a.cells[col].textContent, y = b.cells[col].textContent;
+        var nx = parseFloat(x), ny = parseFloat(y);
+        var c = (!isNaN(nx) && !isNaN(ny)) ? nx - ny : x.localeCompare(y);
+        return desc ? -c : c;
+      });
+      rows.forEach(function (row) { body.appendChild(row); });
+    });
+  });
+});
+</script>
+</body>
+</html>
+{{define "table"}}<table class="sortable">
+<thead><tr>{{range .Header}}<th>{{.}}</th>{{end}}</tr></thead>
+<tbody>
+{{range .Rows}}<tr>{{range .}}<td{{if numeric .}} class="num"{{end}}>{{cell .}}</td>{{end}}</tr>
+{{end}}</tbody>
+</table>{{end}}
+`))
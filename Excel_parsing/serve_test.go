@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/FrancoisDuvet/friendly-chainsaw/Excel_parsing/gradebook"
+)
+
+const serveMarks = `Class No.,Emplid,Campus ID,Mid Sem,Compre,Total
+1,101,2022A7PS0001P,30,50,80
+1,102,2022A3PS0002P,20,40,60
+2,103,2023A7PS0003P,25,45,70
+`
+
+func newTestServer(keep int) *server {
+	return &server{maxUpload: 1 << 20, keep: keep, reports: make(map[string]*upload)}
+}
+
+// postUpload sends files to the upload API with the given form fields.
+func postUpload(t *testing.T, h http.Handler, fields map[string]string, files ...string) *httptest.ResponseRecorder {
+	t.Helper()
+	var body bytes.Buffer
+	mw := multipart.NewWriter(&body)
+	for k, v := range fields {
+		mw.WriteField(k, v)
+	}
+	for _, content := range files {
+		fw, err := mw.CreateFormFile("file", "marks.csv")
+		if err != nil {
+			t.Fatal(err)
+		}
+		fw.Write([]byte(content))
+	}
+	mw.Close()
+	req := httptest.NewRequest("POST", "/api/reports", &body)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServeUpload(t *testing.T) {
+	tests := []struct {
+		name   string
+		fields map[string]string
+		files  []string
+		status int
+		ranked int
+	}{
+		{"whole class", nil, []string{serveMarks}, http.StatusCreated, 3},
+		{"one section", map[string]string{"class": "2"}, []string{serveMarks}, http.StatusCreated, 1},
+		{"no file", nil, nil, http.StatusBadRequest, 0},
+		{"unknown grading scheme", map[string]string{"grading": "curve"}, []string{serveMarks}, http.StatusBadRequest, 0},
+		{"bad rank expression", map[string]string{"rank_by": "Total +"}, []string{serveMarks}, http.StatusBadRequest, 0},
+	}
+	for _, tt := range tests {
+		s := newTestServer(0)
+		rec := postUpload(t, s.routes(), tt.fields, tt.files...)
+		if rec.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.name, rec.Code, tt.status, rec.Body)
+			continue
+		}
+		if tt.status != http.StatusCreated {
+			continue
+		}
+		var u upload
+		if err := json.Unmarshal(rec.Body.Bytes(), &u); err != nil {
+			t.Fatal(err)
+		}
+		if len(u.Report.OverallTopStudents) != tt.ranked || s.lookup(u.ID) == nil {
+			t.Errorf("%s: %d students ranked, stored %v", tt.name, len(u.Report.OverallTopStudents), s.lookup(u.ID) != nil)
+		}
+	}
+}
+
+func TestServeStudents(t *testing.T) {
+	s := newTestServer(0)
+	h := s.routes()
+	rec := postUpload(t, h, nil, serveMarks)
+	var u upload
+	if err := json.Unmarshal(rec.Body.Bytes(), &u); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		query  string
+		status int
+		want   []string
+	}{
+		{"", http.StatusOK, []string{"101", "103", "102"}},
+		{"?emplid=10", http.StatusOK, []string{"101", "103", "102"}},
+		{"?emplid=a3ps", http.StatusOK, []string{"102"}},
+		{"?branch=Computer+Science", http.StatusOK, []string{"101", "103"}},
+		{"?where=Compre+%3E%3D+45", http.StatusOK, []string{"101", "103"}},
+		{"?where=Compre+%3E%3D", http.StatusBadRequest, nil},
+	}
+	for _, tt := range tests {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", "/api/reports/"+u.ID+"/students"+tt.query, nil))
+		if rec.Code != tt.status {
+			t.Errorf("%q: status %d, want %d", tt.query, rec.Code, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			continue
+		}
+		var students []gradebook.RankedStudent
+		if err := json.Unmarshal(rec.Body.Bytes(), &students); err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, st := range students {
+			got = append(got, st.Emplid)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: students %v, want %v", tt.query, got, tt.want)
+		}
+	}
+
+	for _, path := range []string{"/api/reports/missing", "/api/reports/missing/students", "/reports/missing"} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+		if rec.Code != http.StatusNotFound {
+			t.Errorf("%s: status %d, want 404", path, rec.Code)
+		}
+	}
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/reports/"+u.ID+"?branch=Computer+Science", nil))
+	if rec.Code != http.StatusOK || !bytes.Contains(rec.Body.Bytes(), []byte("2023A7PS0003P")) {
+		t.Errorf("report page: status %d", rec.Code)
+	}
+}
+
+func TestServeKeepsNewest(t *testing.T) {
+	s := newTestServer(2)
+	for _, id := range []string{"a", "b", "c"} {
+		s.store(&upload{ID: id})
+	}
+	var ids []string
+	for _, u := range s.list() {
+		ids = append(ids, u.ID)
+	}
+	if !reflect.DeepEqual(ids, []string{"c", "b"}) || s.lookup("a") != nil {
+		t.Errorf("kept %v, want [c b]", ids)
+	}
+}
+
+func TestUploadStatus(t *testing.T) {
+	tests := []struct {
+		err  error
+		want int
+	}{
+		{errors.New("no gradebook uploaded"), http.StatusBadRequest},
+		{internalError{errors.New("entropy exhausted")}, http.StatusInternalServerError},
+		{fmt.Errorf("wrapped: %w", internalError{errors.New("entropy exhausted")}), http.StatusInternalServerError},
+	}
+	for _, tt := range tests {
+		if got := uploadStatus(tt.err); got != tt.want {
+			t.Errorf("%v: status %d, want %d", tt.err, got, tt.want)
+		}
+	}
+}
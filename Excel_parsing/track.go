@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/FrancoisDuvet/friendly-chainsaw/Excel_parsing/gradebook"
+)
+
+// track runs the "track" subcommand: it loads gradebooks from several
+// courses and terms and follows students across them by Emplid.
+func track(args []string) {
+	fs := flag.NewFlagSet("track", flag.ExitOnError)
+	format := fs.String("format", "", "Export as json or csv (prints tables to the console when empty)")
+	output := fs.String("output", "", "Export destination: a file or - for json (stdout when empty), a directory for csv (tracking_report when empty)")
+	manifest := fs.String("manifest", "", "JSON or YAML list of {file, course, term} for gradebooks not named COURSE_YYYYYY_NN_...")
+	decline := fs.Float64("decline", 10, "Percentile points lost per term that count as declining")
+	minTerms := fs.Int("min-terms", 2, "Terms a student needs before being flagged as declining")
+	columnsFile := fs.String("columns", "", "JSON or YAML file with column header synonyms and extra components")
+	sheetName := fs.String("sheet", "", "Read the named sheet instead of the first one")
+	branchFile := fs.String("branches", "", "JSON or YAML file with the campus ID pattern and branch/campus names")
+	gradingScheme := fs.String("grading", "", "Assign letter grades using "+strings.Join(gradebook.GradingSchemes, ", "))
+	gradingFile := fs.String("grading-config", "", "JSON or YAML file with grade cutoffs, bands or quotas")
+	fs.Usage = func() {
+		fmt.Fprintln(fs.Output(), "Usage: track [flags] GRADEBOOK...")
+		fs.PrintDefaults()
+	}
+	fs.Parse(args)
+
+	var offerings []gradebook.Offering
+	if *manifest != "" {
+		var err error
+		if offerings, err = gradebook.LoadOfferings(*manifest); err != nil {
+			fmt.Println("Error reading manifest:", err)
+			return
+		}
+	}
+	files, err := expandInputs(fs.Args())
+	if err != nil {
+		fmt.Println("Error resolving input files:", err)
+		return
+	}
+	for _, file := range files {
+		o, err := gradebook.OfferingFromName(file)
+		if err != nil {
+			fmt.Println("Error:", err)
+			return
+		}
+		offerings = append(offerings, o)
+	}
+	if len(offerings) == 0 {
+		fs.Usage()
+		os.Exit(2)
+	}
+
+	columnCfg, err := gradebook.LoadColumnConfig(*columnsFile)
+	if err != nil {
+		fmt.Println("Error reading column config:", err)
+		return
+	}
+	branches, err := gradebook.LoadBranchRegistry(*branchFile, "")
+	if err != nil {
+		fmt.Println("Error reading branch registry:", err)
+		return
+	}
+	for i, o := range offerings {
+		// A student appears once per offering, so duplicates are only
+		// checked within each gradebook.
+		loader, err := gradebook.NewLoader(gradebook.LoadOptions{
+			Columns:  columnCfg,
+			Sheets:   gradebook.SheetSelection{Name: *sheetName},
+			Branches: branches,
+		})
+		if err != nil {
+			fmt.Println("Error preparing loader:", err)
+			return
+		}
+		if offerings[i].Students, err = loader.LoadFile(o.File); err != nil {
+			fmt.Println("Error opening file:", err)
+			return
+		}
+		if v := loader.Validation(); len(v.Issues) > 0 {
+			fmt.Fprintf(os.Stderr, "%s %s: %d rows read, %d used, %d issues\n", o.Course, o.Term, v.RowsRead, v.RowsUsed, len(v.Issues))
+		}
+	}
+
+	opts := gradebook.TrackOptions{
+		Report:   gradebook.Options{Rank: gradebook.DefaultRankOptions(), MaxTotal: columnCfg.MaxMarks[gradebook.FieldTotal]},
+		Decline:  *decline,
+		MinTerms: *minTerms,
+	}
+	if *gradingScheme != "" || *gradingFile != "" {
+		gradingCfg, err := gradebook.LoadGradingConfig(*gradingFile, *gradingScheme)
+		if err != nil {
+			fmt.Println("Error reading grading config:", err)
+			return
+		}
+		opts.Report.Grading = &gradingCfg
+	}
+	report, err := gradebook.Track(offerings, opts)
+	if err != nil {
+		fmt.Println("Error tracking students:", err)
+		return
+	}
+	if *format == "" {
+		gradebook.PrintTracking(os.Stdout, report)
+		return
+	}
+	path, err := gradebook.ExportTracking(report, strings.ToLower(*format), *output)
+	if err != nil {
+		fmt.Println("Error exporting tracking report:", err)
+		return
+	}
+	if path != "-" {
+		fmt.Println("Tracking report successfully exported to", path)
+	}
+}